@@ -0,0 +1,28 @@
+package simpleblocklist
+
+import "net"
+
+const (
+	// anonymizedIPv4Bits the leading bits of IPv4 addresses kept in logs, which masks the last octet.
+	anonymizedIPv4Bits = 24
+	// anonymizedIPv6Bits the leading bits of IPv6 addresses kept in logs, which masks the last 80 bits.
+	anonymizedIPv6Bits = 48
+)
+
+// logIP returns ip as it may be logged: unchanged, or with its host part masked when logged IPs are
+// anonymized, e.g. "192.0.2.0" for "192.0.2.1". The port of an address such as RemoteAddr is
+// dropped. Values that aren't IPs are returned unchanged.
+func (a *SimpleBlocklist) logIP(ip string) string {
+	if !a.anonymizeLoggedIPs {
+		return ip
+	}
+
+	parsed := parseIP(stripPort(ip))
+	if parsed == nil {
+		return ip
+	}
+	if len(parsed) == net.IPv4len {
+		return parsed.Mask(net.CIDRMask(anonymizedIPv4Bits, 8*net.IPv4len)).String()
+	}
+	return parsed.Mask(net.CIDRMask(anonymizedIPv6Bits, 8*net.IPv6len)).String()
+}
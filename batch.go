@@ -0,0 +1,37 @@
+package simpleblocklist
+
+import (
+	"fmt"
+	"net"
+	"strings"
+)
+
+// Decision the outcome of evaluating one IP with EvaluateBatch.
+type Decision struct {
+	// IP the IP as given.
+	IP      string
+	Blocked bool
+	// MatchedNetwork the blacklisted network that matched, nil if the IP isn't blocked or is
+	// blocked by another rule, see IsBlocked.
+	MatchedNetwork *net.IPNet
+	// Err is set if IP isn't a valid IP address, in which case it wasn't evaluated.
+	Err error
+}
+
+// EvaluateBatch evaluates each of ips against the loaded lists, e.g. to audit the coverage of a
+// feed offline. The decisions are returned in the order of ips. Like IsBlocked, it doesn't take
+// request-level rules into account.
+func (a *SimpleBlocklist) EvaluateBatch(ips []string) []Decision {
+	decisions := make([]Decision, len(ips))
+	for i, value := range ips {
+		decisions[i].IP = value
+
+		ip := net.ParseIP(strings.TrimSpace(value))
+		if ip == nil {
+			decisions[i].Err = fmt.Errorf("invalid IP address %q", value)
+			continue
+		}
+		decisions[i].Blocked, decisions[i].MatchedNetwork = a.IsBlocked(ip)
+	}
+	return decisions
+}
@@ -0,0 +1,60 @@
+package simpleblocklist_test
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/LucaNori/traefik-simpleblocklist"
+)
+
+func TestSimpleBlocklist_EvaluateBatch(t *testing.T) {
+	cfg := simpleblocklist.CreateConfig()
+	cfg.BlacklistPath = createBlacklistFile(t, "192.0.2.0/24\n2001:db8::/32\n")
+
+	next := http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {})
+
+	handler, err := simpleblocklist.New(context.Background(), next, cfg, "simpleblocklist")
+	if err != nil {
+		t.Fatal(err)
+	}
+	blocklist := handler.(*simpleblocklist.SimpleBlocklist)
+
+	ips := []string{"192.0.2.7", "not-an-ip", "198.51.100.1", " 2001:db8::1 ", ""}
+	expected := []struct {
+		blocked        bool
+		matchedNetwork string
+		invalid        bool
+	}{
+		{blocked: true, matchedNetwork: "192.0.2.0/24"},
+		{invalid: true},
+		{},
+		{blocked: true, matchedNetwork: "2001:db8::/32"},
+		{invalid: true},
+	}
+
+	decisions := blocklist.EvaluateBatch(ips)
+	if len(decisions) != len(ips) {
+		t.Fatalf("got %d decisions, want %d", len(decisions), len(ips))
+	}
+
+	for i, decision := range decisions {
+		want := expected[i]
+		if decision.IP != ips[i] {
+			t.Errorf("decision %d: got IP %q, want %q", i, decision.IP, ips[i])
+		}
+		if (decision.Err != nil) != want.invalid {
+			t.Errorf("%q: got error %v, want error %t", ips[i], decision.Err, want.invalid)
+		}
+		if decision.Blocked != want.blocked {
+			t.Errorf("%q: got blocked %t, want %t", ips[i], decision.Blocked, want.blocked)
+		}
+		var matchedNetwork string
+		if decision.MatchedNetwork != nil {
+			matchedNetwork = decision.MatchedNetwork.String()
+		}
+		if matchedNetwork != want.matchedNetwork {
+			t.Errorf("%q: got matched network %q, want %q", ips[i], matchedNetwork, want.matchedNetwork)
+		}
+	}
+}
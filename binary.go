@@ -0,0 +1,186 @@
+package simpleblocklist
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"sort"
+)
+
+// The binary blacklist format, for lists too large to parse as text on every start:
+//
+//	magic "SBLB", version byte, big-endian uint32 record count,
+//	then per network: family byte (4 or 6), the 4 or 16 address bytes, prefix length byte.
+//
+// Records are sorted by family, address and prefix length, see CompileBinaryBlacklist.
+var binaryBlacklistMagic = []byte("SBLB")
+
+const (
+	binaryBlacklistVersion = 1
+	// maxBinaryPrealloc caps how many networks are allocated up front from the record count, so a
+	// corrupt header can't cause a huge allocation.
+	maxBinaryPrealloc = 1 << 20
+)
+
+// CompileBinaryBlacklist compiles a plain text blacklist read from text into the binary format
+// loaded by binaryBlacklistPath, written to w. Entries that can't be parsed fail the compilation.
+// Exceptions, includes and expiring entries can't be represented and fail it too.
+func CompileBinaryBlacklist(text io.Reader, w io.Writer) error {
+	result, err := parseBlacklist(text, blacklistOptions{format: blacklistFormatPlain, strict: true})
+	if err != nil {
+		return err
+	}
+	switch {
+	case len(result.exceptions) != 0:
+		return errors.New("exceptions are not supported by the binary format")
+	case len(result.includes) != 0:
+		return errors.New("includes are not supported by the binary format")
+	case len(result.annotations) != 0:
+		return errors.New("expiring entries are not supported by the binary format")
+	}
+
+	networks := result.networks
+	sort.Slice(networks, func(i, j int) bool {
+		if len(networks[i].IP) != len(networks[j].IP) {
+			return len(networks[i].IP) < len(networks[j].IP)
+		}
+		if c := bytes.Compare(networks[i].IP, networks[j].IP); c != 0 {
+			return c < 0
+		}
+		ones, _ := networks[i].Mask.Size()
+		otherOnes, _ := networks[j].Mask.Size()
+		return ones < otherOnes
+	})
+
+	bw := bufio.NewWriter(w)
+	header := append(append([]byte{}, binaryBlacklistMagic...), binaryBlacklistVersion, 0, 0, 0, 0)
+	binary.BigEndian.PutUint32(header[len(binaryBlacklistMagic)+1:], uint32(len(networks)))
+	if _, err := bw.Write(header); err != nil {
+		return err
+	}
+
+	for _, network := range networks {
+		family := byte(6)
+		if len(network.IP) == net.IPv4len {
+			family = 4
+		}
+		ones, _ := network.Mask.Size()
+
+		record := append(append([]byte{family}, network.IP...), byte(ones))
+		if _, err := bw.Write(record); err != nil {
+			return err
+		}
+	}
+
+	return bw.Flush()
+}
+
+// loadBinaryBlacklist loads the binary blacklist at path, see parseBinaryBlacklist.
+func loadBinaryBlacklist(path string, opts blacklistOptions, logger *logger) (*parseResult, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		if !opts.skipUnreadable {
+			return nil, err
+		}
+		logger.infof(logFields{"path": path}, "Skipping unreadable blacklist %s: %v", path, err)
+		return &parseResult{}, nil
+	}
+	defer file.Close()
+
+	result, err := parseBinaryBlacklist(file, opts.maxEntries)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+
+	logger.infof(logFields{"path": path, "entries": len(result.networks)},
+		"Loaded %d IPs/Networks from %s", len(result.networks), path)
+	return result, nil
+}
+
+// parseBinaryBlacklist streams the records of a binary blacklist from r, so the file is never held
+// in memory as a whole. It fails on a corrupt file, or if the file holds more than maxEntries
+// networks when maxEntries isn't 0.
+func parseBinaryBlacklist(r io.Reader, maxEntries int) (*parseResult, error) {
+	br := bufio.NewReader(r)
+
+	header := make([]byte, len(binaryBlacklistMagic)+5)
+	if _, err := io.ReadFull(br, header); err != nil {
+		return nil, fmt.Errorf("invalid binary blacklist header: %v", err)
+	}
+	if !bytes.Equal(header[:len(binaryBlacklistMagic)], binaryBlacklistMagic) {
+		return nil, errors.New("not a binary blacklist")
+	}
+	if version := header[len(binaryBlacklistMagic)]; version != binaryBlacklistVersion {
+		return nil, fmt.Errorf("unsupported binary blacklist version %d", version)
+	}
+
+	count := binary.BigEndian.Uint32(header[len(binaryBlacklistMagic)+1:])
+	if maxEntries > 0 && uint64(count) > uint64(maxEntries) {
+		return nil, fmt.Errorf("blacklist exceeds the maximum of %d entries", maxEntries)
+	}
+
+	prealloc := count
+	if prealloc > maxBinaryPrealloc {
+		prealloc = maxBinaryPrealloc
+	}
+	result := &parseResult{networks: make([]*net.IPNet, 0, prealloc)}
+
+	for i := uint32(0); i < count; i++ {
+		network, err := readBinaryRecord(br)
+		if err != nil {
+			return nil, fmt.Errorf("record %d: %v", i+1, err)
+		}
+		result.networks = append(result.networks, network)
+	}
+
+	if _, err := br.ReadByte(); err != io.EOF {
+		return nil, errors.New("unexpected data after the last record")
+	}
+
+	return result, nil
+}
+
+// readBinaryRecord reads the network of one record of a binary blacklist.
+func readBinaryRecord(br *bufio.Reader) (*net.IPNet, error) {
+	family, err := br.ReadByte()
+	if err != nil {
+		return nil, unexpectedEOF(err)
+	}
+
+	var ip net.IP
+	switch family {
+	case 4:
+		ip = make(net.IP, net.IPv4len)
+	case 6:
+		ip = make(net.IP, net.IPv6len)
+	default:
+		return nil, fmt.Errorf("invalid address family %d", family)
+	}
+	if _, err := io.ReadFull(br, ip); err != nil {
+		return nil, unexpectedEOF(err)
+	}
+
+	ones, err := br.ReadByte()
+	if err != nil {
+		return nil, unexpectedEOF(err)
+	}
+	if int(ones) > 8*len(ip) {
+		return nil, fmt.Errorf("invalid prefix length %d", ones)
+	}
+
+	mask := net.CIDRMask(int(ones), 8*len(ip))
+	return &net.IPNet{IP: ip.Mask(mask), Mask: mask}, nil
+}
+
+// unexpectedEOF reports a file ending in the middle of a record as truncated.
+func unexpectedEOF(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
@@ -0,0 +1,126 @@
+package simpleblocklist_test
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/LucaNori/traefik-simpleblocklist"
+)
+
+func TestSimpleBlocklist_BinaryBlacklist(t *testing.T) {
+	text := `# Mixed entries
+192.0.2.1
+198.51.100.0/24
+203.0.113.10-203.0.113.20
+10.20.*.*
+2001:db8::/32
+[2001:db8:ffff::1]
+`
+
+	var compiled bytes.Buffer
+	if err := simpleblocklist.CompileBinaryBlacklist(strings.NewReader(text), &compiled); err != nil {
+		t.Fatal(err)
+	}
+	binaryPath := filepath.Join(t.TempDir(), "blacklist.bin")
+	if err := os.WriteFile(binaryPath, compiled.Bytes(), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := context.Background()
+	next := http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		rw.WriteHeader(http.StatusOK)
+	})
+
+	textCfg := simpleblocklist.CreateConfig()
+	textCfg.BlacklistPath = createBlacklistFile(t, text)
+	textHandler, err := simpleblocklist.New(ctx, next, textCfg, "simpleblocklist")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	binaryCfg := simpleblocklist.CreateConfig()
+	binaryCfg.BinaryBlacklistPath = binaryPath
+	binaryHandler, err := simpleblocklist.New(ctx, next, binaryCfg, "simpleblocklist")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	serve := func(handler http.Handler, ip string) int {
+		recorder := httptest.NewRecorder()
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, "http://localhost", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("X-Forwarded-For", ip)
+		handler.ServeHTTP(recorder, req)
+		return recorder.Code
+	}
+
+	ips := []string{
+		"192.0.2.1", "192.0.2.2", "198.51.100.77", "203.0.113.9", "203.0.113.10", "203.0.113.20",
+		"203.0.113.21", "10.20.30.40", "10.21.0.1", "2001:db8::1", "2001:db9::1", "2001:db8:ffff::1",
+	}
+	blocked := 0
+	for _, ip := range ips {
+		want := serve(textHandler, ip)
+		if got := serve(binaryHandler, ip); got != want {
+			t.Errorf("%s: got status code %d from the binary blacklist, want %d as from the text one", ip, got, want)
+		}
+		if want == http.StatusForbidden {
+			blocked++
+		}
+	}
+	if blocked == 0 || blocked == len(ips) {
+		t.Errorf("expected a mix of blocked and allowed IPs, got %d blocked out of %d", blocked, len(ips))
+	}
+}
+
+func TestSimpleBlocklist_InvalidBinaryBlacklist(t *testing.T) {
+	var compiled bytes.Buffer
+	if err := simpleblocklist.CompileBinaryBlacklist(strings.NewReader("192.0.2.1\n2001:db8::/32\n"), &compiled); err != nil {
+		t.Fatal(err)
+	}
+	valid := compiled.Bytes()
+
+	tests := []struct {
+		desc    string
+		content []byte
+	}{
+		{desc: "empty", content: nil},
+		{desc: "text file", content: []byte("192.0.2.1\n")},
+		{desc: "truncated", content: valid[:len(valid)-3]},
+		{desc: "trailing data", content: append(append([]byte{}, valid...), 0)},
+	}
+
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "blacklist.bin")
+			if err := os.WriteFile(path, test.content, 0o600); err != nil {
+				t.Fatal(err)
+			}
+
+			cfg := simpleblocklist.CreateConfig()
+			cfg.BinaryBlacklistPath = path
+
+			next := http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {})
+
+			if _, err := simpleblocklist.New(context.Background(), next, cfg, "simpleblocklist"); err == nil {
+				t.Error("expected error for an invalid binary blacklist")
+			}
+		})
+	}
+}
+
+func TestCompileBinaryBlacklist_Unsupported(t *testing.T) {
+	for _, text := range []string{"!192.0.2.1\n", "include other.txt\n", "192.0.2.1 # expires:2999-01-01T00:00:00Z\n", "not-an-ip\n"} {
+		if err := simpleblocklist.CompileBinaryBlacklist(strings.NewReader(text), &bytes.Buffer{}); err == nil {
+			t.Errorf("%q: expected error", text)
+		}
+	}
+}
@@ -0,0 +1,904 @@
+package simpleblocklist
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	blacklistFormatPlain    = "plain"
+	blacklistFormatIPSet    = "ipset"
+	blacklistFormatHosts    = "hosts"
+	blacklistFormatScored   = "scored"
+	blacklistFormatFireHOL  = "firehol"
+	blacklistFormatSpamhaus = "spamhaus"
+)
+
+// entryExtractors pull the IP address or CIDR network out of a line, per blacklist format.
+// They return false for lines that don't hold an entry, such as ipset "create" commands.
+var entryExtractors = map[string]func(line string) (string, bool){
+	blacklistFormatPlain: func(line string) (string, bool) {
+		return line, true
+	},
+	// ipset save format: "add <set> <entry> [options]"
+	blacklistFormatIPSet: func(line string) (string, bool) {
+		fields := strings.Fields(line)
+		if len(fields) < 3 || fields[0] != "add" {
+			return "", false
+		}
+		return fields[2], true
+	},
+	// hosts file format: "<ip> <hostname>..."
+	blacklistFormatHosts: func(line string) (string, bool) {
+		return strings.Fields(line)[0], true
+	},
+	// scored format: "<ip> [score]", see meetsScoreThreshold
+	blacklistFormatScored: func(line string) (string, bool) {
+		return strings.Fields(line)[0], true
+	},
+	// FireHOL .netset and .ipset format: one entry per line, see parseFireHOLEntries for the header
+	blacklistFormatFireHOL: func(line string) (string, bool) {
+		return line, true
+	},
+	// Spamhaus DROP and EDROP format: "<network> ; <SBL ID>", with ";" comment lines
+	blacklistFormatSpamhaus: func(line string) (string, bool) {
+		entry, _, _ := strings.Cut(line, ";")
+		entry = strings.TrimSpace(entry)
+		return entry, len(entry) != 0
+	},
+}
+
+// isBlacklistFormat reports whether format is a supported blacklist format.
+func isBlacklistFormat(format string) bool {
+	_, line := entryExtractors[format]
+	_, document := documentParsers[format]
+	return line || document
+}
+
+// formatExtensions the formats selected by the file extension when the configured format is plain.
+// ".ipset" is left out: FireHOL lists and "ipset save" output both use it, in different formats.
+var formatExtensions = map[string]string{
+	".csv":    blacklistFormatCSV,
+	".json":   blacklistFormatJSON,
+	".netset": blacklistFormatFireHOL,
+}
+
+// fileFormat returns the format path is parsed in: a file whose extension, compressed or not, is
+// in formatExtensions is parsed in that format unless another format than plain is configured,
+// otherwise the configured format.
+func fileFormat(path string, format string) string {
+	if format != blacklistFormatPlain {
+		return format
+	}
+	if extensionFormat, ok := formatExtensions[strings.ToLower(filepath.Ext(strings.TrimSuffix(path, ".gz")))]; ok {
+		return extensionFormat
+	}
+	return format
+}
+
+// blacklistOptions controls how blacklist files are loaded and parsed.
+type blacklistOptions struct {
+	// format selects the entry extractor, one of the blacklistFormat constants.
+	format string
+	// skipUnreadable logs and skips files that can't be opened instead of failing.
+	skipUnreadable bool
+	// strict fails on entries that can't be parsed instead of skipping them.
+	strict bool
+	// maxEntries caps the number of networks, including exceptions, loaded across all files.
+	// 0 means unlimited.
+	maxEntries int
+	// disabledTags the tags whose entries are not loaded, see parseTag.
+	disabledTags map[string]struct{}
+	// scoreThreshold the minimum score of the entries loaded from scored blacklists.
+	scoreThreshold int
+	// collapse merges adjacent networks of the blacklist into supernets once loaded, see
+	// collapseNetworks.
+	collapse bool
+	// maxDecompressedSize caps the decompressed size of gzip-compressed input, 0 means unlimited.
+	maxDecompressedSize int64
+}
+
+const (
+	// maxSkippedSample caps how many skipped lines are kept for reporting.
+	maxSkippedSample = 5
+	// maxLineLength caps the length of a blacklist line, longer lines are skipped as invalid.
+	maxLineLength = 4096
+	// maxIncludeDepth caps how deeply blacklist files may include each other.
+	maxIncludeDepth = 8
+)
+
+// parseResult the networks parsed from one or more blacklists, and the lines that were skipped as invalid.
+type parseResult struct {
+	networks []*net.IPNet
+	// exceptions networks from "!" entries, which are never blocked by the blacklist.
+	exceptions []*net.IPNet
+	// includes paths of the files included with "include <path>" lines, not loaded yet.
+	includes []string
+	// annotations metadata of the networks that have any, keyed by network.
+	annotations map[string]entryAnnotation
+	// listed the networks added so far, keyed by network, only kept once a network is annotated.
+	listed map[string]struct{}
+	// expired the number of entries skipped because they expired.
+	expired int
+	// disabled the number of entries skipped because their tag is disabled.
+	disabled int
+	// belowThreshold the number of entries skipped because their score is below the threshold.
+	belowThreshold int
+	// duplicates the number of networks removed by dedupe.
+	duplicates    int
+	skipped       int
+	skippedSample []string
+}
+
+func (r *parseResult) merge(other *parseResult) {
+	if r.listed == nil && len(other.annotations) == 0 {
+		r.networks = append(r.networks, other.networks...)
+	} else {
+		for _, network := range other.networks {
+			r.add([]*net.IPNet{network}, other.annotations[network.String()])
+		}
+	}
+	r.exceptions = append(r.exceptions, other.exceptions...)
+	r.expired += other.expired
+	r.disabled += other.disabled
+	r.belowThreshold += other.belowThreshold
+	r.skipped += other.skipped
+	for _, line := range other.skippedSample {
+		if len(r.skippedSample) == maxSkippedSample {
+			break
+		}
+		r.skippedSample = append(r.skippedSample, line)
+	}
+}
+
+// dedupe removes the networks listed more than once, e.g. by several feeds, keeping the first.
+func (r *parseResult) dedupe() {
+	seen := make(map[string]struct{}, len(r.networks))
+	networks := r.networks[:0]
+	for _, network := range r.networks {
+		key := string(network.IP) + string(network.Mask)
+		if _, ok := seen[key]; ok {
+			r.duplicates++
+			continue
+		}
+		seen[key] = struct{}{}
+		networks = append(networks, network)
+	}
+	r.networks = networks
+}
+
+// add appends networks listed with annotation, the zero annotation for a permanent entry without a
+// reason. A network listed more than once keeps its longest ban: a permanent listing wins over an
+// expiring one, so a feed banning a network temporarily never lifts a permanent ban from another list.
+func (r *parseResult) add(networks []*net.IPNet, annotation entryAnnotation) {
+	if r.listed == nil && annotation != (entryAnnotation{}) {
+		// Only needed to combine annotations, so lists without any skip it
+		r.listed = make(map[string]struct{}, len(r.networks))
+		for _, network := range r.networks {
+			r.listed[network.String()] = struct{}{}
+		}
+	}
+
+	if r.listed != nil {
+		for _, network := range networks {
+			key := network.String()
+			if _, ok := r.listed[key]; !ok {
+				r.listed[key] = struct{}{}
+				if annotation != (entryAnnotation{}) {
+					r.annotate(key, annotation)
+				}
+				continue
+			}
+			r.relist(key, annotation)
+		}
+	}
+	r.networks = append(r.networks, networks...)
+}
+
+// relist combines annotation with the one of the network keyed by key, which is already listed.
+func (r *parseResult) relist(key string, annotation entryAnnotation) {
+	existing := r.annotations[key]
+	if existing.expires.IsZero() || annotation.expires.IsZero() {
+		existing.expires = time.Time{}
+	} else if annotation.expires.After(existing.expires) {
+		existing.expires = annotation.expires
+	}
+	if len(existing.reason) == 0 {
+		existing.reason = annotation.reason
+	}
+	if existing == (entryAnnotation{}) {
+		delete(r.annotations, key)
+	} else {
+		r.annotate(key, existing)
+	}
+}
+
+func (r *parseResult) annotate(network string, annotation entryAnnotation) {
+	if r.annotations == nil {
+		r.annotations = make(map[string]entryAnnotation)
+	}
+	r.annotations[network] = annotation
+}
+
+func (r *parseResult) skip(line string) {
+	r.skipped++
+	if len(r.skippedSample) < maxSkippedSample {
+		r.skippedSample = append(r.skippedSample, line)
+	}
+}
+
+// loadBlacklists loads and merges the blacklist files at paths. A path may be a glob pattern,
+// in which case every matching file is loaded. A file listed several times, or matched by several
+// patterns, is only loaded once.
+func loadBlacklists(paths []string, opts blacklistOptions, logger *logger) (*parseResult, error) {
+	result := &parseResult{}
+	loaded := make(map[string]bool)
+	for _, pattern := range paths {
+		files, err := expandBlacklistPath(pattern)
+		if err != nil {
+			if !opts.skipUnreadable {
+				return nil, err
+			}
+			logger.infof(logFields{"path": pattern}, "Skipping blacklist %s: %v", pattern, err)
+			continue
+		}
+
+		for _, path := range files {
+			if loaded[absPath(path)] {
+				continue
+			}
+			loaded[absPath(path)] = true
+
+			fileResult, err := loadBlacklistFile(path, opts, logger)
+			if err != nil {
+				return nil, err
+			}
+			if fileResult == nil {
+				continue
+			}
+			result.merge(fileResult)
+
+			if opts.maxEntries > 0 && len(result.networks)+len(result.exceptions) > opts.maxEntries {
+				return nil, fmt.Errorf("blacklists exceed the maximum of %d entries", opts.maxEntries)
+			}
+		}
+	}
+
+	return result, nil
+}
+
+// loadBlacklistFile loads the blacklist file at path and the files it includes. It returns nil if
+// the file can't be opened and unreadable files are skipped.
+func loadBlacklistFile(path string, opts blacklistOptions, logger *logger) (*parseResult, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		if !opts.skipUnreadable {
+			return nil, err
+		}
+		logger.infof(logFields{"path": path}, "Skipping unreadable blacklist %s: %v", path, err)
+		return nil, nil
+	}
+
+	result, err := parseBlacklistFile(path, file, opts)
+	file.Close()
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	if err := loadIncludes(path, result, opts, logger, []string{absPath(path)}); err != nil {
+		return nil, err
+	}
+
+	logger.infof(logFields{"path": path, "entries": len(result.networks), "skipped": result.skipped},
+		"Loaded %d IPs/Networks from %s", len(result.networks), path)
+	if result.belowThreshold > 0 {
+		logger.infof(logFields{"path": path, "below_threshold": result.belowThreshold},
+			"Ignored %d entries scored below %d from %s", result.belowThreshold, opts.scoreThreshold, path)
+	}
+	if result.disabled > 0 {
+		logger.infof(logFields{"path": path, "disabled": result.disabled},
+			"Ignored %d entries with a disabled tag from %s", result.disabled, path)
+	}
+	if result.expired > 0 {
+		logger.infof(logFields{"path": path, "expired": result.expired},
+			"Ignored %d expired entries from %s", result.expired, path)
+	}
+	return result, nil
+}
+
+// loadIncludes loads the files included by the blacklist at path and merges them into result,
+// recursively. stack holds the absolute paths of the including files, which is used to detect
+// include cycles and to cap the include depth. Relative includes resolve against the directory of
+// the including file. A missing include is an error in strict mode and skipped otherwise.
+func loadIncludes(path string, result *parseResult, opts blacklistOptions, logger *logger, stack []string) error {
+	includes := result.includes
+	result.includes = nil
+
+	for _, include := range includes {
+		if !filepath.IsAbs(include) {
+			include = filepath.Join(filepath.Dir(path), include)
+		}
+
+		for _, including := range stack {
+			if including == absPath(include) {
+				return fmt.Errorf("include cycle: %s -> %s", strings.Join(stack, " -> "), absPath(include))
+			}
+		}
+		if len(stack) > maxIncludeDepth {
+			return fmt.Errorf("%s: includes are nested deeper than %d levels", path, maxIncludeDepth)
+		}
+
+		file, err := os.Open(include)
+		if err != nil {
+			if opts.strict {
+				return fmt.Errorf("%s: include: %v", path, err)
+			}
+			logger.warnf(logFields{"path": path, "include": include}, "Skipping missing include %s in %s: %v", include, path, err)
+			continue
+		}
+
+		included, err := parseBlacklistFile(include, file, opts)
+		file.Close()
+		if err != nil {
+			return fmt.Errorf("%s: %v", include, err)
+		}
+		if err := loadIncludes(include, included, opts, logger, append(stack, absPath(include))); err != nil {
+			return err
+		}
+
+		logger.infof(logFields{"path": include, "entries": len(included.networks), "skipped": included.skipped},
+			"Loaded %d IPs/Networks from %s included by %s", len(included.networks), include, path)
+		result.merge(included)
+	}
+
+	return nil
+}
+
+// absPath returns the absolute form of path, or its cleaned form if that can't be determined.
+func absPath(path string) string {
+	if abs, err := filepath.Abs(path); err == nil {
+		return abs
+	}
+	return filepath.Clean(path)
+}
+
+// expandEnvPaths expands environment variables such as ${BLOCKLIST_FILE} in paths, as paths are
+// often injected by the container runtime. It fails if a path expands to an empty value.
+func expandEnvPaths(paths []string) ([]string, error) {
+	expanded := make([]string, len(paths))
+	for i, path := range paths {
+		expanded[i] = os.ExpandEnv(path)
+		if len(strings.TrimSpace(expanded[i])) == 0 {
+			return nil, fmt.Errorf("path %q expands to an empty value", path)
+		}
+	}
+	return expanded, nil
+}
+
+// expandBlacklistPath returns the files matching path if it is a glob pattern, or path itself otherwise.
+func expandBlacklistPath(path string) ([]string, error) {
+	if !strings.ContainsAny(path, "*?[") {
+		return []string{path}, nil
+	}
+
+	files, err := filepath.Glob(path)
+	if err != nil {
+		return nil, fmt.Errorf("invalid blacklist path pattern %s: %v", path, err)
+	}
+	if len(files) == 0 {
+		return nil, fmt.Errorf("no blacklist file matches %s", path)
+	}
+
+	return files, nil
+}
+
+// gzipMagic is the header every gzip stream starts with.
+var gzipMagic = []byte{0x1f, 0x8b}
+
+// parseBlacklistFile parses the blacklist file at path, transparently decompressing ".gz" files
+// and files starting with the gzip header, e.g. a compressed feed saved without the extension.
+// The input is decompressed as it is read rather than up front.
+// The JSON, TOML and CSV formats are parsed as documents, the other formats line by line. Some
+// file extensions select their format, see fileFormat.
+func parseBlacklistFile(path string, file io.Reader, opts blacklistOptions) (*parseResult, error) {
+	buffered := bufio.NewReader(file)
+	file = buffered
+	// A file shorter than the header can't be compressed, the error is left to the parser
+	header, _ := buffered.Peek(len(gzipMagic))
+	if strings.HasSuffix(path, ".gz") || bytes.Equal(header, gzipMagic) {
+		gz, err := gzip.NewReader(file)
+		if err != nil {
+			return nil, fmt.Errorf("invalid gzip file: %v", err)
+		}
+		defer gz.Close()
+		file = gz
+		if opts.maxDecompressedSize > 0 {
+			file = &sizeLimitReader{r: gz, limit: opts.maxDecompressedSize}
+		}
+	}
+
+	opts.format = fileFormat(path, opts.format)
+	if parse, ok := documentParsers[opts.format]; ok {
+		return parse(file, opts)
+	}
+	return parseBlacklist(file, opts)
+}
+
+// parseBlacklist parses one entry per line, skipping empty lines and comments. See parseEntry for the accepted entries.
+// The entry is extracted from each line according to the configured format.
+// Comments start with "#" and may follow an entry on the same line. An inline comment of the form
+// "# expires:<RFC 3339 timestamp>" makes the entry temporary, see parseInlineExpiry.
+// Lines of the form "include <path>" or "#include <path>" are collected in includes, to be loaded by loadIncludes.
+// Entries prefixed with "!" are exceptions, e.g. "!10.0.5.5" to allow one host inside a blocked range.
+// Entries that can't be parsed are skipped, or returned as an error in strict mode.
+// Parsing stops with an error as soon as more than opts.maxEntries networks have been read.
+// A FireHOL list fails when the number of entries read differs from the one its header declares,
+// so a truncated or partly invalid update is never loaded with entries silently dropped.
+func parseBlacklist(r io.Reader, opts blacklistOptions) (*parseResult, error) {
+	p := &lineParser{
+		opts:         opts,
+		extractEntry: entryExtractors[opts.format],
+		result:       &parseResult{},
+		now:          time.Now(),
+		declared:     -1,
+	}
+	if p.extractEntry == nil {
+		p.extractEntry = entryExtractors[blacklistFormatPlain]
+	}
+
+	scanner, overlong := newLineScanner(r)
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		line := scanner.Text()
+		if *overlong {
+			if opts.strict {
+				return nil, fmt.Errorf("line %d: line exceeds %d bytes", lineNumber, maxLineLength)
+			}
+			p.result.skip(line[:maxSkippedLineLength] + "...")
+			continue
+		}
+
+		if err := p.parseLine(line); err != nil {
+			return nil, fmt.Errorf("line %d: %v", lineNumber, err)
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if p.declared >= 0 && p.read != p.declared {
+		return nil, fmt.Errorf("the header declares %d entries but %d were read", p.declared, p.read)
+	}
+
+	return p.result, nil
+}
+
+// lineParser the state of parseBlacklist carried from one line to the next.
+type lineParser struct {
+	opts         blacklistOptions
+	extractEntry func(line string) (string, bool)
+	result       *parseResult
+	now          time.Time
+	// disabled whether the lines being read follow a disabled tag.
+	disabled bool
+	// declared the number of entries a FireHOL header announces, -1 without one.
+	declared int
+	// read the number of entries read, checked against declared.
+	read int
+}
+
+// parseLine parses one line of the blacklist.
+func (p *lineParser) parseLine(line string) error {
+	if p.parseDirective(line) {
+		return nil
+	}
+
+	// Strip comments, both full-line and inline after an entry
+	comment := ""
+	if i := strings.IndexByte(line, '#'); i >= 0 {
+		line, comment = line[:i], line[i+1:]
+	}
+	line = strings.TrimSpace(line)
+	if line == "" {
+		return nil
+	}
+
+	if fields := strings.Fields(line); len(fields) == 2 && fields[0] == "include" {
+		p.include(fields[1])
+		return nil
+	}
+
+	entry, ok := p.extractEntry(line)
+	if !ok {
+		return nil
+	}
+	if p.disabled {
+		p.result.disabled++
+		return nil
+	}
+
+	annotation, keep, err := p.annotation(line, comment)
+	if err != nil {
+		return p.invalid(line, err)
+	}
+	if !keep {
+		return nil
+	}
+	return p.addEntry(line, entry, annotation)
+}
+
+// parseDirective handles the tag, FireHOL header and "#include" comment lines, and reports whether
+// line was one of them.
+func (p *lineParser) parseDirective(line string) bool {
+	if tag, ok := parseTag(line); ok {
+		_, p.disabled = p.opts.disabledTags[tag]
+		return true
+	}
+	if p.opts.format == blacklistFormatFireHOL {
+		if count, ok := parseFireHOLEntries(line); ok {
+			p.declared = count
+			return true
+		}
+	}
+	if include, ok := parseIncludeDirective(line); ok {
+		p.include(include)
+		return true
+	}
+	return false
+}
+
+// include collects the included path, unless it follows a disabled tag.
+func (p *lineParser) include(path string) {
+	if !p.disabled {
+		p.result.includes = append(p.result.includes, path)
+	}
+}
+
+// annotation returns the annotation of the entry of line, whose inline comment is comment, and
+// whether the entry is kept: entries scored below the threshold and expired entries are counted
+// and dropped.
+func (p *lineParser) annotation(line, comment string) (entryAnnotation, bool, error) {
+	if p.opts.format == blacklistFormatScored {
+		enforced, err := meetsScoreThreshold(line, p.opts.scoreThreshold)
+		if err != nil {
+			return entryAnnotation{}, false, err
+		}
+		if !enforced {
+			p.result.belowThreshold++
+			return entryAnnotation{}, false, nil
+		}
+	}
+
+	expires, err := parseInlineExpiry(comment)
+	if err != nil {
+		return entryAnnotation{}, false, err
+	}
+	annotation := entryAnnotation{expires: expires}
+	if p.opts.format == blacklistFormatSpamhaus {
+		annotation.reason = spamhausReason(line)
+	}
+	if annotation.expired(p.now) {
+		p.result.expired++
+		return entryAnnotation{}, false, nil
+	}
+	return annotation, true, nil
+}
+
+// addEntry adds entry, extracted from line, to the networks or, prefixed with "!", to the exceptions.
+func (p *lineParser) addEntry(line, entry string, annotation entryAnnotation) error {
+	exception := strings.HasPrefix(entry, "!")
+	if exception {
+		entry = strings.TrimSpace(entry[1:])
+	}
+
+	networks := parseEntry(entry)
+	if networks == nil {
+		return p.invalid(line, fmt.Errorf("invalid IP address or network %q", line))
+	}
+	if exception {
+		p.result.exceptions = append(p.result.exceptions, networks...)
+	} else {
+		p.read++
+		p.result.add(networks, annotation)
+	}
+
+	if p.opts.maxEntries > 0 && len(p.result.networks)+len(p.result.exceptions) > p.opts.maxEntries {
+		return fmt.Errorf("blacklist exceeds the maximum of %d entries", p.opts.maxEntries)
+	}
+	return nil
+}
+
+// invalid skips the invalid line, or returns err in strict mode.
+func (p *lineParser) invalid(line string, err error) error {
+	if p.opts.strict {
+		return err
+	}
+	p.result.skip(line)
+	return nil
+}
+
+// meetsScoreThreshold reports whether the entry of a scored blacklist line, "<ip> <score>", is
+// enforced with threshold. The score is a non-negative integer, and an entry without one has the
+// maximum score so it is always enforced.
+func meetsScoreThreshold(line string, threshold int) (bool, error) {
+	fields := strings.Fields(line)
+	switch len(fields) {
+	case 1:
+		return true, nil
+	case 2:
+	default:
+		return false, fmt.Errorf("unexpected fields after the score in %q", line)
+	}
+
+	score, err := strconv.Atoi(fields[1])
+	if err != nil || score < 0 {
+		return false, fmt.Errorf("invalid score %q", fields[1])
+	}
+	return score >= threshold, nil
+}
+
+// parseInlineExpiry parses the expiry of an entry from its inline comment, e.g.
+// "192.0.2.1 # expires:2025-01-01T00:00:00Z". It returns the zero time, a permanent entry, if the
+// comment has no expiry, and an error if the timestamp isn't RFC 3339.
+func parseInlineExpiry(comment string) (time.Time, error) {
+	comment = strings.TrimSpace(comment)
+	if !strings.HasPrefix(comment, "expires:") {
+		return time.Time{}, nil
+	}
+
+	value := strings.TrimSpace(comment[len("expires:"):])
+	expires, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid expiry %q", value)
+	}
+	return expires, nil
+}
+
+// parseTag parses a "# tag:<name>" comment line, which tags the entries that follow it up to the
+// next tag line or the end of the file. An empty name, "# tag:", ends the tagged group.
+func parseTag(line string) (tag string, ok bool) {
+	line = strings.TrimSpace(line)
+	if !strings.HasPrefix(line, "#") {
+		return "", false
+	}
+	line = strings.TrimSpace(line[1:])
+	if !strings.HasPrefix(line, "tag:") {
+		return "", false
+	}
+	return strings.TrimSpace(line[len("tag:"):]), true
+}
+
+// parseFireHOLEntries returns the number of entries declared by the header line of a FireHOL list,
+// "# Entries : 4521 subnets, 611453234 unique IPs" for a .netset file or "# Entries : 1234 unique
+// IPs" for an .ipset file. The other header lines are plain comments.
+func parseFireHOLEntries(line string) (int, bool) {
+	line = strings.TrimSpace(line)
+	if !strings.HasPrefix(line, "#") {
+		return 0, false
+	}
+	name, value, ok := strings.Cut(line[1:], ":")
+	if !ok || strings.TrimSpace(name) != "Entries" {
+		return 0, false
+	}
+	fields := strings.Fields(value)
+	if len(fields) == 0 {
+		return 0, false
+	}
+	count, err := strconv.Atoi(fields[0])
+	if err != nil || count < 0 {
+		return 0, false
+	}
+	return count, true
+}
+
+// spamhausReason returns the SBL ID of a Spamhaus DROP line such as "1.10.16.0/20 ; SBL256894",
+// which names the Spamhaus Block List record the network is listed for.
+func spamhausReason(line string) string {
+	_, reason, _ := strings.Cut(line, ";")
+	return strings.TrimSpace(reason)
+}
+
+// parseIncludeDirective returns the path of a "#include <path>" line. The directive is written as
+// a comment so that tools unaware of it skip the line, while "# include" remains a plain comment.
+func parseIncludeDirective(line string) (path string, ok bool) {
+	if fields := strings.Fields(line); len(fields) == 2 && fields[0] == "#include" {
+		return fields[1], true
+	}
+	return "", false
+}
+
+// maxSkippedLineLength caps how much of an over-long line is kept for reporting.
+const maxSkippedLineLength = 64
+
+// newLineScanner returns a scanner over the lines of r that never buffers more than maxLineLength
+// bytes, so a malformed feed can't cause unbounded allocations. A longer line is returned truncated
+// to maxLineLength bytes with overlong set, and the rest of it is discarded.
+func newLineScanner(r io.Reader) (scanner *bufio.Scanner, overlong *bool) {
+	overlong = new(bool)
+	discarding := false
+
+	// Leave room for the line ending, "\r\n" at most
+	bufferSize := maxLineLength + 2
+
+	scanner = bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, bufferSize), bufferSize)
+	scanner.Split(func(data []byte, atEOF bool) (int, []byte, error) {
+		if discarding {
+			if i := bytes.IndexByte(data, '\n'); i >= 0 {
+				discarding = false
+				return i + 1, nil, nil
+			}
+			return len(data), nil, nil
+		}
+
+		advance, token, err := bufio.ScanLines(data, atEOF)
+		if advance == 0 && token == nil && err == nil && len(data) == bufferSize {
+			// The buffer is full without a line ending, discard up to the next one
+			discarding = true
+			advance, token = len(data), data
+		}
+
+		*overlong = len(token) > maxLineLength
+		if *overlong {
+			token = token[:maxLineLength]
+		}
+		return advance, token, err
+	})
+
+	return scanner, overlong
+}
+
+// parseEntry parses a blacklist entry into the networks it covers. Besides the CIDR networks and
+// single IPs accepted by parseNetwork, it accepts IPv4 octet wildcards such as "192.0.2.*" and
+// address ranges such as "192.0.2.1-192.0.2.50", which are decomposed into the minimal set of
+// CIDR networks. It returns nil for anything else, including ambiguous forms like "192.0.*"
+// (missing octets) or "192.0.2.1-50" (partial range end). Bracketed IPv6 entries are accepted too.
+func parseEntry(entry string) []*net.IPNet {
+	entry = stripBrackets(entry)
+
+	if ipNet := parseNetwork(entry); ipNet != nil {
+		return []*net.IPNet{ipNet}
+	}
+
+	if strings.Contains(entry, "*") {
+		if ipNet := parseWildcard(entry); ipNet != nil {
+			return []*net.IPNet{ipNet}
+		}
+		return nil
+	}
+
+	if first, last, ok := strings.Cut(entry, "-"); ok {
+		start, end := parseIP(strings.TrimSpace(first)), parseIP(strings.TrimSpace(last))
+		if start == nil || end == nil || len(start) != len(end) || bytes.Compare(start, end) > 0 {
+			return nil
+		}
+		return rangeToNetworks(start, end)
+	}
+
+	return nil
+}
+
+// stripBrackets removes the brackets around an IPv6 address as found in URLs and some feeds, e.g.
+// "[2001:db8::1]", "[2001:db8::1]:443" (the port is dropped) or "[2001:db8::]/32".
+func stripBrackets(entry string) string {
+	if !strings.HasPrefix(entry, "[") {
+		return entry
+	}
+
+	if host, _, err := net.SplitHostPort(entry); err == nil {
+		return host
+	}
+	if addr, rest, ok := strings.Cut(entry[1:], "]"); ok && (rest == "" || strings.HasPrefix(rest, "/")) {
+		return addr + rest
+	}
+	return entry
+}
+
+// parseWildcard parses an IPv4 address whose trailing octets are "*", e.g. "192.0.2.*" (a /24)
+// or "10.*.*.*" (a /8). It returns nil if a wildcard is followed by a number or octets are missing.
+func parseWildcard(entry string) *net.IPNet {
+	octets := strings.Split(entry, ".")
+	if len(octets) != net.IPv4len {
+		return nil
+	}
+
+	ones := 0
+	for i, octet := range octets {
+		if octet == "*" {
+			octets[i] = "0"
+			continue
+		}
+		if ones != 8*i {
+			return nil
+		}
+		ones += 8
+	}
+
+	ip := net.ParseIP(strings.Join(octets, ".")).To4()
+	if ip == nil {
+		return nil
+	}
+	return &net.IPNet{IP: ip, Mask: net.CIDRMask(ones, 8*net.IPv4len)}
+}
+
+// rangeToNetworks decomposes the inclusive address range start-end into the minimal list of CIDR
+// networks covering it. start and end must have the same length and start must not exceed end.
+func rangeToNetworks(start, end net.IP) []*net.IPNet {
+	bits := 8 * len(start)
+
+	var networks []*net.IPNet
+	for {
+		// Grow the network at start for as long as it stays aligned and within the range
+		ones := bits
+		for ones > 0 {
+			mask := net.CIDRMask(ones-1, bits)
+			if !start.Mask(mask).Equal(start) || bytes.Compare(lastIP(start, mask), end) > 0 {
+				break
+			}
+			ones--
+		}
+
+		mask := net.CIDRMask(ones, bits)
+		networks = append(networks, &net.IPNet{IP: start, Mask: mask})
+
+		last := lastIP(start, mask)
+		if bytes.Equal(last, end) {
+			return networks
+		}
+		start = nextIP(last)
+	}
+}
+
+// lastIP returns the last address of the network with the given mask that starts at ip.
+func lastIP(ip net.IP, mask net.IPMask) net.IP {
+	last := make(net.IP, len(ip))
+	for i := range ip {
+		last[i] = ip[i] | ^mask[i]
+	}
+	return last
+}
+
+// nextIP returns the address following ip.
+func nextIP(ip net.IP) net.IP {
+	next := make(net.IP, len(ip))
+	copy(next, ip)
+	for i := len(next) - 1; i >= 0; i-- {
+		next[i]++
+		if next[i] != 0 {
+			break
+		}
+	}
+	return next
+}
+
+// parseNetwork parses a CIDR network or a single IP address, which is converted to a /32 (IPv4)
+// or /128 (IPv6) network. IPv4-mapped IPv6 networks are converted to IPv4 networks.
+// It returns nil if entry is neither.
+func parseNetwork(entry string) *net.IPNet {
+	// Try parsing as CIDR first
+	if _, ipNet, err := net.ParseCIDR(entry); err == nil {
+		ones, bits := ipNet.Mask.Size()
+		if ip4 := ipNet.IP.To4(); ip4 != nil && bits == 8*net.IPv6len && ones >= 96 {
+			return &net.IPNet{IP: ip4, Mask: net.CIDRMask(ones-96, 8*net.IPv4len)}
+		}
+		return ipNet
+	}
+
+	// If not CIDR, try as single IP
+	if ip := net.ParseIP(entry); ip != nil {
+		bits := 8 * net.IPv6len
+		if ip4 := ip.To4(); ip4 != nil {
+			ip, bits = ip4, 8*net.IPv4len
+		}
+		return &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)}
+	}
+
+	return nil
+}
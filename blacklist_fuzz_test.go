@@ -0,0 +1,102 @@
+package simpleblocklist
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func FuzzLoadBlacklist(f *testing.F) {
+	f.Add([]byte("192.0.2.1\n198.51.100.0/24 # inline comment\n"), "plain", false)
+	f.Add([]byte("# comment only\n\n2001:db8::/32\n[2001:db8::1]:443\n!2001:db8::2\n"), "plain", true)
+	f.Add([]byte("192.0.2.*\n192.0.2.1-192.0.2.50\n2001:db8::-2001:db8::ffff\n"), "plain", false)
+	f.Add([]byte("create set hash:net\nadd set 192.0.2.0/24 timeout 0\n"), "ipset", false)
+	f.Add([]byte("0.0.0.0 ads.example\n192.0.2.1\tbad.example\n"), "hosts", false)
+	f.Add([]byte("\x00\xff\xfe\n192.0.2.1\x00\n"+strings.Repeat("A", 2*maxLineLength)+"\n192.0.2.2"), "plain", false)
+
+	f.Fuzz(func(t *testing.T, data []byte, format string, strict bool) {
+		result, err := parseBlacklist(bytes.NewReader(data), blacklistOptions{format: format, strict: strict})
+		if err != nil {
+			if !strict {
+				t.Fatalf("unexpected error in non-strict mode: %v", err)
+			}
+			return
+		}
+
+		if strict && result.skipped > 0 {
+			t.Errorf("strict mode skipped %d lines", result.skipped)
+		}
+		if len(result.skippedSample) > maxSkippedSample {
+			t.Errorf("got %d skipped samples, want at most %d", len(result.skippedSample), maxSkippedSample)
+		}
+		for _, network := range append(result.networks, result.exceptions...) {
+			ones, bits := network.Mask.Size()
+			if bits == 0 || ones > bits || 8*len(network.IP) != bits {
+				t.Errorf("invalid network %v", network)
+			}
+		}
+
+		// The trie must accept whatever the parser produced
+		newIPTrie(result.networks)
+	})
+}
+
+func TestParseBlacklist_OverlongLines(t *testing.T) {
+	tests := []struct {
+		desc     string
+		input    string
+		networks int
+		skipped  int
+	}{
+		{
+			desc:     "over-long line between entries",
+			input:    "192.0.2.1\n" + strings.Repeat("1", 3*maxLineLength) + "\n192.0.2.2\n",
+			networks: 2,
+			skipped:  1,
+		},
+		{
+			desc:     "over-long line at the end without newline",
+			input:    "192.0.2.1\n" + strings.Repeat("1", 3*maxLineLength),
+			networks: 1,
+			skipped:  1,
+		},
+		{
+			desc:     "over-long comment",
+			input:    "# " + strings.Repeat("x", maxLineLength) + "\n192.0.2.1\n",
+			networks: 1,
+			skipped:  1,
+		},
+		{
+			desc:     "line at the limit",
+			input:    "192.0.2.1" + strings.Repeat(" ", maxLineLength-len("192.0.2.1")) + "\n192.0.2.2\n",
+			networks: 2,
+		},
+		{
+			desc:     "null bytes",
+			input:    "192.0.2.1\x00\n\x00\x00\n192.0.2.2\n",
+			networks: 1,
+			skipped:  2,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			result, err := parseBlacklist(strings.NewReader(test.input), blacklistOptions{})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if len(result.networks) != test.networks {
+				t.Errorf("got %d networks, want %d", len(result.networks), test.networks)
+			}
+			if result.skipped != test.skipped {
+				t.Errorf("got %d skipped lines, want %d", result.skipped, test.skipped)
+			}
+		})
+	}
+
+	_, err := parseBlacklist(strings.NewReader(strings.Repeat("1", 2*maxLineLength)), blacklistOptions{strict: true})
+	if err == nil || !strings.Contains(err.Error(), "line 1: line exceeds") {
+		t.Errorf("expected an over-long line error in strict mode, got %v", err)
+	}
+}
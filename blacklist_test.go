@@ -0,0 +1,1431 @@
+package simpleblocklist_test
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/LucaNori/traefik-simpleblocklist"
+)
+
+func TestSimpleBlocklist_BlacklistGlob(t *testing.T) {
+	dir := t.TempDir()
+	for name, content := range map[string]string{
+		"manual.list":  "192.0.2.1\n",
+		"feed.list":    "203.0.113.0/24\n",
+		"ignored.json": "198.51.100.1\n",
+	} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	cfg := simpleblocklist.CreateConfig()
+	cfg.BlacklistPath = filepath.Join(dir, "*.list")
+
+	ctx := context.Background()
+	next := http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		rw.WriteHeader(http.StatusOK)
+	})
+
+	handler, err := simpleblocklist.New(ctx, next, cfg, "simpleblocklist")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		desc           string
+		ip             string
+		expectedStatus int
+	}{
+		{
+			desc:           "IP from first matching file",
+			ip:             "192.0.2.1",
+			expectedStatus: 403,
+		},
+		{
+			desc:           "IP from second matching file",
+			ip:             "203.0.113.7",
+			expectedStatus: 403,
+		},
+		{
+			desc:           "IP from file not matching the pattern",
+			ip:             "198.51.100.1",
+			expectedStatus: 200,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			recorder := httptest.NewRecorder()
+			req, err := http.NewRequestWithContext(ctx, http.MethodGet, "http://localhost", nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			req.Header.Set("X-Forwarded-For", test.ip)
+
+			handler.ServeHTTP(recorder, req)
+
+			if recorder.Code != test.expectedStatus {
+				t.Errorf("got status code %d, want %d", recorder.Code, test.expectedStatus)
+			}
+		})
+	}
+}
+
+func TestSimpleBlocklist_BlacklistGlobWithoutMatches(t *testing.T) {
+	cfg := simpleblocklist.CreateConfig()
+	cfg.BlacklistPath = filepath.Join(t.TempDir(), "*.list")
+
+	ctx := context.Background()
+	next := http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {})
+
+	_, err := simpleblocklist.New(ctx, next, cfg, "simpleblocklist")
+	if err == nil {
+		t.Error("expected error when the blacklist pattern matches no files")
+	}
+}
+
+func TestSimpleBlocklist_StrictParsing(t *testing.T) {
+	blacklistPath := createBlacklistFile(t, `# Valid entries
+192.0.2.1
+198.51.100.0/24
+not-an-ip
+203.0.113.1
+`)
+
+	tests := []struct {
+		desc        string
+		strict      bool
+		expectError bool
+	}{
+		{
+			desc:   "Lenient mode skips invalid entries",
+			strict: false,
+		},
+		{
+			desc:        "Strict mode rejects invalid entries",
+			strict:      true,
+			expectError: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			cfg := simpleblocklist.CreateConfig()
+			cfg.BlacklistPath = blacklistPath
+			cfg.StrictParsing = test.strict
+
+			ctx := context.Background()
+			next := http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {})
+
+			_, err := simpleblocklist.New(ctx, next, cfg, "simpleblocklist")
+			if !test.expectError {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				return
+			}
+
+			if err == nil {
+				t.Fatal("expected error for an invalid entry")
+			}
+			if !strings.Contains(err.Error(), "line 4") || !strings.Contains(err.Error(), "not-an-ip") {
+				t.Errorf("expected error to name the line number and entry, got %q", err)
+			}
+		})
+	}
+}
+
+func TestSimpleBlocklist_SkippedLinesSummary(t *testing.T) {
+	var buf bytes.Buffer
+	defer simpleblocklist.SetLogOutput(&buf)()
+
+	cfg := simpleblocklist.CreateConfig()
+	cfg.BlacklistPath = createBlacklistFile(t, `# Valid entries
+192.0.2.1
+198.51.100.0/24
+
+# Invalid entries
+invalid.ip.address
+256.256.256.256
+198.51.100.0/33
+not-an-ip
+
+203.0.113.1
+`)
+
+	ctx := context.Background()
+	next := http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {})
+
+	if _, err := simpleblocklist.New(ctx, next, cfg, "simpleblocklist"); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, want := range []string{
+		"Loaded 3 blacklisted IPs/Networks",
+		`Skipped 4 invalid blacklist lines, e.g. ["invalid.ip.address" "256.256.256.256" "198.51.100.0/33" "not-an-ip"]`,
+	} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("expected log line %q, got %q", want, buf.String())
+		}
+	}
+}
+
+func TestSimpleBlocklist_InlineComments(t *testing.T) {
+	cfg := simpleblocklist.CreateConfig()
+	cfg.BlacklistPath = createBlacklistFile(t, `# Full-line comment
+198.51.100.0/24  # office
+2001:db8::1	# single IPv6 host
+2001:db8:1::/48 #IPv6 network
+203.0.113.5# no space
+`)
+	cfg.StrictParsing = true
+
+	ctx := context.Background()
+	next := http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		rw.WriteHeader(http.StatusOK)
+	})
+
+	handler, err := simpleblocklist.New(ctx, next, cfg, "simpleblocklist")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		desc           string
+		ip             string
+		expectedStatus int
+	}{
+		{
+			desc:           "IP in CIDR with inline comment",
+			ip:             "198.51.100.200",
+			expectedStatus: 403,
+		},
+		{
+			desc:           "IPv6 with inline comment",
+			ip:             "2001:db8::1",
+			expectedStatus: 403,
+		},
+		{
+			desc:           "IP in IPv6 network with inline comment",
+			ip:             "2001:db8:1::42",
+			expectedStatus: 403,
+		},
+		{
+			desc:           "IP with comment directly after it",
+			ip:             "203.0.113.5",
+			expectedStatus: 403,
+		},
+		{
+			desc:           "IPv6 next to the blacklisted host",
+			ip:             "2001:db8::2",
+			expectedStatus: 200,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			recorder := httptest.NewRecorder()
+			req, err := http.NewRequestWithContext(ctx, http.MethodGet, "http://localhost", nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			req.Header.Set("X-Forwarded-For", test.ip)
+
+			handler.ServeHTTP(recorder, req)
+
+			if recorder.Code != test.expectedStatus {
+				t.Errorf("got status code %d, want %d", recorder.Code, test.expectedStatus)
+			}
+		})
+	}
+}
+
+func TestSimpleBlocklist_DisabledTags(t *testing.T) {
+	cfg := simpleblocklist.CreateConfig()
+	cfg.BlacklistPath = createBlacklistFile(t, `192.0.2.1
+
+# tag:scrapers
+198.51.100.1
+198.51.100.2 # inline comments still work
+
+# tag:attackers
+203.0.113.1
+
+# tag:
+192.0.2.2
+`)
+	cfg.DisabledTags = []string{"scrapers"}
+	cfg.StrictParsing = true
+
+	ctx := context.Background()
+	next := http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		rw.WriteHeader(http.StatusOK)
+	})
+
+	handler, err := simpleblocklist.New(ctx, next, cfg, "simpleblocklist")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		desc           string
+		ip             string
+		expectedStatus int
+	}{
+		{desc: "untagged before the tags", ip: "192.0.2.1", expectedStatus: 403},
+		{desc: "disabled tag", ip: "198.51.100.1", expectedStatus: 200},
+		{desc: "disabled tag with inline comment", ip: "198.51.100.2", expectedStatus: 200},
+		{desc: "enabled tag", ip: "203.0.113.1", expectedStatus: 403},
+		{desc: "untagged after the tags", ip: "192.0.2.2", expectedStatus: 403},
+	}
+
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			recorder := httptest.NewRecorder()
+			req, err := http.NewRequestWithContext(ctx, http.MethodGet, "http://localhost", nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			req.Header.Set("X-Forwarded-For", test.ip)
+
+			handler.ServeHTTP(recorder, req)
+
+			if recorder.Code != test.expectedStatus {
+				t.Errorf("got status code %d, want %d", recorder.Code, test.expectedStatus)
+			}
+		})
+	}
+}
+
+func TestSimpleBlocklist_GzipBlacklist(t *testing.T) {
+	var compressed bytes.Buffer
+	gz := gzip.NewWriter(&compressed)
+	if _, err := gz.Write([]byte("# Compressed feed\n192.0.2.1\n198.51.100.0/24\n")); err != nil {
+		t.Fatal(err)
+	}
+	if err := gz.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		desc string
+		name string
+	}{
+		{desc: "gz extension", name: "blacklist.txt.gz"},
+		{desc: "gzip header without the extension", name: "blacklist.txt"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			blacklistPath := filepath.Join(t.TempDir(), test.name)
+			if err := os.WriteFile(blacklistPath, compressed.Bytes(), 0o600); err != nil {
+				t.Fatal(err)
+			}
+
+			cfg := simpleblocklist.CreateConfig()
+			cfg.BlacklistPath = blacklistPath
+			cfg.StrictParsing = true
+
+			ctx := context.Background()
+			next := http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+				rw.WriteHeader(http.StatusOK)
+			})
+
+			handler, err := simpleblocklist.New(ctx, next, cfg, "simpleblocklist")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			for ip, expectedStatus := range map[string]int{"192.0.2.1": 403, "198.51.100.7": 403, "203.0.113.1": 200} {
+				recorder := httptest.NewRecorder()
+				req, err := http.NewRequestWithContext(ctx, http.MethodGet, "http://localhost", nil)
+				if err != nil {
+					t.Fatal(err)
+				}
+				req.Header.Set("X-Forwarded-For", ip)
+
+				handler.ServeHTTP(recorder, req)
+
+				if recorder.Code != expectedStatus {
+					t.Errorf("%s: got status code %d, want %d", ip, recorder.Code, expectedStatus)
+				}
+			}
+		})
+	}
+}
+
+func TestSimpleBlocklist_CorruptGzipBlacklist(t *testing.T) {
+	blacklistPath := filepath.Join(t.TempDir(), "blacklist.txt.gz")
+	if err := os.WriteFile(blacklistPath, []byte("192.0.2.1\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := simpleblocklist.CreateConfig()
+	cfg.BlacklistPath = blacklistPath
+
+	ctx := context.Background()
+	next := http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {})
+
+	_, err := simpleblocklist.New(ctx, next, cfg, "simpleblocklist")
+	if err == nil || !strings.Contains(err.Error(), "invalid gzip file") {
+		t.Errorf("expected an invalid gzip error, got %v", err)
+	}
+}
+
+func TestSimpleBlocklist_BlacklistFormats(t *testing.T) {
+	tests := []struct {
+		desc    string
+		format  string
+		content string
+	}{
+		{
+			desc:   "plain",
+			format: "plain",
+			content: `192.0.2.1
+198.51.100.0/24
+`,
+		},
+		{
+			desc:   "ipset",
+			format: "ipset",
+			content: `create blocklist hash:net family inet hashsize 1024 maxelem 65536
+add blocklist 192.0.2.1
+add blocklist 198.51.100.0/24 timeout 0
+`,
+		},
+		{
+			desc:   "hosts",
+			format: "hosts",
+			content: `# hosts-style feed
+192.0.2.1 scanner.example
+198.51.100.0/24	bad.example worse.example
+`,
+		},
+		{
+			desc:    "json",
+			format:  "json",
+			content: `[{"cidr": "192.0.2.1"}, {"cidr": "198.51.100.0/24", "reason": "scanner"}]`,
+		},
+		{
+			desc:   "toml",
+			format: "toml",
+			content: `[[entries]]
+cidr = "192.0.2.1"
+
+[[entries]]
+cidr = "198.51.100.0/24"
+reason = "scanner"
+`,
+		},
+		{
+			desc:   "csv",
+			format: "csv",
+			content: `ip,reason,expiry
+192.0.2.1,,
+198.51.100.0/24,scanner,2999-01-01T00:00:00Z
+`,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			cfg := simpleblocklist.CreateConfig()
+			cfg.BlacklistPath = createBlacklistFile(t, test.content)
+			cfg.BlacklistFormat = test.format
+			cfg.StrictParsing = true
+
+			ctx := context.Background()
+			next := http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+				rw.WriteHeader(http.StatusOK)
+			})
+
+			handler, err := simpleblocklist.New(ctx, next, cfg, "simpleblocklist")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			for ip, expectedStatus := range map[string]int{"192.0.2.1": 403, "198.51.100.7": 403, "203.0.113.1": 200} {
+				recorder := httptest.NewRecorder()
+				req, err := http.NewRequestWithContext(ctx, http.MethodGet, "http://localhost", nil)
+				if err != nil {
+					t.Fatal(err)
+				}
+				req.Header.Set("X-Forwarded-For", ip)
+
+				handler.ServeHTTP(recorder, req)
+
+				if recorder.Code != expectedStatus {
+					t.Errorf("%s: got status code %d, want %d", ip, recorder.Code, expectedStatus)
+				}
+			}
+		})
+	}
+}
+
+func TestSimpleBlocklist_BlacklistFormatFromExtension(t *testing.T) {
+	tests := []struct {
+		desc    string
+		name    string
+		format  string
+		content string
+	}{
+		{
+			desc:    "json extension",
+			name:    "feed.json",
+			content: `[{"cidr": "198.51.100.0/24", "reason": "botnet"}]`,
+		},
+		{
+			desc:    "upper case csv extension",
+			name:    "feed.CSV",
+			content: "198.51.100.0/24,botnet\n",
+		},
+		{
+			desc:    "configured format wins over the extension",
+			name:    "feed.json",
+			format:  "hosts",
+			content: "198.51.100.0/24 botnet.example\n",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			blacklistPath := filepath.Join(t.TempDir(), test.name)
+			if err := os.WriteFile(blacklistPath, []byte(test.content), 0o600); err != nil {
+				t.Fatal(err)
+			}
+
+			cfg := simpleblocklist.CreateConfig()
+			cfg.BlacklistPath = blacklistPath
+			cfg.StrictParsing = true
+			if len(test.format) != 0 {
+				cfg.BlacklistFormat = test.format
+			}
+
+			next := http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {})
+
+			handler, err := simpleblocklist.New(context.Background(), next, cfg, "simpleblocklist")
+			if err != nil {
+				t.Fatal(err)
+			}
+			blocklist := handler.(*simpleblocklist.SimpleBlocklist)
+
+			if blocked, _ := blocklist.IsBlocked(net.ParseIP("198.51.100.7")); !blocked {
+				t.Error("expected 198.51.100.7 to be blocked")
+			}
+		})
+	}
+}
+
+func TestSimpleBlocklist_FireHOLBlacklist(t *testing.T) {
+	tests := []struct {
+		desc          string
+		path          string
+		format        string
+		blockedIP     string
+		allowedIP     string
+		expectedError string
+	}{
+		{
+			desc:      "netset selected by the extension",
+			path:      "testdata/firehol_level1.netset",
+			blockedIP: "1.10.16.1",
+			allowedIP: "8.8.8.8",
+		},
+		{
+			desc:      "ipset",
+			path:      "testdata/firehol_abusers_1d.ipset",
+			format:    "firehol",
+			blockedIP: "203.0.113.201",
+			allowedIP: "203.0.113.202",
+		},
+		{
+			desc:          "truncated netset",
+			path:          createBlacklistFile(t, withoutLastLines(t, "testdata/firehol_level1.netset", 2)),
+			format:        "firehol",
+			expectedError: "the header declares 18 entries but 16 were read",
+		},
+		{
+			desc:          "ipset with an invalid entry",
+			path:          createBlacklistFile(t, withoutLastLines(t, "testdata/firehol_abusers_1d.ipset", 1)+"not-an-ip\n"),
+			format:        "firehol",
+			expectedError: "the header declares 5 entries but 4 were read",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			cfg := simpleblocklist.CreateConfig()
+			cfg.BlacklistPath = test.path
+			if len(test.format) != 0 {
+				cfg.BlacklistFormat = test.format
+			}
+
+			next := http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {})
+
+			handler, err := simpleblocklist.New(context.Background(), next, cfg, "simpleblocklist")
+			if len(test.expectedError) != 0 {
+				if err == nil || !strings.Contains(err.Error(), test.expectedError) {
+					t.Errorf("expected an error containing %q, got %v", test.expectedError, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			blocklist := handler.(*simpleblocklist.SimpleBlocklist)
+
+			if blocked, _ := blocklist.IsBlocked(net.ParseIP(test.blockedIP)); !blocked {
+				t.Errorf("expected %s to be blocked", test.blockedIP)
+			}
+			if blocked, _ := blocklist.IsBlocked(net.ParseIP(test.allowedIP)); blocked {
+				t.Errorf("expected %s to be allowed", test.allowedIP)
+			}
+		})
+	}
+}
+
+func TestSimpleBlocklist_IPSetExtension(t *testing.T) {
+	tests := []struct {
+		desc          string
+		path          string
+		format        string
+		blockedIPs    []string
+		expectedError string
+	}{
+		{
+			desc:       "ipset save output",
+			path:       "testdata/blocklist_save.ipset",
+			format:     "ipset",
+			blockedIPs: []string{"192.0.2.1", "198.51.100.7", "203.0.113.200", "2001:db8:dead::1"},
+		},
+		{
+			desc:          "ipset save output read as plain",
+			path:          "testdata/blocklist_save.ipset",
+			expectedError: `line 1: invalid IP address or network "create blocklist`,
+		},
+		{
+			desc:       "FireHOL ipset read as plain",
+			path:       "testdata/firehol_abusers_1d.ipset",
+			blockedIPs: []string{"203.0.113.201"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			cfg := simpleblocklist.CreateConfig()
+			cfg.BlacklistPath = test.path
+			cfg.StrictParsing = true
+			if len(test.format) != 0 {
+				cfg.BlacklistFormat = test.format
+			}
+
+			next := http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {})
+
+			handler, err := simpleblocklist.New(context.Background(), next, cfg, "simpleblocklist")
+			if len(test.expectedError) != 0 {
+				if err == nil || !strings.Contains(err.Error(), test.expectedError) {
+					t.Errorf("expected an error containing %q, got %v", test.expectedError, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			blocklist := handler.(*simpleblocklist.SimpleBlocklist)
+
+			for _, ip := range test.blockedIPs {
+				if blocked, _ := blocklist.IsBlocked(net.ParseIP(ip)); !blocked {
+					t.Errorf("expected %s to be blocked", ip)
+				}
+			}
+		})
+	}
+}
+
+// withoutLastLines returns the content of the file at path without its last n lines.
+func withoutLastLines(t *testing.T, path string, n int) string {
+	t.Helper()
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.SplitAfter(strings.TrimSuffix(string(content), "\n"), "\n")
+	return strings.Join(lines[:len(lines)-n], "")
+}
+
+func TestSimpleBlocklist_SpamhausBlacklist(t *testing.T) {
+	var buf bytes.Buffer
+	defer simpleblocklist.SetLogOutput(&buf)()
+
+	cfg := simpleblocklist.CreateConfig()
+	cfg.BlacklistPath = "testdata/spamhaus_drop.txt"
+	cfg.BlacklistFormat = "spamhaus"
+	cfg.StrictParsing = true
+
+	ctx := context.Background()
+	next := http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		rw.WriteHeader(http.StatusOK)
+	})
+
+	handler, err := simpleblocklist.New(ctx, next, cfg, "simpleblocklist")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for ip, expectedStatus := range map[string]int{"1.10.16.1": 403, "5.134.130.7": 403, "203.0.113.1": 200} {
+		recorder := httptest.NewRecorder()
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, "http://localhost", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("X-Forwarded-For", ip)
+
+		handler.ServeHTTP(recorder, req)
+
+		if recorder.Code != expectedStatus {
+			t.Errorf("%s: got status code %d, want %d", ip, recorder.Code, expectedStatus)
+		}
+	}
+
+	if !strings.Contains(buf.String(), "Loaded 5 IPs/Networks") {
+		t.Errorf("expected the 5 entries to be loaded, got %q", buf.String())
+	}
+	if !strings.Contains(buf.String(), "SBL256894") {
+		t.Errorf("expected the SBL ID in the denial log, got %q", buf.String())
+	}
+}
+
+func TestSimpleBlocklist_StructuredBlacklistMetadata(t *testing.T) {
+	tests := []struct {
+		desc    string
+		name    string
+		format  string
+		content string
+	}{
+		{
+			desc: "json",
+			name: "bans.json",
+			content: `[
+  {"cidr": "192.0.2.0/24", "reason": "expired abuse report", "expires": "2001-01-01T00:00:00Z"},
+  {"cidr": "198.51.100.0/24", "reason": "credential stuffing, login endpoint", "expires": "2999-01-01T00:00:00Z"}
+]`,
+		},
+		{
+			desc: "toml",
+			name: "bans.toml",
+			// TOML files aren't recognized by their extension
+			format: "toml",
+			content: `[[entries]]
+cidr = "192.0.2.0/24"
+reason = "expired abuse report"
+expires = 2001-01-01T00:00:00Z
+
+[[entries]]
+cidr = "198.51.100.0/24"
+reason = "credential stuffing, login endpoint"
+expires = 2999-01-01T00:00:00Z
+`,
+		},
+		{
+			desc: "csv",
+			name: "bans.csv",
+			content: `ip,reason,expiry
+192.0.2.0/24,expired abuse report,2001-01-01T00:00:00Z
+198.51.100.0/24,"credential stuffing, login endpoint",2999-01-01T00:00:00Z
+`,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			var buf bytes.Buffer
+			defer simpleblocklist.SetLogOutput(&buf)()
+
+			blacklistPath := filepath.Join(t.TempDir(), test.name)
+			if err := os.WriteFile(blacklistPath, []byte(test.content), 0o600); err != nil {
+				t.Fatal(err)
+			}
+
+			cfg := simpleblocklist.CreateConfig()
+			cfg.BlacklistPath = blacklistPath
+			if len(test.format) != 0 {
+				cfg.BlacklistFormat = test.format
+			}
+
+			ctx := context.Background()
+			next := http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {})
+
+			handler, err := simpleblocklist.New(ctx, next, cfg, "simpleblocklist")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if blocked, _ := handler.(*simpleblocklist.SimpleBlocklist).IsBlocked(net.ParseIP("192.0.2.1")); blocked {
+				t.Error("expected the expired entry not to be loaded")
+			}
+
+			recorder := httptest.NewRecorder()
+			req, err := http.NewRequestWithContext(ctx, http.MethodGet, "http://localhost", nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			req.Header.Set("X-Forwarded-For", "198.51.100.7")
+			handler.ServeHTTP(recorder, req)
+
+			if recorder.Code != http.StatusForbidden {
+				t.Errorf("got status code %d, want %d", recorder.Code, http.StatusForbidden)
+			}
+			if !strings.Contains(buf.String(), "credential stuffing, login endpoint") {
+				t.Errorf("expected the entry reason in the denial log, got %q", buf.String())
+			}
+		})
+	}
+}
+
+func TestSimpleBlocklist_TemporaryBan(t *testing.T) {
+	expires := time.Now().Add(500 * time.Millisecond).UTC().Format(time.RFC3339Nano)
+
+	cfg := simpleblocklist.CreateConfig()
+	cfg.BlacklistPath = createBlacklistFile(t, `[
+  {"cidr": "192.0.2.0/24", "reason": "temporary ban", "expires": "`+expires+`"},
+  {"cidr": "198.51.100.0/24"}
+]`)
+	cfg.BlacklistFormat = "json"
+
+	ctx := context.Background()
+	next := http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		rw.WriteHeader(http.StatusOK)
+	})
+
+	handler, err := simpleblocklist.New(ctx, next, cfg, "simpleblocklist")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	serve := func(ip string) int {
+		recorder := httptest.NewRecorder()
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, "http://localhost", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("X-Forwarded-For", ip)
+
+		handler.ServeHTTP(recorder, req)
+		return recorder.Code
+	}
+
+	if code := serve("192.0.2.1"); code != http.StatusForbidden {
+		t.Fatalf("before expiry: got status code %d, want %d", code, http.StatusForbidden)
+	}
+
+	time.Sleep(600 * time.Millisecond)
+
+	if code := serve("192.0.2.1"); code != http.StatusOK {
+		t.Errorf("after expiry: got status code %d, want %d", code, http.StatusOK)
+	}
+	if code := serve("198.51.100.1"); code != http.StatusForbidden {
+		t.Errorf("permanent entry: got status code %d, want %d", code, http.StatusForbidden)
+	}
+}
+
+func TestSimpleBlocklist_PermanentBanWinsOverExpiry(t *testing.T) {
+	expiring := func() string {
+		return "198.51.100.0/24 # expires:" + time.Now().Add(300*time.Millisecond).UTC().Format(time.RFC3339Nano) + "\n"
+	}
+
+	tests := []struct {
+		desc   string
+		update func(cfg *simpleblocklist.Config)
+	}{
+		{
+			desc: "permanent file first",
+			update: func(cfg *simpleblocklist.Config) {
+				cfg.BlacklistPaths = []string{createBlacklistFile(t, "198.51.100.0/24\n"), createBlacklistFile(t, expiring())}
+			},
+		},
+		{
+			desc: "expiring file first",
+			update: func(cfg *simpleblocklist.Config) {
+				cfg.BlacklistPaths = []string{createBlacklistFile(t, expiring()), createBlacklistFile(t, "198.51.100.0/24\n")}
+			},
+		},
+		{
+			desc: "permanent line first in one file",
+			update: func(cfg *simpleblocklist.Config) {
+				cfg.BlacklistPath = createBlacklistFile(t, "198.51.100.0/24\n"+expiring())
+			},
+		},
+		{
+			desc: "expiring line first in one file",
+			update: func(cfg *simpleblocklist.Config) {
+				cfg.BlacklistPath = createBlacklistFile(t, expiring()+"198.51.100.0/24\n")
+			},
+		},
+		{
+			desc: "permanent entry from the configuration",
+			update: func(cfg *simpleblocklist.Config) {
+				cfg.BlacklistPath = createBlacklistFile(t, expiring())
+				cfg.BlacklistedIPs = []string{"198.51.100.0/24"}
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			cfg := simpleblocklist.CreateConfig()
+			test.update(cfg)
+
+			next := http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {})
+
+			handler, err := simpleblocklist.New(context.Background(), next, cfg, "simpleblocklist")
+			if err != nil {
+				t.Fatal(err)
+			}
+			blocklist := handler.(*simpleblocklist.SimpleBlocklist)
+
+			time.Sleep(400 * time.Millisecond)
+
+			if blocked, _ := blocklist.IsBlocked(net.ParseIP("198.51.100.7")); !blocked {
+				t.Error("expected the permanent ban to outlive the expiring one")
+			}
+		})
+	}
+}
+
+func TestSimpleBlocklist_InlineExpiry(t *testing.T) {
+	content := `192.0.2.0/24 # expires:2001-01-01T00:00:00Z
+198.51.100.0/24  # expires:2999-01-01T00:00:00Z
+203.0.113.1 # permanent, no expiry
+203.0.113.2 # expires:next-week
+`
+
+	tests := []struct {
+		desc     string
+		strict   bool
+		wantErr  bool
+		statuses map[string]int
+	}{
+		{
+			desc: "lenient",
+			statuses: map[string]int{
+				"192.0.2.1":    200,
+				"198.51.100.7": 403,
+				"203.0.113.1":  403,
+				"203.0.113.2":  200,
+			},
+		},
+		{
+			desc:    "strict rejects an invalid expiry",
+			strict:  true,
+			wantErr: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			var buf bytes.Buffer
+			defer simpleblocklist.SetLogOutput(&buf)()
+
+			cfg := simpleblocklist.CreateConfig()
+			cfg.BlacklistPath = createBlacklistFile(t, content)
+			cfg.StrictParsing = test.strict
+
+			ctx := context.Background()
+			next := http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+				rw.WriteHeader(http.StatusOK)
+			})
+
+			handler, err := simpleblocklist.New(ctx, next, cfg, "simpleblocklist")
+			if test.wantErr {
+				if err == nil || !strings.Contains(err.Error(), `invalid expiry "next-week"`) {
+					t.Fatalf("expected an invalid expiry error, got %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			for ip, expectedStatus := range test.statuses {
+				recorder := httptest.NewRecorder()
+				req, err := http.NewRequestWithContext(ctx, http.MethodGet, "http://localhost", nil)
+				if err != nil {
+					t.Fatal(err)
+				}
+				req.Header.Set("X-Forwarded-For", ip)
+
+				handler.ServeHTTP(recorder, req)
+
+				if recorder.Code != expectedStatus {
+					t.Errorf("%s: got status code %d, want %d", ip, recorder.Code, expectedStatus)
+				}
+			}
+
+			if !strings.Contains(buf.String(), "Ignored 1 expired entries") {
+				t.Errorf("expected the expired entry to be logged, got %q", buf.String())
+			}
+		})
+	}
+}
+
+func TestSimpleBlocklist_BlockScoreThreshold(t *testing.T) {
+	blacklistPath := createBlacklistFile(t, `# feed with confidence scores
+192.0.2.1 90
+192.0.2.2 50
+192.0.2.3 10
+198.51.100.0/24
+203.0.113.1 not-a-score
+`)
+
+	tests := []struct {
+		desc      string
+		threshold int
+		expected  map[string]int
+	}{
+		{
+			desc:      "no threshold",
+			threshold: 0,
+			expected:  map[string]int{"192.0.2.1": 403, "192.0.2.2": 403, "192.0.2.3": 403, "198.51.100.1": 403, "203.0.113.1": 200},
+		},
+		{
+			desc:      "threshold includes equal scores",
+			threshold: 50,
+			expected:  map[string]int{"192.0.2.1": 403, "192.0.2.2": 403, "192.0.2.3": 200, "198.51.100.1": 403},
+		},
+		{
+			desc:      "threshold above every score",
+			threshold: 100,
+			expected:  map[string]int{"192.0.2.1": 200, "192.0.2.2": 200, "192.0.2.3": 200, "198.51.100.1": 403},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			cfg := simpleblocklist.CreateConfig()
+			cfg.BlacklistPath = blacklistPath
+			cfg.BlacklistFormat = "scored"
+			cfg.BlockScoreThreshold = test.threshold
+
+			ctx := context.Background()
+			next := http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+				rw.WriteHeader(http.StatusOK)
+			})
+
+			handler, err := simpleblocklist.New(ctx, next, cfg, "simpleblocklist")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			for ip, expectedStatus := range test.expected {
+				recorder := httptest.NewRecorder()
+				req, err := http.NewRequestWithContext(ctx, http.MethodGet, "http://localhost", nil)
+				if err != nil {
+					t.Fatal(err)
+				}
+				req.Header.Set("X-Forwarded-For", ip)
+
+				handler.ServeHTTP(recorder, req)
+
+				if recorder.Code != expectedStatus {
+					t.Errorf("%s: got status code %d, want %d", ip, recorder.Code, expectedStatus)
+				}
+			}
+		})
+	}
+
+	t.Run("invalid score in strict mode", func(t *testing.T) {
+		cfg := simpleblocklist.CreateConfig()
+		cfg.BlacklistPath = blacklistPath
+		cfg.BlacklistFormat = "scored"
+		cfg.StrictParsing = true
+
+		next := http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {})
+
+		_, err := simpleblocklist.New(context.Background(), next, cfg, "simpleblocklist")
+		if err == nil || !strings.Contains(err.Error(), "line 6") {
+			t.Errorf("got error %v, want an error naming line 6", err)
+		}
+	})
+}
+
+func TestSimpleBlocklist_InvalidBlacklistFormat(t *testing.T) {
+	cfg := simpleblocklist.CreateConfig()
+	cfg.BlacklistPath = createBlacklistFile(t, "192.0.2.1\n")
+	cfg.BlacklistFormat = "iptables"
+
+	ctx := context.Background()
+	next := http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {})
+
+	if _, err := simpleblocklist.New(ctx, next, cfg, "simpleblocklist"); err == nil {
+		t.Error("expected error for an invalid blacklist format")
+	}
+}
+
+func TestSimpleBlocklist_BlacklistExceptions(t *testing.T) {
+	tests := []struct {
+		desc           string
+		xff            string
+		expectedStatus int
+	}{
+		{desc: "excepted host inside blocked range", xff: "198.51.100.5", expectedStatus: 200},
+		{desc: "excepted network inside blocked range", xff: "198.51.100.130", expectedStatus: 200},
+		{desc: "other host inside blocked range", xff: "198.51.100.6", expectedStatus: 403},
+		{desc: "excepted host without matching block", xff: "203.0.113.5", expectedStatus: 200},
+	}
+
+	cfg := simpleblocklist.CreateConfig()
+	cfg.BlacklistPath = createBlacklistFile(t, `!198.51.100.5
+198.51.100.0/24
+!198.51.100.128/25 # exceptions may also be networks
+!203.0.113.5
+`)
+
+	ctx := context.Background()
+	next := http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		rw.WriteHeader(http.StatusOK)
+	})
+
+	handler, err := simpleblocklist.New(ctx, next, cfg, "simpleblocklist")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			recorder := httptest.NewRecorder()
+			req, err := http.NewRequestWithContext(ctx, http.MethodGet, "http://localhost", nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			req.Header.Set("X-Forwarded-For", test.xff)
+
+			handler.ServeHTTP(recorder, req)
+
+			if recorder.Code != test.expectedStatus {
+				t.Errorf("got status code %d, want %d", recorder.Code, test.expectedStatus)
+			}
+		})
+	}
+}
+
+func TestSimpleBlocklist_BlacklistPathFromEnv(t *testing.T) {
+	t.Setenv("SIMPLEBLOCKLIST_TEST_FILE", createBlacklistFile(t, "192.0.2.1\n"))
+
+	cfg := simpleblocklist.CreateConfig()
+	cfg.BlacklistPath = "${SIMPLEBLOCKLIST_TEST_FILE}"
+
+	ctx := context.Background()
+	next := http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		rw.WriteHeader(http.StatusOK)
+	})
+
+	handler, err := simpleblocklist.New(ctx, next, cfg, "simpleblocklist")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	recorder := httptest.NewRecorder()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, "http://localhost", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("X-Forwarded-For", "192.0.2.1")
+
+	handler.ServeHTTP(recorder, req)
+
+	if recorder.Code != http.StatusForbidden {
+		t.Errorf("got status code %d, want %d", recorder.Code, http.StatusForbidden)
+	}
+}
+
+func TestSimpleBlocklist_BlacklistPathFromUnsetEnv(t *testing.T) {
+	t.Setenv("SIMPLEBLOCKLIST_TEST_FILE", "")
+
+	cfg := simpleblocklist.CreateConfig()
+	cfg.BlacklistPath = "${SIMPLEBLOCKLIST_TEST_FILE}"
+
+	next := http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {})
+
+	_, err := simpleblocklist.New(context.Background(), next, cfg, "simpleblocklist")
+	if err == nil || !strings.Contains(err.Error(), "empty") {
+		t.Errorf("expected an empty path error, got %v", err)
+	}
+}
+
+func TestSimpleBlocklist_WildcardAndRangeEntries(t *testing.T) {
+	cfg := simpleblocklist.CreateConfig()
+	cfg.BlacklistPath = createBlacklistFile(t, `198.51.100.*
+192.0.2.1-192.0.2.50
+2001:db8::ff-2001:db8::100
+`)
+	cfg.StrictParsing = true
+
+	next := http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {})
+
+	handler, err := simpleblocklist.New(context.Background(), next, cfg, "simpleblocklist")
+	if err != nil {
+		t.Fatal(err)
+	}
+	blocklist := handler.(*simpleblocklist.SimpleBlocklist)
+
+	tests := []struct {
+		ip              string
+		expectedNetwork string
+	}{
+		{ip: "198.51.100.0", expectedNetwork: "198.51.100.0/24"},
+		{ip: "198.51.100.255", expectedNetwork: "198.51.100.0/24"},
+		{ip: "198.51.101.0"},
+		{ip: "192.0.2.0"},
+		{ip: "192.0.2.1", expectedNetwork: "192.0.2.1/32"},
+		{ip: "192.0.2.3", expectedNetwork: "192.0.2.2/31"},
+		{ip: "192.0.2.7", expectedNetwork: "192.0.2.4/30"},
+		{ip: "192.0.2.15", expectedNetwork: "192.0.2.8/29"},
+		{ip: "192.0.2.31", expectedNetwork: "192.0.2.16/28"},
+		{ip: "192.0.2.47", expectedNetwork: "192.0.2.32/28"},
+		{ip: "192.0.2.49", expectedNetwork: "192.0.2.48/31"},
+		{ip: "192.0.2.50", expectedNetwork: "192.0.2.50/32"},
+		{ip: "192.0.2.51"},
+		{ip: "2001:db8::fe"},
+		{ip: "2001:db8::ff", expectedNetwork: "2001:db8::ff/128"},
+		{ip: "2001:db8::100", expectedNetwork: "2001:db8::100/128"},
+		{ip: "2001:db8::101"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.ip, func(t *testing.T) {
+			_, network := blocklist.IsBlocked(net.ParseIP(test.ip))
+
+			var matched string
+			if network != nil {
+				matched = network.String()
+			}
+			if matched != test.expectedNetwork {
+				t.Errorf("got network %q, want %q", matched, test.expectedNetwork)
+			}
+		})
+	}
+}
+
+func TestSimpleBlocklist_AmbiguousEntriesStrict(t *testing.T) {
+	for _, entry := range []string{
+		"192.0.*",
+		"192.*.2.1",
+		"192.0.2.1-50",
+		"192.0.2.50-192.0.2.1",
+		"192.0.2.1-2001:db8::1",
+	} {
+		t.Run(entry, func(t *testing.T) {
+			cfg := simpleblocklist.CreateConfig()
+			cfg.BlacklistPath = createBlacklistFile(t, entry+"\n")
+			cfg.StrictParsing = true
+
+			next := http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {})
+
+			if _, err := simpleblocklist.New(context.Background(), next, cfg, "simpleblocklist"); err == nil {
+				t.Errorf("expected error for ambiguous entry %q", entry)
+			}
+		})
+	}
+}
+
+func TestSimpleBlocklist_MaxBlacklistEntries(t *testing.T) {
+	tests := []struct {
+		desc    string
+		files   []string
+		wantErr bool
+	}{
+		{
+			desc:  "within the limit",
+			files: []string{"192.0.2.1\n192.0.2.2\n192.0.2.3\n"},
+		},
+		{
+			desc:    "single file over the limit",
+			files:   []string{"192.0.2.1\n192.0.2.2\n192.0.2.3\n192.0.2.4\n"},
+			wantErr: true,
+		},
+		{
+			desc:    "files over the limit combined",
+			files:   []string{"192.0.2.1\n192.0.2.2\n", "198.51.100.1\n198.51.100.2\n"},
+			wantErr: true,
+		},
+		{
+			desc:    "range over the limit",
+			files:   []string{"192.0.2.1-192.0.2.50\n"},
+			wantErr: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			cfg := simpleblocklist.CreateConfig()
+			for _, content := range test.files {
+				cfg.BlacklistPaths = append(cfg.BlacklistPaths, createBlacklistFile(t, content))
+			}
+			cfg.MaxBlacklistEntries = 3
+
+			next := http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {})
+
+			_, err := simpleblocklist.New(context.Background(), next, cfg, "simpleblocklist")
+			if !test.wantErr {
+				if err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), "maximum of 3 entries") {
+				t.Errorf("expected a maximum entries error, got %v", err)
+			}
+		})
+	}
+}
+
+func TestSimpleBlocklist_BracketedIPv6Entries(t *testing.T) {
+	cfg := simpleblocklist.CreateConfig()
+	cfg.BlacklistPath = createBlacklistFile(t, `[2001:DB8::1]
+[2001:db8::2]:443
+[2001:db8:1::]/48
+[2001:db8::3
+`)
+
+	next := http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {})
+
+	handler, err := simpleblocklist.New(context.Background(), next, cfg, "simpleblocklist")
+	if err != nil {
+		t.Fatal(err)
+	}
+	blocklist := handler.(*simpleblocklist.SimpleBlocklist)
+
+	tests := []struct {
+		desc            string
+		ip              string
+		expectedBlocked bool
+	}{
+		{desc: "bracketed uppercase entry", ip: "2001:db8::1", expectedBlocked: true},
+		{desc: "bracketed entry with port", ip: "2001:db8::2", expectedBlocked: true},
+		{desc: "bracketed network", ip: "2001:db8:1::42", expectedBlocked: true},
+		{desc: "unbalanced bracket is skipped", ip: "2001:db8::3", expectedBlocked: false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			if blocked, _ := blocklist.IsBlocked(net.ParseIP(test.ip)); blocked != test.expectedBlocked {
+				t.Errorf("got blocked %t, want %t", blocked, test.expectedBlocked)
+			}
+		})
+	}
+}
+
+func TestSimpleBlocklist_IncludeDirective(t *testing.T) {
+	dir := t.TempDir()
+	for name, content := range map[string]string{
+		"main.txt":                "192.0.2.1\ninclude feeds/level1.txt\n",
+		"feeds/level1.txt":        "198.51.100.0/24\ninclude " + filepath.Join(dir, "feeds/level2/level2.txt") + "\n",
+		"feeds/level2/level2.txt": "203.0.113.0/24\n#include ../../extra.txt\n",
+		"extra.txt":               "# include is only a comment with a space\n192.0.2.99\n",
+	} {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	cfg := simpleblocklist.CreateConfig()
+	cfg.BlacklistPath = filepath.Join(dir, "main.txt")
+
+	next := http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {})
+
+	handler, err := simpleblocklist.New(context.Background(), next, cfg, "simpleblocklist")
+	if err != nil {
+		t.Fatal(err)
+	}
+	blocklist := handler.(*simpleblocklist.SimpleBlocklist)
+
+	for _, ip := range []string{"192.0.2.1", "198.51.100.7", "203.0.113.7", "192.0.2.99"} {
+		if blocked, _ := blocklist.IsBlocked(net.ParseIP(ip)); !blocked {
+			t.Errorf("expected %s to be blocked", ip)
+		}
+	}
+}
+
+func TestSimpleBlocklist_IncludeErrors(t *testing.T) {
+	tests := []struct {
+		desc    string
+		files   map[string]string
+		strict  bool
+		wantErr string
+	}{
+		{
+			desc:    "self-referential include",
+			files:   map[string]string{"main.txt": "192.0.2.1\ninclude main.txt\n"},
+			wantErr: "include cycle",
+		},
+		{
+			desc: "indirect include cycle",
+			files: map[string]string{
+				"main.txt":  "include other.txt\n",
+				"other.txt": "include ./main.txt\n",
+			},
+			wantErr: "include cycle",
+		},
+		{
+			desc: "include cycle through the #include directive",
+			files: map[string]string{
+				"main.txt":  "#include other.txt\n",
+				"other.txt": "include main.txt\n",
+			},
+			wantErr: "include cycle",
+		},
+		{
+			desc:    "missing include in strict mode",
+			files:   map[string]string{"main.txt": "192.0.2.1\ninclude missing.txt\n"},
+			strict:  true,
+			wantErr: "missing.txt",
+		},
+		{
+			desc:  "missing include in lenient mode",
+			files: map[string]string{"main.txt": "192.0.2.1\ninclude missing.txt\n"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			dir := t.TempDir()
+			for name, content := range test.files {
+				if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o600); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			cfg := simpleblocklist.CreateConfig()
+			cfg.BlacklistPath = filepath.Join(dir, "main.txt")
+			cfg.StrictParsing = test.strict
+
+			next := http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {})
+
+			_, err := simpleblocklist.New(context.Background(), next, cfg, "simpleblocklist")
+			if test.wantErr == "" {
+				if err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), test.wantErr) {
+				t.Errorf("expected an error containing %q, got %v", test.wantErr, err)
+			}
+		})
+	}
+}
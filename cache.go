@@ -0,0 +1,74 @@
+package simpleblocklist
+
+import (
+	"container/list"
+	"sync"
+)
+
+// decisionCache a bounded, concurrency-safe LRU cache of block decisions keyed by IP.
+type decisionCache struct {
+	mu      sync.Mutex
+	size    int
+	entries map[string]*list.Element
+	order   *list.List
+}
+
+type decisionCacheEntry struct {
+	ip       string
+	decision *blockDecision
+}
+
+// newDecisionCache returns the decision cache of config, or nil if caching is disabled.
+func newDecisionCache(config *Config, logger *logger) *decisionCache {
+	size := config.DecisionCacheSize
+	if size <= 0 {
+		return nil
+	}
+
+	logger.infof(nil, "Decision cache size: %d", size)
+	return &decisionCache{
+		size:    size,
+		entries: make(map[string]*list.Element, size),
+		order:   list.New(),
+	}
+}
+
+// get returns the cached decision for ip. A nil decision means the IP is not blocked.
+func (c *decisionCache) get(ip string) (*blockDecision, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	element, ok := c.entries[ip]
+	if !ok {
+		return nil, false
+	}
+	c.order.MoveToFront(element)
+	return element.Value.(*decisionCacheEntry).decision, true
+}
+
+func (c *decisionCache) add(ip string, decision *blockDecision) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if element, ok := c.entries[ip]; ok {
+		element.Value.(*decisionCacheEntry).decision = decision
+		c.order.MoveToFront(element)
+		return
+	}
+
+	c.entries[ip] = c.order.PushFront(&decisionCacheEntry{ip: ip, decision: decision})
+	if c.order.Len() > c.size {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*decisionCacheEntry).ip)
+	}
+}
+
+// purge removes all cached decisions.
+func (c *decisionCache) purge() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.entries = make(map[string]*list.Element, c.size)
+	c.order.Init()
+}
@@ -0,0 +1,151 @@
+package simpleblocklist_test
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/LucaNori/traefik-simpleblocklist"
+)
+
+func TestSimpleBlocklist_DecisionCacheInvalidatedOnReload(t *testing.T) {
+	blacklistPath := createBlacklistFile(t, "192.0.2.1\n")
+
+	cfg := simpleblocklist.CreateConfig()
+	cfg.BlacklistPath = blacklistPath
+	cfg.DecisionCacheSize = 16
+
+	ctx := context.Background()
+	next := http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		rw.WriteHeader(http.StatusOK)
+	})
+
+	handler, err := simpleblocklist.New(ctx, next, cfg, "simpleblocklist")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	serve := func(ip string) int {
+		recorder := httptest.NewRecorder()
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, "http://localhost", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("X-Forwarded-For", ip)
+		handler.ServeHTTP(recorder, req)
+		return recorder.Code
+	}
+
+	// Cache a decision for both IPs.
+	if code := serve("192.0.2.1"); code != 403 {
+		t.Fatalf("got status code %d, want 403", code)
+	}
+	if code := serve("192.0.2.2"); code != 200 {
+		t.Fatalf("got status code %d, want 200", code)
+	}
+
+	if err := os.WriteFile(blacklistPath, []byte("192.0.2.2\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := simpleblocklist.Reload(handler); err != nil {
+		t.Fatal(err)
+	}
+
+	if code := serve("192.0.2.1"); code != 200 {
+		t.Errorf("got status code %d for removed IP, want 200", code)
+	}
+	if code := serve("192.0.2.2"); code != 403 {
+		t.Errorf("got status code %d for added IP, want 403", code)
+	}
+}
+
+func TestSimpleBlocklist_DecisionCacheEviction(t *testing.T) {
+	cfg := simpleblocklist.CreateConfig()
+	cfg.BlacklistPath = createBlacklistFile(t, "198.51.100.0/24\n")
+	cfg.DecisionCacheSize = 2
+
+	ctx := context.Background()
+	next := http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		rw.WriteHeader(http.StatusOK)
+	})
+
+	handler, err := simpleblocklist.New(ctx, next, cfg, "simpleblocklist")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// More distinct IPs than the cache holds must still be decided correctly.
+	for i := 0; i < 3; i++ {
+		for _, ip := range []string{"198.51.100.1", "198.51.100.2", "203.0.113.1", "203.0.113.2"} {
+			recorder := httptest.NewRecorder()
+			req, err := http.NewRequestWithContext(ctx, http.MethodGet, "http://localhost", nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			req.Header.Set("X-Forwarded-For", ip)
+
+			handler.ServeHTTP(recorder, req)
+
+			want := 200
+			if ip[:3] == "198" {
+				want = 403
+			}
+			if recorder.Code != want {
+				t.Errorf("%s: got status code %d, want %d", ip, recorder.Code, want)
+			}
+		}
+	}
+}
+
+func BenchmarkServeHTTP_RepeatedIPs(b *testing.B) {
+	var content strings.Builder
+	for i := 0; i < 100000; i++ {
+		fmt.Fprintf(&content, "100.%d.%d.0/24\n", i/256%256, i%256)
+	}
+	blacklistPath := filepath.Join(b.TempDir(), "blacklist.txt")
+	if err := os.WriteFile(blacklistPath, []byte(content.String()), 0o600); err != nil {
+		b.Fatal(err)
+	}
+
+	defer simpleblocklist.SetLogOutput(io.Discard)()
+
+	for _, size := range []int{0, 1024} {
+		b.Run(fmt.Sprintf("cache=%d", size), func(b *testing.B) {
+			cfg := simpleblocklist.CreateConfig()
+			cfg.BlacklistPath = blacklistPath
+			cfg.GeoIPDatabasePath = "testdata/GeoLite2-Country-Test.mmdb"
+			cfg.BlockedCountries = []string{"SE"}
+			cfg.DecisionCacheSize = size
+
+			ctx := context.Background()
+			next := http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {})
+
+			handler, err := simpleblocklist.New(ctx, next, cfg, "simpleblocklist")
+			if err != nil {
+				b.Fatal(err)
+			}
+
+			requests := make([]*http.Request, 0, 4)
+			for _, ip := range []string{"81.2.69.160", "216.160.83.56", "100.1.2.3", "2001:db8:1::1"} {
+				req, err := http.NewRequestWithContext(ctx, http.MethodGet, "http://localhost", nil)
+				if err != nil {
+					b.Fatal(err)
+				}
+				req.Header.Set("X-Forwarded-For", ip)
+				requests = append(requests, req)
+			}
+			recorder := httptest.NewRecorder()
+
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				handler.ServeHTTP(recorder, requests[i%len(requests)])
+			}
+		})
+	}
+}
@@ -0,0 +1,86 @@
+package simpleblocklist
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	challengeCookieName = "simpleblocklist_challenge"
+	// challengeCookieTTL how long a passed challenge lets an IP through.
+	challengeCookieTTL = time.Hour
+)
+
+// challengePage reloads the page once the challenge cookie is set, which clients that don't keep
+// cookies, such as simple bots, never get past.
+const challengePage = `<!DOCTYPE html>
+<html>
+<head><meta charset="utf-8"><title>Checking your browser</title></head>
+<body>
+<p>Checking your browser, you will be redirected shortly.</p>
+<script>window.location.reload();</script>
+<noscript><p>Please enable cookies and reload this page.</p></noscript>
+</body>
+</html>
+`
+
+// challengeSignature returns the HMAC of ip and expires, so a cookie can't be forged, extended or
+// reused from another IP.
+func (a *SimpleBlocklist) challengeSignature(ip string, expires int64) string {
+	mac := hmac.New(sha256.New, a.challengeSecret)
+	mac.Write([]byte(ip + "|" + strconv.FormatInt(expires, 10)))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// passedChallenge reports whether req carries an unexpired challenge cookie issued to ip.
+func (a *SimpleBlocklist) passedChallenge(req *http.Request, ip string) bool {
+	cookie, err := req.Cookie(challengeCookieName)
+	if err != nil {
+		return false
+	}
+
+	// "<expires unix time>.<signature>"
+	parts := strings.SplitN(cookie.Value, ".", 2)
+	if len(parts) != 2 {
+		return false
+	}
+	expires, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil || time.Now().Unix() >= expires {
+		return false
+	}
+
+	return hmac.Equal([]byte(parts[1]), []byte(a.challengeSignature(ip, expires)))
+}
+
+// challenge answers a blocked request from ip with the challenge page and a signed cookie that lets
+// the retried request through.
+func (a *SimpleBlocklist) challenge(rw http.ResponseWriter, req *http.Request, ip string, decision *blockDecision) {
+	a.logger.infof(logFields{"ip": a.logIP(ip), "action": "challenge", "matched": decision.matched},
+		"%s: request challenged [%s] matched [%s] - %s", a.name, a.logIP(ip), decision.matched, decision.reason)
+
+	expires := time.Now().Add(challengeCookieTTL).Unix()
+	http.SetCookie(rw, &http.Cookie{
+		Name:     challengeCookieName,
+		Value:    strconv.FormatInt(expires, 10) + "." + a.challengeSignature(ip, expires),
+		Path:     "/",
+		MaxAge:   int(challengeCookieTTL / time.Second),
+		HttpOnly: true,
+		Secure:   req.TLS != nil,
+		SameSite: http.SameSiteLaxMode,
+	})
+
+	if a.debugHeaders {
+		rw.Header().Set(xBlockedReason, decision.code)
+	}
+	rw.Header().Set("Content-Type", "text/html; charset=utf-8")
+	rw.Header().Set("Cache-Control", "no-store")
+	rw.WriteHeader(a.httpStatusCodeDeniedRequest)
+	if _, err := rw.Write([]byte(challengePage)); err != nil {
+		a.logger.warnf(logFields{"ip": a.logIP(ip)}, "%s: failed to write the challenge page: %v", a.name, err)
+	}
+}
@@ -0,0 +1,131 @@
+package simpleblocklist_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/LucaNori/traefik-simpleblocklist"
+)
+
+func TestSimpleBlocklist_ChallengeMode(t *testing.T) {
+	cfg := simpleblocklist.CreateConfig()
+	cfg.BlacklistPath = createBlacklistFile(t, "192.0.2.1\n192.0.2.2\n")
+	cfg.ChallengeMode = true
+	cfg.ChallengeSecret = "test-secret"
+
+	ctx := context.Background()
+	next := http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		rw.WriteHeader(http.StatusOK)
+	})
+
+	handler, err := simpleblocklist.New(ctx, next, cfg, "simpleblocklist")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	serve := func(remoteAddr string, cookies ...*http.Cookie) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "http://localhost/", nil)
+		req.RemoteAddr = remoteAddr
+		for _, cookie := range cookies {
+			req.AddCookie(cookie)
+		}
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, req)
+		return recorder
+	}
+
+	challenged := serve("192.0.2.1:1234")
+	if challenged.Code != http.StatusForbidden {
+		t.Fatalf("challenge: expected status 403, got %d", challenged.Code)
+	}
+	if !strings.Contains(challenged.Body.String(), "window.location.reload()") {
+		t.Errorf("challenge: expected the challenge page, got %q", challenged.Body.String())
+	}
+	cookies := challenged.Result().Cookies()
+	if len(cookies) != 1 || !cookies[0].HttpOnly {
+		t.Fatalf("challenge: expected one HttpOnly cookie, got %v", cookies)
+	}
+	cookie := cookies[0]
+
+	tampered := *cookie
+	tampered.Value = cookie.Value[:len(cookie.Value)-1] + "0"
+	if tampered.Value == cookie.Value {
+		tampered.Value = cookie.Value[:len(cookie.Value)-1] + "1"
+	}
+	extended := *cookie
+	extended.Value = "9999999999" + cookie.Value[strings.Index(cookie.Value, "."):]
+
+	tests := []struct {
+		desc           string
+		remoteAddr     string
+		cookie         *http.Cookie
+		expectedStatus int
+		expectedCookie bool
+	}{
+		{
+			desc:           "retry with signed cookie",
+			remoteAddr:     "192.0.2.1:1234",
+			cookie:         cookie,
+			expectedStatus: 200,
+		},
+		{
+			desc:           "cookie issued to another IP",
+			remoteAddr:     "192.0.2.2:1234",
+			cookie:         cookie,
+			expectedStatus: 403,
+			expectedCookie: true,
+		},
+		{
+			desc:           "tampered signature",
+			remoteAddr:     "192.0.2.1:1234",
+			cookie:         &tampered,
+			expectedStatus: 403,
+			expectedCookie: true,
+		},
+		{
+			desc:           "extended expiry",
+			remoteAddr:     "192.0.2.1:1234",
+			cookie:         &extended,
+			expectedStatus: 403,
+			expectedCookie: true,
+		},
+		{
+			desc:           "not blacklisted",
+			remoteAddr:     "203.0.113.1:1234",
+			expectedStatus: 200,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			var cookies []*http.Cookie
+			if test.cookie != nil {
+				cookies = append(cookies, test.cookie)
+			}
+			recorder := serve(test.remoteAddr, cookies...)
+
+			if recorder.Code != test.expectedStatus {
+				t.Errorf("expected status %d, got %d", test.expectedStatus, recorder.Code)
+			}
+			if got := len(recorder.Result().Cookies()) != 0; got != test.expectedCookie {
+				t.Errorf("expected challenge cookie %t, got %t", test.expectedCookie, got)
+			}
+		})
+	}
+}
+
+func TestSimpleBlocklist_ChallengeModeRequiresSecret(t *testing.T) {
+	cfg := simpleblocklist.CreateConfig()
+	cfg.BlacklistPath = createBlacklistFile(t, "192.0.2.1\n")
+	cfg.ChallengeMode = true
+
+	next := http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {})
+
+	_, err := simpleblocklist.New(context.Background(), next, cfg, "simpleblocklist")
+	if err == nil || !strings.Contains(err.Error(), "challenge mode requires a challenge secret") {
+		t.Fatalf("expected a missing challenge secret error, got %v", err)
+	}
+}
@@ -0,0 +1,47 @@
+package simpleblocklist
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+)
+
+// checkResult the JSON payload served on the check path.
+type checkResult struct {
+	IP             string `json:"ip"`
+	Blocked        bool   `json:"blocked"`
+	Matched        string `json:"matched,omitempty"`
+	MatchedNetwork string `json:"matched_network,omitempty"`
+	Reason         string `json:"reason,omitempty"`
+}
+
+// serveCheck answers whether the IP of the "ip" query parameter would be blocked, so other systems
+// can consult the blocklist. Only admin callers are answered, since the response reveals the lists.
+func (a *SimpleBlocklist) serveCheck(rw http.ResponseWriter, req *http.Request) {
+	if !a.isAdminCaller(req) {
+		a.writeJSONError(rw, http.StatusForbidden, "the check path is only available to the admin allowed IPs")
+		return
+	}
+
+	query := strings.TrimSpace(req.URL.Query().Get("ip"))
+	ip := parseIP(query)
+	if ip == nil {
+		a.writeJSONError(rw, http.StatusBadRequest, "the ip query parameter must be an IP address")
+		return
+	}
+
+	result := checkResult{IP: ip.String()}
+	if decision := a.check(ip); decision != nil && !decision.logOnly {
+		result.Blocked = true
+		result.Matched = decision.matched
+		result.Reason = decision.reason
+		if decision.network != nil {
+			result.MatchedNetwork = decision.network.String()
+		}
+	}
+
+	rw.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(rw).Encode(result); err != nil {
+		a.logger.warnf(nil, "Failed to write check result: %v", err)
+	}
+}
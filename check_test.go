@@ -0,0 +1,104 @@
+package simpleblocklist_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/LucaNori/traefik-simpleblocklist"
+)
+
+func TestSimpleBlocklist_CheckPath(t *testing.T) {
+	cfg := simpleblocklist.CreateConfig()
+	cfg.BlacklistPath = createBlacklistFile(t, "192.0.2.0/24\n")
+	cfg.CheckPath = "/_blocklist/check"
+	cfg.AdminAllowedIPs = []string{"127.0.0.1", "10.0.0.0/24"}
+
+	ctx := context.Background()
+	next := http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		rw.WriteHeader(http.StatusTeapot)
+	})
+
+	handler, err := simpleblocklist.New(ctx, next, cfg, "simpleblocklist")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		desc                   string
+		query                  string
+		remoteAddr             string
+		forwardedFor           string
+		expectedStatus         int
+		expectedBlocked        bool
+		expectedMatchedNetwork string
+	}{
+		{
+			desc:                   "blocked IP",
+			query:                  "?ip=192.0.2.7",
+			remoteAddr:             "127.0.0.1:1234",
+			expectedStatus:         200,
+			expectedBlocked:        true,
+			expectedMatchedNetwork: "192.0.2.0/24",
+		},
+		{
+			desc:           "allowed IP",
+			query:          "?ip=198.51.100.1",
+			remoteAddr:     "10.0.0.5:1234",
+			expectedStatus: 200,
+		},
+		{
+			desc:           "invalid IP",
+			query:          "?ip=not-an-ip",
+			remoteAddr:     "127.0.0.1:1234",
+			expectedStatus: 400,
+		},
+		{
+			desc:           "public caller",
+			query:          "?ip=192.0.2.7",
+			remoteAddr:     "203.0.113.1:1234",
+			expectedStatus: 403,
+		},
+		{
+			desc:           "public caller with a forged allowed header",
+			query:          "?ip=192.0.2.7",
+			remoteAddr:     "203.0.113.1:1234",
+			forwardedFor:   "127.0.0.1",
+			expectedStatus: 403,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			recorder := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodGet, "http://localhost/_blocklist/check"+test.query, nil)
+			req.RemoteAddr = test.remoteAddr
+			if test.forwardedFor != "" {
+				req.Header.Set("X-Forwarded-For", test.forwardedFor)
+			}
+
+			handler.ServeHTTP(recorder, req)
+
+			if recorder.Code != test.expectedStatus {
+				t.Fatalf("got status code %d, want %d", recorder.Code, test.expectedStatus)
+			}
+			if test.expectedStatus != http.StatusOK {
+				return
+			}
+
+			var result struct {
+				Blocked        bool   `json:"blocked"`
+				MatchedNetwork string `json:"matched_network"`
+			}
+			if err := json.Unmarshal(recorder.Body.Bytes(), &result); err != nil {
+				t.Fatal(err)
+			}
+			if result.Blocked != test.expectedBlocked || result.MatchedNetwork != test.expectedMatchedNetwork {
+				t.Errorf("got blocked %t, matched network %q, want %t, %q",
+					result.Blocked, result.MatchedNetwork, test.expectedBlocked, test.expectedMatchedNetwork)
+			}
+		})
+	}
+}
@@ -0,0 +1,94 @@
+package simpleblocklist
+
+import (
+	"bytes"
+	"net"
+	"sort"
+)
+
+// collapseNetworks returns networks with duplicates and networks covered by another one removed,
+// and with adjacent sibling networks merged into their supernet, repeatedly: "10.0.0.0/25" and
+// "10.0.0.128/25" become "10.0.0.0/24". The networks cover exactly the same addresses as before.
+// IPv4 and IPv6 networks are collapsed separately.
+func collapseNetworks(networks []*net.IPNet) []*net.IPNet {
+	var v4, v6 []*net.IPNet
+	for _, network := range networks {
+		if _, bits := network.Mask.Size(); bits == 8*net.IPv4len {
+			v4 = append(v4, network)
+		} else {
+			v6 = append(v6, network)
+		}
+	}
+	return append(collapseFamily(v4), collapseFamily(v6)...)
+}
+
+// collapseFamily collapses networks of a single address family, see collapseNetworks.
+func collapseFamily(networks []*net.IPNet) []*net.IPNet {
+	sort.Slice(networks, func(i, j int) bool {
+		if c := bytes.Compare(networks[i].IP, networks[j].IP); c != 0 {
+			return c < 0
+		}
+		ones, _ := networks[i].Mask.Size()
+		otherOnes, _ := networks[j].Mask.Size()
+		return ones < otherOnes
+	})
+
+	// Sorted by address then prefix length, a network can only be covered by the last one kept,
+	// and can only merge with it
+	var collapsed []*net.IPNet
+	for _, network := range networks {
+		if len(collapsed) > 0 && collapsed[len(collapsed)-1].Contains(network.IP) {
+			last, _ := collapsed[len(collapsed)-1].Mask.Size()
+			if ones, _ := network.Mask.Size(); ones >= last {
+				continue
+			}
+		}
+
+		collapsed = append(collapsed, network)
+		for len(collapsed) >= 2 {
+			parent := siblingsParent(collapsed[len(collapsed)-2], collapsed[len(collapsed)-1])
+			if parent == nil {
+				break
+			}
+			collapsed = append(collapsed[:len(collapsed)-2], parent)
+		}
+	}
+	return collapsed
+}
+
+// siblingsParent returns the supernet of a and b if they are the two halves of it, in that order,
+// or nil if they aren't.
+func siblingsParent(a, b *net.IPNet) *net.IPNet {
+	ones, bits := a.Mask.Size()
+	otherOnes, otherBits := b.Mask.Size()
+	if ones == 0 || ones != otherOnes || bits != otherBits || len(a.IP) != len(b.IP) {
+		return nil
+	}
+
+	mask := net.CIDRMask(ones-1, bits)
+	parent := a.IP.Mask(mask)
+	if !parent.Equal(a.IP) || !parent.Equal(b.IP.Mask(mask)) || a.IP.Equal(b.IP) {
+		return nil
+	}
+	return &net.IPNet{IP: parent, Mask: mask}
+}
+
+// collapse collapses the networks of r that have no annotation, see collapseNetworks. Annotated
+// networks are kept as they are, so their reason and expiry still apply to them alone.
+func (r *parseResult) collapse(logger *logger) {
+	before := len(r.networks)
+
+	var plain, annotated []*net.IPNet
+	for _, network := range r.networks {
+		if _, ok := r.annotations[network.String()]; ok {
+			annotated = append(annotated, network)
+		} else {
+			plain = append(plain, network)
+		}
+	}
+	r.networks = append(collapseNetworks(plain), annotated...)
+
+	if len(r.networks) != before {
+		logger.infof(logFields{"entries": len(r.networks)}, "Collapsed %d IPs/Networks into %d", before, len(r.networks))
+	}
+}
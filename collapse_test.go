@@ -0,0 +1,98 @@
+package simpleblocklist
+
+import (
+	"math/rand"
+	"net"
+	"reflect"
+	"testing"
+)
+
+func TestCollapseNetworks(t *testing.T) {
+	tests := []struct {
+		desc     string
+		networks []string
+		expected []string
+	}{
+		{
+			desc:     "siblings merge into their supernet",
+			networks: []string{"10.0.0.128/25", "10.0.0.0/25"},
+			expected: []string{"10.0.0.0/24"},
+		},
+		{
+			desc:     "merges repeat up the tree",
+			networks: []string{"10.0.0.0/25", "10.0.0.128/25", "10.0.1.0/24", "10.0.2.0/23"},
+			expected: []string{"10.0.0.0/22"},
+		},
+		{
+			desc:     "adjacent networks of different halves stay intact",
+			networks: []string{"10.0.0.128/25", "10.0.1.0/25"},
+			expected: []string{"10.0.0.128/25", "10.0.1.0/25"},
+		},
+		{
+			desc:     "non-contiguous networks stay intact",
+			networks: []string{"10.0.0.0/25", "10.0.2.0/25"},
+			expected: []string{"10.0.0.0/25", "10.0.2.0/25"},
+		},
+		{
+			desc:     "covered networks and duplicates are dropped",
+			networks: []string{"10.0.0.0/24", "10.0.0.7/32", "10.0.0.0/24", "10.0.0.128/25"},
+			expected: []string{"10.0.0.0/24"},
+		},
+		{
+			desc:     "families are collapsed separately",
+			networks: []string{"2001:db8::/33", "10.0.0.0/25", "2001:db8:8000::/33", "10.0.0.128/25"},
+			expected: []string{"10.0.0.0/24", "2001:db8::/32"},
+		},
+		{
+			desc:     "the two halves of the whole address space",
+			networks: []string{"0.0.0.0/1", "128.0.0.0/1"},
+			expected: []string{"0.0.0.0/0"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			var networks []*net.IPNet
+			for _, cidr := range test.networks {
+				_, network, err := net.ParseCIDR(cidr)
+				if err != nil {
+					t.Fatal(err)
+				}
+				networks = append(networks, network)
+			}
+
+			var got []string
+			for _, network := range collapseNetworks(networks) {
+				got = append(got, network.String())
+			}
+			if !reflect.DeepEqual(got, test.expected) {
+				t.Errorf("got %v, want %v", got, test.expected)
+			}
+		})
+	}
+}
+
+func TestCollapseNetworks_MatchesOriginal(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+
+	// Networks within 10.0.0.0/20 overlap and neighbour each other often enough to collapse.
+	var networks []*net.IPNet
+	for i := 0; i < 500; i++ {
+		ip := net.IPv4(10, 0, byte(rnd.Intn(16)), byte(rnd.Intn(256))).To4()
+		mask := net.CIDRMask(22+rnd.Intn(11), 32)
+		networks = append(networks, &net.IPNet{IP: ip.Mask(mask), Mask: mask})
+	}
+	original := append([]*net.IPNet{}, networks...)
+
+	collapsed := collapseNetworks(networks)
+	if len(collapsed) >= len(original) {
+		t.Fatalf("got %d networks, want fewer than %d", len(collapsed), len(original))
+	}
+
+	for i := 0; i < 1<<12; i++ {
+		ip := net.IPv4(10, 0, byte(i>>8), byte(i)).To4()
+		if got, want := linearLookup(collapsed, ip), linearLookup(original, ip); got != want {
+			t.Fatalf("collapsed lookup(%s) = %t, original = %t", ip, got, want)
+		}
+	}
+}
@@ -0,0 +1,342 @@
+package simpleblocklist
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+	"text/template"
+)
+
+// configProblems the problems found in a configuration.
+type configProblems []string
+
+func (p *configProblems) addf(format string, args ...interface{}) {
+	*p = append(*p, fmt.Sprintf(format, args...))
+}
+
+// Validate checks the configuration without loading any file, and reports every problem found
+// in a single error rather than stopping at the first one. Unset optional fields are valid, New
+// replaces them with their defaults.
+func (c *Config) Validate() error {
+	var problems configProblems
+	c.validateSources(&problems)
+	for i, list := range c.TieredLists {
+		problems = append(problems, list.validate(i)...)
+	}
+	c.validateEntries(&problems)
+	c.validateParsing(&problems)
+	c.validateDeniedStatus(&problems)
+	c.validateDeniedBody(&problems)
+	c.validateDeniedResponseFormat(&problems)
+	c.validateIPEvaluation(&problems)
+	c.validateTrustedProxies(&problems)
+	c.validateIPPrecedence(&problems)
+	c.validateRateLimit(&problems)
+	c.validateAdminPaths(&problems)
+
+	if len(problems) != 0 {
+		return fmt.Errorf("invalid configuration: %s", strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+// hasBlacklistSource reports whether a blacklist source is configured, or none is needed.
+func (c *Config) hasBlacklistSource() bool {
+	return len(c.BlacklistPath) != 0 || len(c.BlacklistPaths) != 0 || len(c.BlacklistDir) != 0 ||
+		len(c.BinaryBlacklistPath) != 0 || len(c.BlacklistInline) != 0 || len(c.BlacklistBase64) != 0 ||
+		len(c.BlacklistedIPs) != 0 || len(c.BlacklistURL) != 0 || len(c.BlacklistURLs) != 0 ||
+		len(c.RedisAddr) != 0 || c.DefaultDeny
+}
+
+// validateSources checks where the lists are loaded from.
+func (c *Config) validateSources(problems *configProblems) {
+	if !c.hasBlacklistSource() {
+		problems.addf("no blacklist file path provided")
+	}
+	c.validateBlacklistURLs(problems)
+	if len(c.RedisAddr) != 0 && len(c.RedisKey) == 0 {
+		problems.addf("a Redis address requires a Redis key")
+	}
+	if len(c.RedisKey) != 0 && len(c.RedisAddr) == 0 {
+		problems.addf("a Redis key requires a Redis address")
+	}
+	if c.DefaultDeny && len(c.WhitelistPath) == 0 {
+		problems.addf("default deny requires a whitelist path")
+	}
+	switch c.ConflictResolution {
+	case "", conflictResolutionWhitelistWins, conflictResolutionBlacklistWins:
+	default:
+		problems.addf("invalid conflict resolution %q supplied, expected %q or %q", c.ConflictResolution, conflictResolutionWhitelistWins, conflictResolutionBlacklistWins)
+	}
+}
+
+// validateBlacklistURLs checks the URLs the blacklist is downloaded from.
+func (c *Config) validateBlacklistURLs(problems *configProblems) {
+	urls := c.BlacklistURLs
+	if len(c.BlacklistURL) != 0 {
+		urls = append([]string{c.BlacklistURL}, urls...)
+	}
+	for _, rawURL := range urls {
+		if u, err := url.Parse(strings.TrimSpace(rawURL)); err != nil || (u.Scheme != "http" && u.Scheme != "https") || len(u.Host) == 0 {
+			problems.addf("invalid blacklist URL %q supplied, expected an http or https URL", rawURL)
+		}
+	}
+}
+
+// validateEntries checks the entries given in the configuration itself.
+func (c *Config) validateEntries(problems *configProblems) {
+	if len(c.BlacklistBase64) != 0 {
+		if _, err := decodeBase64Blacklist(c.BlacklistBase64); err != nil {
+			problems.addf("invalid base64 blacklist supplied: %v", err)
+		}
+	}
+	for _, entry := range c.BlacklistedIPs {
+		if parseEntry(strings.TrimPrefix(strings.TrimSpace(entry), "!")) == nil {
+			problems.addf("invalid blacklisted IP %q supplied", entry)
+		}
+	}
+
+	for _, country := range c.BlockedCountries {
+		// An empty code would match every IP missing from the database
+		if len(strings.TrimSpace(country)) == 0 {
+			problems.addf("empty blocked country code supplied")
+		}
+	}
+
+	for _, fingerprint := range c.BlockedCertFingerprints {
+		if _, err := parseCertFingerprint(fingerprint); err != nil {
+			problems.addf("%v", err)
+		}
+	}
+
+	for _, ip := range append(append([]string{}, c.SelfTestBlockedIPs...), c.SelfTestAllowedIPs...) {
+		if net.ParseIP(strings.TrimSpace(ip)) == nil {
+			problems.addf("invalid self-test IP %q supplied", ip)
+		}
+	}
+}
+
+// validateParsing checks the formats and limits the lists and logs are written with.
+func (c *Config) validateParsing(problems *configProblems) {
+	if c.LogFormat != "" && c.LogFormat != logFormatText && c.LogFormat != logFormatJSON {
+		problems.addf("invalid log format %q, expected %q or %q", c.LogFormat, logFormatText, logFormatJSON)
+	}
+	if c.BlacklistFormat != "" && !isBlacklistFormat(c.BlacklistFormat) {
+		problems.addf("invalid blacklist format %q supplied", c.BlacklistFormat)
+	}
+	if c.BlockScoreThreshold < 0 {
+		problems.addf("invalid block score threshold %d supplied", c.BlockScoreThreshold)
+	}
+	if c.BlockScoreThreshold > 0 && c.BlacklistFormat != blacklistFormatScored {
+		problems.addf("a block score threshold requires the %q blacklist format", blacklistFormatScored)
+	}
+	if c.MaxBlacklistEntries < 0 {
+		problems.addf("invalid max blacklist entries %d supplied", c.MaxBlacklistEntries)
+	}
+	if c.ReloadIntervalSeconds < 0 {
+		problems.addf("invalid reload interval %d supplied", c.ReloadIntervalSeconds)
+	}
+}
+
+// validateDeniedStatus checks the status codes and redirect denied requests are answered with.
+func (c *Config) validateDeniedStatus(problems *configProblems) {
+	if c.HTTPStatusCodeDeniedRequest != 0 {
+		if err := validateDeniedStatusCode(c.HTTPStatusCodeDeniedRequest); err != nil {
+			problems.addf("%v", err)
+		}
+	}
+	if c.LocalDeniedStatusCode != 0 {
+		if err := validateDeniedStatusCode(c.LocalDeniedStatusCode); err != nil {
+			problems.addf("local denied status code: %v", err)
+		}
+	}
+	if c.RetryAfterSeconds < 0 {
+		problems.addf("invalid retry after %d supplied", c.RetryAfterSeconds)
+	}
+
+	if len(c.DeniedRedirectURL) != 0 {
+		if _, err := url.Parse(c.DeniedRedirectURL); err != nil {
+			problems.addf("invalid denied redirect URL supplied: %v", err)
+		}
+		if c.DeniedRedirectStatusCode != 0 && (c.DeniedRedirectStatusCode < 300 || c.DeniedRedirectStatusCode > 399) {
+			problems.addf("denied redirect status code %d is not a redirect (300-399)", c.DeniedRedirectStatusCode)
+		}
+	}
+}
+
+// validateDeniedBody checks the body denied requests are answered with.
+func (c *Config) validateDeniedBody(problems *configProblems) {
+	if len(c.DeniedRequestTemplate) != 0 {
+		if _, err := template.New("denied").Parse(c.DeniedRequestTemplate); err != nil {
+			problems.addf("invalid denied request template: %v", err)
+		}
+	}
+
+	if len(c.DeniedResponseFile) != 0 && len(c.DeniedRequestTemplate) != 0 {
+		problems.addf("a denied response file can't be combined with a denied request template")
+	}
+	if len(c.DeniedResponseContentType) != 0 && len(c.DeniedResponseFile) == 0 {
+		problems.addf("a denied response content type requires a denied response file")
+	}
+}
+
+// validateDeniedResponseFormat checks the denied response format and the challenge mode, which
+// replace the configured body.
+func (c *Config) validateDeniedResponseFormat(problems *configProblems) {
+	switch c.DeniedResponseFormat {
+	case "":
+	case deniedResponseFormatProblemJSON:
+		if len(c.DeniedRequestTemplate) != 0 || len(c.DeniedResponseFile) != 0 {
+			problems.addf("the %q denied response format can't be combined with a denied request template or response file", c.DeniedResponseFormat)
+		}
+	default:
+		problems.addf("invalid denied response format %q supplied", c.DeniedResponseFormat)
+	}
+
+	if c.ChallengeMode && len(c.ChallengeSecret) == 0 {
+		problems.addf("challenge mode requires a challenge secret")
+	}
+}
+
+// validateIPEvaluation checks which client IPs are collected and how they are evaluated.
+func (c *Config) validateIPEvaluation(problems *configProblems) {
+	for _, cidr := range c.LocalIPRanges {
+		if _, _, err := net.ParseCIDR(strings.TrimSpace(cidr)); err != nil {
+			problems.addf("invalid local IP range %q supplied: %v", cidr, err)
+		}
+	}
+
+	if c.MaxForwardedForEntries < 0 {
+		problems.addf("invalid max forwarded for entries %d supplied", c.MaxForwardedForEntries)
+	}
+	switch c.IPEvaluationMode {
+	case "", ipEvaluationModeAll, ipEvaluationModeRemoteOnly:
+	case ipEvaluationModeXFFFirst, ipEvaluationModeXFFLast:
+		// The client IP headers are attacker-controlled when no proxy sits in front of Traefik
+		if c.IgnoreProxyHeaders {
+			problems.addf("IP evaluation mode %q can't be used when ignoring proxy headers", c.IPEvaluationMode)
+		}
+	default:
+		problems.addf("invalid IP evaluation mode %q supplied", c.IPEvaluationMode)
+	}
+
+	switch c.EvaluationStrategy {
+	case "", evaluationStrategyDenyIfAnyBlocked, evaluationStrategyDenyIfClientBlocked:
+	case evaluationStrategyAllowIfAnyLocal:
+		// Making local IPs wait for the other IPs defeats the strategy
+		if c.StillCheckBlacklistForLocal {
+			problems.addf("evaluation strategy %q can't be combined with still checking the blacklist for local IPs", c.EvaluationStrategy)
+		}
+	default:
+		problems.addf("invalid evaluation strategy %q supplied", c.EvaluationStrategy)
+	}
+
+	switch c.DefaultActionOnNoIP {
+	case "", defaultActionAllow, defaultActionDeny:
+	default:
+		problems.addf("invalid default action on no IP %q supplied", c.DefaultActionOnNoIP)
+	}
+
+	switch c.HostnameLookupFailureAction {
+	case "", defaultActionAllow, defaultActionDeny:
+	default:
+		problems.addf("invalid hostname lookup failure action %q supplied", c.HostnameLookupFailureAction)
+	}
+}
+
+// validateTrustedProxies checks the trusted proxies and the PROXY protocol header they set.
+func (c *Config) validateTrustedProxies(problems *configProblems) {
+	if len(c.ProxyProtocolHeader) != 0 && c.IgnoreProxyHeaders {
+		problems.addf("a PROXY protocol header can't be used when ignoring proxy headers")
+	}
+	if len(c.ProxyProtocolHeader) != 0 && len(c.TrustedProxies) == 0 {
+		problems.addf("a PROXY protocol header requires trusted proxies")
+	}
+	for _, proxy := range c.TrustedProxies {
+		if parseNetwork(strings.TrimSpace(proxy)) == nil {
+			problems.addf("invalid trusted proxy %q supplied", proxy)
+		}
+	}
+	if len(c.TrustedProxies) == 0 {
+		return
+	}
+
+	if c.IPEvaluationMode != "" && c.IPEvaluationMode != ipEvaluationModeAll {
+		problems.addf("trusted proxies can't be combined with IP evaluation mode %q", c.IPEvaluationMode)
+	}
+	if len(c.IPPrecedence) > 0 {
+		problems.addf("trusted proxies can't be combined with IP precedence")
+	}
+	if c.IgnoreProxyHeaders {
+		problems.addf("trusted proxies can't be combined with ignoring proxy headers")
+	}
+}
+
+// validateIPPrecedence checks the order the client IP sources are tried in.
+func (c *Config) validateIPPrecedence(problems *configProblems) {
+	seen := make(map[string]bool, len(c.IPPrecedence))
+	for _, source := range c.IPPrecedence {
+		source = strings.ToLower(strings.TrimSpace(source))
+		switch {
+		case source != ipSourceRemoteAddr && source != ipSourceXRealIP && source != ipSourceXFF:
+			problems.addf("invalid IP precedence source %q supplied, expected %q, %q or %q", source, ipSourceRemoteAddr, ipSourceXRealIP, ipSourceXFF)
+		case seen[source]:
+			problems.addf("IP precedence source %q is listed more than once", source)
+		}
+		seen[source] = true
+	}
+	if len(c.IPPrecedence) == 0 {
+		return
+	}
+
+	if c.IPEvaluationMode != "" && c.IPEvaluationMode != ipEvaluationModeAll {
+		problems.addf("IP precedence can't be combined with IP evaluation mode %q", c.IPEvaluationMode)
+	}
+	if c.IgnoreProxyHeaders {
+		problems.addf("IP precedence can't be combined with ignoring proxy headers")
+	}
+}
+
+// validateRateLimit checks the rate limit.
+func (c *Config) validateRateLimit(problems *configProblems) {
+	if c.RateLimitRequests < 0 {
+		problems.addf("invalid rate limit %d supplied", c.RateLimitRequests)
+	}
+	if c.RateLimitRequests <= 0 {
+		return
+	}
+
+	if c.RateLimitWindowSeconds <= 0 {
+		problems.addf("invalid rate limit window %d supplied", c.RateLimitWindowSeconds)
+	}
+	if c.RateLimitAggregateMask < 0 || c.RateLimitAggregateMask > 8*net.IPv4len {
+		problems.addf("invalid rate limit aggregate mask %d supplied", c.RateLimitAggregateMask)
+	}
+	if c.RateLimitAggregateMaskIPv6 < 0 || c.RateLimitAggregateMaskIPv6 > 8*net.IPv6len {
+		problems.addf("invalid IPv6 rate limit aggregate mask %d supplied", c.RateLimitAggregateMaskIPv6)
+	}
+}
+
+// validateAdminPaths checks the paths the middleware answers itself, and who may call them.
+func (c *Config) validateAdminPaths(problems *configProblems) {
+	if c.StatusTopBlockedIPs < 0 {
+		problems.addf("invalid status top blocked IPs %d supplied", c.StatusTopBlockedIPs)
+	}
+
+	if len(c.CheckPath) != 0 && c.CheckPath == c.StatusPath {
+		problems.addf("the check path can't be the status path")
+	}
+	for _, allowed := range c.AdminAllowedIPs {
+		if parseNetwork(strings.TrimSpace(allowed)) == nil {
+			problems.addf("invalid admin allowed IP %q supplied", allowed)
+		}
+	}
+	if (len(c.StatusPath) != 0 || len(c.ReloadPath) != 0 || len(c.CheckPath) != 0) && len(c.AdminAllowedIPs) == 0 {
+		problems.addf("the status, reload and check paths require admin allowed IPs")
+	}
+
+	if c.MetricsEnabled && len(c.StatusPath) == 0 {
+		problems.addf("metrics require a status path")
+	}
+}
@@ -0,0 +1,256 @@
+package simpleblocklist_test
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/LucaNori/traefik-simpleblocklist"
+)
+
+func TestConfig_Validate(t *testing.T) {
+	tests := []struct {
+		desc         string
+		update       func(cfg *simpleblocklist.Config)
+		wantProblems []string
+	}{
+		{
+			desc: "valid",
+			update: func(cfg *simpleblocklist.Config) {
+				cfg.BlacklistPath = "/etc/traefik/blacklist.txt"
+			},
+		},
+		{
+			desc:         "missing blacklist path",
+			update:       func(cfg *simpleblocklist.Config) {},
+			wantProblems: []string{"no blacklist file path provided"},
+		},
+		{
+			desc: "default deny without whitelist and bad status codes",
+			update: func(cfg *simpleblocklist.Config) {
+				cfg.DefaultDeny = true
+				cfg.HTTPStatusCodeDeniedRequest = 200
+				cfg.LocalDeniedStatusCode = 999
+			},
+			wantProblems: []string{
+				"default deny requires a whitelist path",
+				"denied request status code 200 is not a client or server error",
+				"local denied status code: invalid denied request status code",
+			},
+		},
+		{
+			desc: "conflicting IP evaluation settings",
+			update: func(cfg *simpleblocklist.Config) {
+				cfg.BlacklistPath = "/etc/traefik/blacklist.txt"
+				cfg.IgnoreProxyHeaders = true
+				cfg.IPEvaluationMode = "xff-last"
+				cfg.DefaultActionOnNoIP = "block"
+			},
+			wantProblems: []string{
+				`IP evaluation mode "xff-last" can't be used when ignoring proxy headers`,
+				`invalid default action on no IP "block"`,
+			},
+		},
+		{
+			desc: "problem details with a denied request template",
+			update: func(cfg *simpleblocklist.Config) {
+				cfg.BlacklistPath = "/etc/traefik/blacklist.txt"
+				cfg.DeniedResponseFormat = "problem+json"
+				cfg.DeniedRequestTemplate = "Denied {{.IP}}"
+			},
+			wantProblems: []string{
+				`the "problem+json" denied response format can't be combined with a denied request template or response file`,
+			},
+		},
+		{
+			desc: "conflicting evaluation strategy",
+			update: func(cfg *simpleblocklist.Config) {
+				cfg.BlacklistPath = "/etc/traefik/blacklist.txt"
+				cfg.EvaluationStrategy = "allow-if-any-local"
+				cfg.StillCheckBlacklistForLocal = true
+			},
+			wantProblems: []string{
+				`evaluation strategy "allow-if-any-local" can't be combined with still checking the blacklist for local IPs`,
+			},
+		},
+		{
+			desc: "invalid blacklist URL",
+			update: func(cfg *simpleblocklist.Config) {
+				cfg.BlacklistURL = "ftp://example.com/blocklist.txt"
+			},
+			wantProblems: []string{`invalid blacklist URL "ftp://example.com/blocklist.txt"`},
+		},
+		{
+			desc: "unknown conflict resolution",
+			update: func(cfg *simpleblocklist.Config) {
+				cfg.BlacklistPath = "/etc/traefik/blacklist.txt"
+				cfg.ConflictResolution = "newest-wins"
+			},
+			wantProblems: []string{`invalid conflict resolution "newest-wins"`},
+		},
+		{
+			desc: "unknown evaluation strategy",
+			update: func(cfg *simpleblocklist.Config) {
+				cfg.BlacklistPath = "/etc/traefik/blacklist.txt"
+				cfg.EvaluationStrategy = "deny-all"
+			},
+			wantProblems: []string{`invalid evaluation strategy "deny-all"`},
+		},
+		{
+			desc: "invalid and conflicting trusted proxies",
+			update: func(cfg *simpleblocklist.Config) {
+				cfg.BlacklistPath = "/etc/traefik/blacklist.txt"
+				cfg.TrustedProxies = []string{"10.0.0.0/8", "proxy.internal"}
+				cfg.IPEvaluationMode = "xff-first"
+			},
+			wantProblems: []string{
+				`invalid trusted proxy "proxy.internal"`,
+				`trusted proxies can't be combined with IP evaluation mode "xff-first"`,
+			},
+		},
+		{
+			desc: "empty blocked country code",
+			update: func(cfg *simpleblocklist.Config) {
+				cfg.BlacklistPath = "/etc/traefik/blacklist.txt"
+				cfg.GeoIPDatabasePath = "/etc/traefik/GeoLite2-Country.mmdb"
+				cfg.BlockedCountries = []string{"SE", " "}
+			},
+			wantProblems: []string{"empty blocked country code supplied"},
+		},
+		{
+			desc: "PROXY protocol header without trusted proxies",
+			update: func(cfg *simpleblocklist.Config) {
+				cfg.BlacklistPath = "/etc/traefik/blacklist.txt"
+				cfg.ProxyProtocolHeader = "X-Forwarded-Proxy-Client"
+			},
+			wantProblems: []string{"a PROXY protocol header requires trusted proxies"},
+		},
+		{
+			desc: "invalid formats, ranges and limits",
+			update: func(cfg *simpleblocklist.Config) {
+				cfg.BlacklistPath = "/etc/traefik/blacklist.txt"
+				cfg.LogFormat = "xml"
+				cfg.BlacklistFormat = "iptables"
+				cfg.LocalIPRanges = []string{"100.64.0.0/10", "not-a-range"}
+				cfg.RateLimitRequests = 10
+				cfg.RateLimitAggregateMask = 40
+				cfg.ReloadIntervalSeconds = -1
+			},
+			wantProblems: []string{
+				`invalid log format "xml"`,
+				`invalid blacklist format "iptables"`,
+				`invalid local IP range "not-a-range"`,
+				"invalid rate limit window 0",
+				"invalid rate limit aggregate mask 40",
+				"invalid reload interval -1",
+			},
+		},
+		{
+			desc: "redirect status code",
+			update: func(cfg *simpleblocklist.Config) {
+				cfg.BlacklistPath = "/etc/traefik/blacklist.txt"
+				cfg.DeniedRedirectURL = "https://example.com/blocked"
+				cfg.DeniedRedirectStatusCode = 200
+			},
+			wantProblems: []string{"denied redirect status code 200 is not a redirect"},
+		},
+		{
+			desc: "invalid tiered lists",
+			update: func(cfg *simpleblocklist.Config) {
+				cfg.BlacklistPath = "/etc/traefik/blacklist.txt"
+				cfg.TieredLists = []simpleblocklist.TieredList{
+					{Path: "/etc/traefik/soft.txt", StatusCode: 302, Action: "block"},
+					{StatusCode: 429},
+				}
+			},
+			wantProblems: []string{
+				"tiered list 1: denied request status code 302",
+				`tiered list 1: invalid action "block"`,
+				"tiered list 2 has no path",
+			},
+		},
+		{
+			desc: "challenge and metrics dependencies",
+			update: func(cfg *simpleblocklist.Config) {
+				cfg.BlacklistPath = "/etc/traefik/blacklist.txt"
+				cfg.ChallengeMode = true
+				cfg.MetricsEnabled = true
+			},
+			wantProblems: []string{
+				"challenge mode requires a challenge secret",
+				"metrics require a status path",
+			},
+		},
+		{
+			desc: "hostname lookup failure action",
+			update: func(cfg *simpleblocklist.Config) {
+				cfg.BlacklistPath = "/etc/traefik/blacklist.txt"
+				cfg.HostnameLookupFailureAction = "block"
+			},
+			wantProblems: []string{`invalid hostname lookup failure action "block"`},
+		},
+		{
+			desc: "admin paths",
+			update: func(cfg *simpleblocklist.Config) {
+				cfg.BlacklistPath = "/etc/traefik/blacklist.txt"
+				cfg.ReloadPath = "/_blocklist/reload"
+			},
+			wantProblems: []string{"the status, reload and check paths require admin allowed IPs"},
+		},
+		{
+			desc: "invalid admin allowed IP",
+			update: func(cfg *simpleblocklist.Config) {
+				cfg.BlacklistPath = "/etc/traefik/blacklist.txt"
+				cfg.StatusPath = "/_blocklist/status"
+				cfg.AdminAllowedIPs = []string{"127.0.0.1", "localhost"}
+			},
+			wantProblems: []string{`invalid admin allowed IP "localhost"`},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			cfg := simpleblocklist.CreateConfig()
+			test.update(cfg)
+
+			err := cfg.Validate()
+			if len(test.wantProblems) == 0 {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil {
+				t.Fatal("expected an error")
+			}
+
+			for _, problem := range test.wantProblems {
+				if !strings.Contains(err.Error(), problem) {
+					t.Errorf("error %q doesn't report %q", err, problem)
+				}
+			}
+			if got := strings.Count(err.Error(), ";") + 1; got != len(test.wantProblems) {
+				t.Errorf("got %d problems in %q, want %d", got, err, len(test.wantProblems))
+			}
+		})
+	}
+}
+
+func TestSimpleBlocklist_NewValidatesConfig(t *testing.T) {
+	cfg := simpleblocklist.CreateConfig()
+	cfg.LogFormat = "xml"
+	cfg.RetryAfterSeconds = -1
+
+	next := http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {})
+
+	_, err := simpleblocklist.New(context.Background(), next, cfg, "simpleblocklist")
+	if err == nil {
+		t.Fatal("expected error for an invalid configuration")
+	}
+	for _, problem := range []string{"no blacklist file path provided", `invalid log format "xml"`, "invalid retry after -1"} {
+		if !strings.Contains(err.Error(), problem) {
+			t.Errorf("error %q doesn't report %q", err, problem)
+		}
+	}
+}
@@ -0,0 +1,66 @@
+package simpleblocklist
+
+import (
+	"net"
+	"strings"
+)
+
+const (
+	conflictResolutionWhitelistWins = "whitelist-wins"
+	conflictResolutionBlacklistWins = "blacklist-wins"
+
+	// maxConflictSample caps how many conflicting entries are listed in the load warning.
+	maxConflictSample = 10
+)
+
+// listConflicts returns the whitelist and blacklist entries that overlap, as "<whitelisted> and
+// <blacklisted>" pairs. CIDR networks either nest or are disjoint, so two entries overlap exactly
+// when one of them holds the first address of the other.
+func listConflicts(blacklist, whitelist *ipTrie, blacklistNetworks, whitelistNetworks []*net.IPNet) []string {
+	var conflicts []string
+	seen := make(map[string]bool)
+	add := func(whitelisted, blacklisted *net.IPNet) {
+		conflict := whitelisted.String() + " and " + blacklisted.String()
+		if !seen[conflict] {
+			seen[conflict] = true
+			conflicts = append(conflicts, conflict)
+		}
+	}
+
+	for _, network := range whitelistNetworks {
+		if blacklisted := blacklist.match(network.IP); blacklisted != nil {
+			add(network, blacklisted)
+		}
+	}
+	for _, network := range blacklistNetworks {
+		if whitelisted := whitelist.match(network.IP); whitelisted != nil {
+			add(whitelisted, network)
+		}
+	}
+	return conflicts
+}
+
+// warnListConflicts logs the entries of the whitelist that overlap entries of the blacklist, so
+// operators can clean up overlapping feeds. Nothing is logged in default deny mode, where the
+// blacklist doesn't apply.
+func (a *SimpleBlocklist) warnListConflicts(blacklist, whitelist *ipTrie, blacklistNetworks, whitelistNetworks []*net.IPNet) {
+	if a.defaultDeny || len(whitelistNetworks) == 0 {
+		return
+	}
+
+	conflicts := listConflicts(blacklist, whitelist, blacklistNetworks, whitelistNetworks)
+	if len(conflicts) == 0 {
+		return
+	}
+
+	resolution := conflictResolutionWhitelistWins
+	if a.blacklistWins {
+		resolution = conflictResolutionBlacklistWins
+	}
+	sample := conflicts
+	if len(sample) > maxConflictSample {
+		sample = sample[:maxConflictSample]
+	}
+	a.logger.warnf(logFields{"conflicts": len(conflicts), "resolution": resolution, "sample": sample},
+		"Found %d overlapping whitelist and blacklist entries (%s): %s", len(conflicts), resolution, strings.Join(sample, ", "))
+}
@@ -0,0 +1,111 @@
+package simpleblocklist
+
+import (
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"text/template"
+)
+
+// maxDeniedPageSize caps the size of the denied response file, which is kept in memory.
+const maxDeniedPageSize = 1 << 20
+
+// deniedPage the cached contents of the denied response file.
+type deniedPage struct {
+	body        []byte
+	contentType string
+}
+
+// deniedResponse how denied requests are answered, besides their status code.
+type deniedResponse struct {
+	template *template.Template
+	page     *deniedPage
+	// challengeSecret the key signing the challenge cookies, nil unless the challenge mode is on.
+	challengeSecret []byte
+}
+
+// newDeniedResponse prepares the response to denied requests configured in config, loading the
+// denied response file if any.
+func newDeniedResponse(config *Config, logger *logger) (*deniedResponse, error) {
+	logger.infof(nil, "Denied request status code: %d", config.HTTPStatusCodeDeniedRequest)
+	if config.LocalDeniedStatusCode != config.HTTPStatusCodeDeniedRequest {
+		logger.infof(nil, "Local denied request status code: %d", config.LocalDeniedStatusCode)
+	}
+	if len(config.DeniedRedirectURL) != 0 {
+		logger.infof(nil, "Denied requests are redirected to %s with status code %d",
+			config.DeniedRedirectURL, config.DeniedRedirectStatusCode)
+	}
+
+	response := &deniedResponse{}
+	if len(config.DeniedRequestTemplate) != 0 {
+		// Validate already parsed it
+		response.template = template.Must(template.New("denied").Parse(config.DeniedRequestTemplate))
+		logger.infof(nil, "Denied requests are answered with a templated body")
+	}
+
+	if len(config.DeniedResponseFile) != 0 {
+		page, err := loadDeniedPage(config.DeniedResponseFile, config.DeniedResponseContentType)
+		if err != nil {
+			return nil, err
+		}
+		response.page = page
+		logger.infof(nil, "Denied requests are answered with %s (%s)", config.DeniedResponseFile, page.contentType)
+	}
+	if config.DeniedResponseFormat == deniedResponseFormatProblemJSON {
+		logger.infof(nil, "Denied requests are answered with RFC 7807 problem details")
+	}
+
+	if config.ChallengeMode {
+		response.challengeSecret = []byte(config.ChallengeSecret)
+		logger.infof(nil, "Challenge mode: blocked clients can pass a cookie challenge")
+	}
+	return response, nil
+}
+
+// loadDeniedPage reads the denied response file at path. Its content type is contentType if set,
+// otherwise it is inferred from the file extension, then from the contents.
+func loadDeniedPage(path, contentType string) (*deniedPage, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load denied response file: %v", err)
+	}
+	defer file.Close()
+
+	body, err := io.ReadAll(io.LimitReader(file, maxDeniedPageSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to load denied response file: %v", err)
+	}
+	if len(body) > maxDeniedPageSize {
+		return nil, fmt.Errorf("denied response file %s exceeds %d bytes", path, maxDeniedPageSize)
+	}
+
+	if len(contentType) == 0 {
+		contentType = mime.TypeByExtension(filepath.Ext(path))
+	}
+	if len(contentType) == 0 {
+		contentType = http.DetectContentType(body)
+	}
+
+	return &deniedPage{body: body, contentType: contentType}, nil
+}
+
+// reloadDeniedPage reloads the denied response file, if any, keeping the current contents if it
+// can't be read so a broken deploy of the page doesn't affect blocking.
+func (a *SimpleBlocklist) reloadDeniedPage() {
+	if len(a.deniedResponseFile) == 0 {
+		return
+	}
+
+	page, err := loadDeniedPage(a.deniedResponseFile, a.deniedResponseContentType)
+	if err != nil {
+		a.logger.warnf(logFields{"path": a.deniedResponseFile}, "Keeping the current denied response: %v", err)
+		return
+	}
+
+	a.mu.Lock()
+	a.deniedPage = page
+	a.mu.Unlock()
+}
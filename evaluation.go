@@ -0,0 +1,68 @@
+package simpleblocklist
+
+import (
+	"net"
+	"net/http"
+)
+
+const (
+	evaluationStrategyDenyIfAnyBlocked    = "deny-if-any-blocked"
+	evaluationStrategyAllowIfAnyLocal     = "allow-if-any-local"
+	evaluationStrategyDenyIfClientBlocked = "deny-if-client-blocked"
+)
+
+// classifiedIP a collected client IP, parsed and classified.
+type classifiedIP struct {
+	addr string
+	ip   net.IP
+	// local whether the IP is local and local IP handling applies to the request path.
+	local bool
+}
+
+// classifyIPs parses the collected client IPs, in order, skipping the ones that aren't valid, and
+// classifies them as local or public.
+func (a *SimpleBlocklist) classifyIPs(req *http.Request, ipAddresses []string) []classifiedIP {
+	ips := make([]classifiedIP, 0, len(ipAddresses))
+	for _, addr := range ipAddresses {
+		ip := parseIP(addr)
+		if ip == nil {
+			a.logger.infof(logFields{"ip": a.logIP(addr)}, "Failed to parse IP: %s", a.logIP(addr))
+			continue
+		}
+		ips = append(ips, classifiedIP{addr: addr, ip: ip, local: a.isLocalIP(ip) && a.isLocalRequestPath(req)})
+	}
+	return ips
+}
+
+// selectIPs returns the classified IPs to decide on, in order, according to the evaluation
+// strategy. By default, and with deny-if-any-blocked, every IP is decided on in collection order.
+// With allow-if-any-local, local IPs come first, so any of them decides the request. With
+// deny-if-client-blocked, only the client decides it: the first IP that isn't local walking from
+// the nearest hop, RemoteAddr, towards the farthest, or the farthest IP if they are all local.
+func (a *SimpleBlocklist) selectIPs(ips []classifiedIP) []classifiedIP {
+	switch a.evaluationStrategy {
+	case evaluationStrategyAllowIfAnyLocal:
+		selected := make([]classifiedIP, 0, len(ips))
+		for _, ip := range ips {
+			if ip.local {
+				selected = append(selected, ip)
+			}
+		}
+		for _, ip := range ips {
+			if !ip.local {
+				selected = append(selected, ip)
+			}
+		}
+		return selected
+	case evaluationStrategyDenyIfClientBlocked:
+		for i := len(ips) - 1; i >= 0; i-- {
+			if !ips[i].local {
+				return ips[i : i+1]
+			}
+		}
+		if len(ips) > 0 {
+			return ips[:1]
+		}
+	}
+	return ips
+}
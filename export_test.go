@@ -0,0 +1,78 @@
+package simpleblocklist
+
+import (
+	"io"
+	"net/http"
+	"os"
+	"time"
+)
+
+// SetLogOutput redirects the plugin logs to w until the returned function is called.
+func SetLogOutput(w io.Writer) (restore func()) {
+	infoLogger.SetOutput(w)
+	warnLogger.SetOutput(w)
+	jsonLogger.SetOutput(w)
+
+	return func() {
+		infoLogger.SetOutput(os.Stdout)
+		warnLogger.SetOutput(os.Stdout)
+		jsonLogger.SetOutput(os.Stdout)
+	}
+}
+
+// Reload reloads the blacklist of a handler created by New.
+func Reload(handler http.Handler) error {
+	return handler.(*SimpleBlocklist).Reload()
+}
+
+// ReloadDone returns a channel that is closed once the background reload of a handler created by New has stopped.
+func ReloadDone(handler http.Handler) <-chan struct{} {
+	return handler.(*SimpleBlocklist).reloadDone
+}
+
+// SetSetStore makes handlers created by New load their Redis set from store until the returned
+// function is called.
+func SetSetStore(store SetStore) (restore func()) {
+	previous := newSetStore
+	newSetStore = func(string) SetStore { return store }
+	return func() { newSetStore = previous }
+}
+
+// Sources returns the blacklist sources of a handler created by New.
+func Sources(handler http.Handler) []BlocklistSource {
+	return handler.(*SimpleBlocklist).sources
+}
+
+// SetSources replaces the blacklist sources of a handler created by New, effective on the next reload.
+func SetSources(handler http.Handler, sources ...BlocklistSource) {
+	handler.(*SimpleBlocklist).sources = sources
+}
+
+// SetResolver replaces the resolver used for DNS lookups by a handler created by New.
+func SetResolver(handler http.Handler, resolver Resolver) {
+	handler.(*SimpleBlocklist).resolver = resolver
+}
+
+// SetHostnameFailureCacheTTL sets how long failed reverse lookups are cached until the returned
+// function is called.
+func SetHostnameFailureCacheTTL(ttl time.Duration) (restore func()) {
+	previous := hostnameFailureCacheTTL
+	hostnameFailureCacheTTL = ttl
+	return func() { hostnameFailureCacheTTL = previous }
+}
+
+// SetMaxRateLimitEntries caps the number of IPs counted by the rate limiter of handlers created by
+// New until the returned function is called.
+func SetMaxRateLimitEntries(size int64) (restore func()) {
+	previous := maxRateLimitEntries
+	maxRateLimitEntries = size
+	return func() { maxRateLimitEntries = previous }
+}
+
+// SetMaxRemoteDecompressedSize caps the decompressed size of compressed downloaded blacklists until
+// the returned function is called.
+func SetMaxRemoteDecompressedSize(size int64) (restore func()) {
+	previous := maxRemoteDecompressedSize
+	maxRemoteDecompressedSize = size
+	return func() { maxRemoteDecompressedSize = previous }
+}
@@ -0,0 +1,104 @@
+package simpleblocklist
+
+import (
+	"fmt"
+	"net"
+	"strings"
+)
+
+// countryBlocker denies IPs located in one of the blocked countries.
+type countryBlocker struct {
+	db        *mmdbReader
+	countries map[string]struct{}
+}
+
+// newCountryBlocker opens the GeoIP database of config, and returns nil if none is configured.
+func newCountryBlocker(config *Config, logger *logger) (*countryBlocker, error) {
+	if len(config.GeoIPDatabasePath) == 0 {
+		if len(config.BlockedCountries) > 0 {
+			logger.infof(nil, "No GeoIP database path provided, country blocking is disabled")
+		}
+		return nil, nil
+	}
+
+	db, err := openMMDB(config.GeoIPDatabasePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open GeoIP database: %v", err)
+	}
+
+	blocked := make(map[string]struct{}, len(config.BlockedCountries))
+	for _, country := range config.BlockedCountries {
+		blocked[strings.ToUpper(strings.TrimSpace(country))] = struct{}{}
+	}
+	logger.infof(nil, "Blocked countries: %s", strings.Join(config.BlockedCountries, ", "))
+
+	return &countryBlocker{db: db, countries: blocked}, nil
+}
+
+// lookup returns the ISO code of the country ip is located in, and whether that country is blocked.
+// The code is empty, and never blocked, if ip is not in the database.
+func (c *countryBlocker) lookup(ip net.IP) (string, bool, error) {
+	record, err := c.db.lookup(ip)
+	if err != nil {
+		return "", false, err
+	}
+
+	country, _ := record["country"].(map[string]interface{})
+	isoCode, _ := country["iso_code"].(string)
+	if len(isoCode) == 0 {
+		return "", false, nil
+	}
+	_, blocked := c.countries[isoCode]
+	return isoCode, blocked, nil
+}
+
+// asnRecord the subset of a GeoLite2/GeoIP2 ASN record used for blocking.
+type asnRecord struct {
+	Number       uint
+	Organization string
+}
+
+// asnBlocker denies IPs announced by one of the blocked autonomous systems.
+type asnBlocker struct {
+	db   *mmdbReader
+	asns map[uint]struct{}
+}
+
+// newASNBlocker opens the ASN database of config, and returns nil if none is configured.
+func newASNBlocker(config *Config, logger *logger) (*asnBlocker, error) {
+	if len(config.ASNDatabasePath) == 0 {
+		if len(config.BlockedASNs) > 0 {
+			logger.infof(nil, "No ASN database path provided, ASN blocking is disabled")
+		}
+		return nil, nil
+	}
+
+	db, err := openMMDB(config.ASNDatabasePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open ASN database: %v", err)
+	}
+
+	blocked := make(map[uint]struct{}, len(config.BlockedASNs))
+	for _, asn := range config.BlockedASNs {
+		blocked[asn] = struct{}{}
+	}
+	logger.infof(nil, "Blocked ASNs: %v", config.BlockedASNs)
+
+	return &asnBlocker{db: db, asns: blocked}, nil
+}
+
+// lookup returns the autonomous system ip belongs to, and whether that autonomous system is blocked.
+// The number is 0 if ip is not in the database.
+func (b *asnBlocker) lookup(ip net.IP) (asnRecord, bool, error) {
+	record, err := b.db.lookup(ip)
+	if err != nil {
+		return asnRecord{}, false, err
+	}
+
+	number, _ := record["autonomous_system_number"].(uint64)
+	organization, _ := record["autonomous_system_organization"].(string)
+	as := asnRecord{Number: uint(number), Organization: organization}
+
+	_, blocked := b.asns[as.Number]
+	return as, blocked && as.Number != 0, nil
+}
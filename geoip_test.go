@@ -0,0 +1,202 @@
+package simpleblocklist_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/LucaNori/traefik-simpleblocklist"
+)
+
+func TestSimpleBlocklist_BlockedCountries(t *testing.T) {
+	cfg := simpleblocklist.CreateConfig()
+	cfg.BlacklistPath = createBlacklistFile(t, "192.0.2.1\n")
+	cfg.GeoIPDatabasePath = "testdata/GeoLite2-Country-Test.mmdb"
+	cfg.BlockedCountries = []string{"gb", "SE"}
+
+	ctx := context.Background()
+	next := http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		rw.WriteHeader(http.StatusOK)
+	})
+
+	handler, err := simpleblocklist.New(ctx, next, cfg, "simpleblocklist")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		desc           string
+		ip             string
+		expectedStatus int
+	}{
+		{
+			desc:           "IPv4 in blocked country",
+			ip:             "81.2.69.160",
+			expectedStatus: 403,
+		},
+		{
+			desc:           "IPv6 in blocked country",
+			ip:             "2001:db8:1::1",
+			expectedStatus: 403,
+		},
+		{
+			desc:           "IP in allowed country",
+			ip:             "216.160.83.56",
+			expectedStatus: 200,
+		},
+		{
+			desc:           "IP not in database",
+			ip:             "203.0.113.1",
+			expectedStatus: 200,
+		},
+		{
+			desc:           "Blacklisted IP",
+			ip:             "192.0.2.1",
+			expectedStatus: 403,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			recorder := httptest.NewRecorder()
+			req, err := http.NewRequestWithContext(ctx, http.MethodGet, "http://localhost", nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			req.Header.Set("X-Forwarded-For", test.ip)
+
+			handler.ServeHTTP(recorder, req)
+
+			if recorder.Code != test.expectedStatus {
+				t.Errorf("got status code %d, want %d", recorder.Code, test.expectedStatus)
+			}
+		})
+	}
+}
+
+func TestSimpleBlocklist_BlockedCountriesWithoutDatabase(t *testing.T) {
+	cfg := simpleblocklist.CreateConfig()
+	cfg.BlacklistPath = createBlacklistFile(t, "192.0.2.1\n")
+	cfg.BlockedCountries = []string{"GB"}
+
+	ctx := context.Background()
+	next := http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		rw.WriteHeader(http.StatusOK)
+	})
+
+	handler, err := simpleblocklist.New(ctx, next, cfg, "simpleblocklist")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	recorder := httptest.NewRecorder()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, "http://localhost", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("X-Forwarded-For", "81.2.69.160")
+
+	handler.ServeHTTP(recorder, req)
+
+	if recorder.Code != 200 {
+		t.Errorf("got status code %d, want 200", recorder.Code)
+	}
+}
+
+func TestSimpleBlocklist_InvalidGeoIPDatabase(t *testing.T) {
+	cfg := simpleblocklist.CreateConfig()
+	cfg.BlacklistPath = createBlacklistFile(t, "192.0.2.1\n")
+	cfg.GeoIPDatabasePath = "testdata/nonexistent.mmdb"
+
+	ctx := context.Background()
+	next := http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {})
+
+	_, err := simpleblocklist.New(ctx, next, cfg, "simpleblocklist")
+	if err == nil {
+		t.Error("expected error when GeoIP database doesn't exist")
+	}
+}
+
+func TestSimpleBlocklist_CorruptGeoIPDatabase(t *testing.T) {
+	cfg := simpleblocklist.CreateConfig()
+	cfg.BlacklistPath = createBlacklistFile(t, "192.0.2.1\n")
+	// Any file without MaxMind DB metadata, such as a blacklist saved under the wrong name
+	cfg.GeoIPDatabasePath = createBlacklistFile(t, "198.51.100.0/24\n")
+	cfg.BlockedCountries = []string{"SE"}
+
+	next := http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {})
+
+	_, err := simpleblocklist.New(context.Background(), next, cfg, "simpleblocklist")
+	if err == nil || !strings.Contains(err.Error(), "metadata not found") {
+		t.Errorf("expected an invalid database error, got %v", err)
+	}
+}
+
+func TestSimpleBlocklist_BlockedASNs(t *testing.T) {
+	cfg := simpleblocklist.CreateConfig()
+	cfg.BlacklistPath = createBlacklistFile(t, "192.0.2.1\n")
+	cfg.GeoIPDatabasePath = "testdata/GeoLite2-Country-Test.mmdb"
+	cfg.BlockedCountries = []string{"SE"}
+	cfg.ASNDatabasePath = "testdata/GeoLite2-ASN-Test.mmdb"
+	cfg.BlockedASNs = []uint{1221, 64500}
+	cfg.DebugHeaders = true
+
+	ctx := context.Background()
+	next := http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		rw.WriteHeader(http.StatusOK)
+	})
+
+	handler, err := simpleblocklist.New(ctx, next, cfg, "simpleblocklist")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		desc           string
+		ip             string
+		expectedStatus int
+		expectedReason string
+	}{
+		{desc: "IPv4 in blocked ASN", ip: "1.128.0.1", expectedStatus: 403, expectedReason: "asn:AS1221"},
+		{desc: "IPv6 in blocked ASN", ip: "2001:db8:2::1", expectedStatus: 403, expectedReason: "asn:AS64500"},
+		{desc: "IP in allowed ASN", ip: "12.81.92.1", expectedStatus: 200},
+		{desc: "IP not in database", ip: "203.0.113.1", expectedStatus: 200},
+		{desc: "IP in blocked country", ip: "89.160.20.1", expectedStatus: 403, expectedReason: "country:SE"},
+		{desc: "Blacklisted IP", ip: "192.0.2.1", expectedStatus: 403, expectedReason: "blacklist:192.0.2.1/32"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			recorder := httptest.NewRecorder()
+			req, err := http.NewRequestWithContext(ctx, http.MethodGet, "http://localhost", nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			req.Header.Set("X-Forwarded-For", test.ip)
+
+			handler.ServeHTTP(recorder, req)
+
+			if recorder.Code != test.expectedStatus {
+				t.Errorf("got status code %d, want %d", recorder.Code, test.expectedStatus)
+			}
+			if reason := recorder.Header().Get("X-Blocked-Reason"); reason != test.expectedReason {
+				t.Errorf("got blocked reason %q, want %q", reason, test.expectedReason)
+			}
+		})
+	}
+}
+
+func TestSimpleBlocklist_ASNDatabaseMissing(t *testing.T) {
+	cfg := simpleblocklist.CreateConfig()
+	cfg.BlacklistPath = createBlacklistFile(t, "192.0.2.1\n")
+	cfg.ASNDatabasePath = "testdata/does-not-exist.mmdb"
+	cfg.BlockedASNs = []uint{1221}
+
+	next := http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {})
+
+	if _, err := simpleblocklist.New(context.Background(), next, cfg, "simpleblocklist"); err == nil {
+		t.Error("expected error for a missing ASN database")
+	}
+}
@@ -0,0 +1,31 @@
+package simpleblocklist
+
+import (
+	"net/http"
+	"strings"
+)
+
+// newBlockedHosts returns the normalized blocked host patterns of config.
+func newBlockedHosts(config *Config, logger *logger) []string {
+	if len(config.BlockedHosts) == 0 {
+		return nil
+	}
+
+	hosts := normalizeHostnames(config.BlockedHosts)
+	logger.infof(nil, "Blocked hosts: %s", strings.Join(hosts, ", "))
+	return hosts
+}
+
+// blockedHost returns the Host header of req if it matches a blocked host pattern, or an empty
+// string if it doesn't. The port, if any, is ignored.
+func (a *SimpleBlocklist) blockedHost(req *http.Request) string {
+	if len(a.blockedHosts) == 0 {
+		return ""
+	}
+
+	host := normalizeHostname(stripPort(req.Host))
+	if len(host) != 0 && matchesHostnamePattern(a.blockedHosts, host) {
+		return host
+	}
+	return ""
+}
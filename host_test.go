@@ -0,0 +1,79 @@
+package simpleblocklist_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/LucaNori/traefik-simpleblocklist"
+)
+
+func TestSimpleBlocklist_BlockedHosts(t *testing.T) {
+	cfg := simpleblocklist.CreateConfig()
+	cfg.BlacklistPath = createBlacklistFile(t, "192.0.2.1\n")
+	cfg.BlockedHosts = []string{"*.evil.example", "Admin.Example.com."}
+
+	ctx := context.Background()
+	next := http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		rw.WriteHeader(http.StatusOK)
+	})
+
+	handler, err := simpleblocklist.New(ctx, next, cfg, "simpleblocklist")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		desc           string
+		host           string
+		remoteAddr     string
+		expectedStatus int
+	}{
+		{
+			desc:           "wildcard subdomain",
+			host:           "cdn.evil.example",
+			remoteAddr:     "203.0.113.1:1234",
+			expectedStatus: 403,
+		},
+		{
+			desc:           "nested wildcard subdomain with port",
+			host:           "a.b.EVIL.example:8443",
+			remoteAddr:     "203.0.113.1:1234",
+			expectedStatus: 403,
+		},
+		{
+			desc:           "wildcard parent domain",
+			host:           "evil.example",
+			remoteAddr:     "203.0.113.1:1234",
+			expectedStatus: 200,
+		},
+		{
+			desc:           "exact host from a local IP",
+			host:           "admin.example.com",
+			remoteAddr:     "10.0.0.1:1234",
+			expectedStatus: 403,
+		},
+		{
+			desc:           "other host",
+			host:           "www.example.com",
+			remoteAddr:     "203.0.113.1:1234",
+			expectedStatus: 200,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			recorder := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodGet, "http://localhost/", nil)
+			req.Host = test.host
+			req.RemoteAddr = test.remoteAddr
+
+			handler.ServeHTTP(recorder, req)
+
+			if recorder.Code != test.expectedStatus {
+				t.Errorf("got status code %d, want %d", recorder.Code, test.expectedStatus)
+			}
+		})
+	}
+}
@@ -0,0 +1,145 @@
+package simpleblocklist
+
+import (
+	"context"
+	"errors"
+	"net"
+	"path"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// reverseLookupTimeout caps how long a request waits for the PTR records of its client IP.
+	reverseLookupTimeout = 500 * time.Millisecond
+	// hostnameCacheTTL how long reverse lookup results, including IPs without PTR records, are cached.
+	hostnameCacheTTL = 10 * time.Minute
+	// maxHostnameCacheEntries bounds the reverse lookup cache.
+	maxHostnameCacheEntries = 10000
+)
+
+// hostnameFailureCacheTTL how long failed reverse lookups, such as timeouts, are cached: long enough
+// to spare an unavailable DNS server a lookup per request, short enough to check again soon after.
+var hostnameFailureCacheTTL = 30 * time.Second
+
+// Resolver performs the reverse DNS lookups of the plugin. *net.Resolver implements it.
+type Resolver interface {
+	LookupAddr(ctx context.Context, addr string) ([]string, error)
+}
+
+// hostnameBlocker denies IPs whose reverse DNS (PTR) hostname matches one of the blocked patterns.
+type hostnameBlocker struct {
+	patterns []string
+	// denyOnFailure denies IPs whose reverse lookup failed instead of letting them through.
+	denyOnFailure bool
+
+	mu    sync.Mutex
+	cache map[string]hostnameCacheEntry
+}
+
+// hostnameCacheEntry the cached outcome of a reverse lookup. hostname is the matching PTR
+// hostname, empty if none matched or the lookup failed.
+type hostnameCacheEntry struct {
+	hostname string
+	failed   bool
+	expires  time.Time
+}
+
+// newHostnameBlocker returns the hostname blocker of config, or nil if no pattern is blocked.
+func newHostnameBlocker(config *Config, logger *logger) *hostnameBlocker {
+	if len(config.BlockedHostnamePatterns) == 0 {
+		return nil
+	}
+
+	logger.infof(nil, "Blocked hostname patterns: %s", strings.Join(config.BlockedHostnamePatterns, ", "))
+	denyOnFailure := config.HostnameLookupFailureAction == defaultActionDeny
+	if denyOnFailure {
+		logger.infof(nil, "IPs whose hostname lookup fails are denied")
+	}
+
+	return &hostnameBlocker{
+		patterns:      normalizeHostnames(config.BlockedHostnamePatterns),
+		denyOnFailure: denyOnFailure,
+		cache:         make(map[string]hostnameCacheEntry),
+	}
+}
+
+// normalizeHostnames lowercases hostnames or hostname patterns and removes their trailing dot.
+func normalizeHostnames(hostnames []string) []string {
+	normalized := make([]string, 0, len(hostnames))
+	for _, hostname := range hostnames {
+		normalized = append(normalized, normalizeHostname(hostname))
+	}
+	return normalized
+}
+
+func normalizeHostname(hostname string) string {
+	return strings.ToLower(strings.TrimSuffix(strings.TrimSpace(hostname), "."))
+}
+
+// match returns the PTR hostname of ip, looked up with resolver, that matches a blocked pattern,
+// or an empty string if none does, and whether the lookup failed. An IP without PTR records is not
+// a failure. Results are cached so repeated requests don't wait on DNS, failures for a shorter time.
+func (h *hostnameBlocker) match(resolver Resolver, ip net.IP) (hostname string, failed bool) {
+	key := ip.String()
+	now := time.Now()
+
+	h.mu.Lock()
+	entry, ok := h.cache[key]
+	h.mu.Unlock()
+	if ok && now.Before(entry.expires) {
+		return entry.hostname, entry.failed
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), reverseLookupTimeout)
+	defer cancel()
+
+	names, err := resolver.LookupAddr(ctx, key)
+	var dnsErr *net.DNSError
+	failed = err != nil && !(errors.As(err, &dnsErr) && dnsErr.IsNotFound)
+	for _, name := range names {
+		name = normalizeHostname(name)
+		if matchesHostnamePattern(h.patterns, name) {
+			hostname = name
+			break
+		}
+	}
+
+	ttl := hostnameCacheTTL
+	if failed {
+		ttl = hostnameFailureCacheTTL
+	}
+	h.mu.Lock()
+	if len(h.cache) >= maxHostnameCacheEntries {
+		h.evictExpired(now)
+	}
+	h.cache[key] = hostnameCacheEntry{hostname: hostname, failed: failed, expires: now.Add(ttl)}
+	h.mu.Unlock()
+
+	return hostname, failed
+}
+
+// matchesHostnamePattern reports whether hostname matches one of patterns, such as
+// "*.scan.example". "*" matches any characters, dots included.
+func matchesHostnamePattern(patterns []string, hostname string) bool {
+	for _, pattern := range patterns {
+		if matched, _ := path.Match(pattern, hostname); matched {
+			return true
+		}
+	}
+	return false
+}
+
+// evictExpired removes expired cache entries, or all of them if none has expired, which keeps the
+// cache bounded without tracking recency. The caller must hold h.mu.
+func (h *hostnameBlocker) evictExpired(now time.Time) {
+	for key, entry := range h.cache {
+		if !now.Before(entry.expires) {
+			delete(h.cache, key)
+		}
+	}
+	if len(h.cache) >= maxHostnameCacheEntries {
+		h.cache = make(map[string]hostnameCacheEntry)
+	}
+}
@@ -0,0 +1,169 @@
+package simpleblocklist_test
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/LucaNori/traefik-simpleblocklist"
+)
+
+func TestSimpleBlocklist_BlockedHostnamePatterns(t *testing.T) {
+	cfg := simpleblocklist.CreateConfig()
+	cfg.BlacklistPath = createBlacklistFile(t, "192.0.2.1\n")
+	cfg.BlockedHostnamePatterns = []string{"*.amazonaws.com", "*.SCAN.example."}
+	cfg.DebugHeaders = true
+
+	ctx := context.Background()
+	next := http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		rw.WriteHeader(http.StatusOK)
+	})
+
+	handler, err := simpleblocklist.New(ctx, next, cfg, "simpleblocklist")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	resolver := &fakeResolver{names: map[string][]string{
+		"198.51.100.7": {"ec2-198-51-100-7.compute-1.amazonaws.com."},
+		"198.51.100.8": {"www.example.org.", "Host1.Scan.Example."},
+		"198.51.100.9": {"mail.example.org."},
+	}}
+	simpleblocklist.SetResolver(handler, resolver)
+
+	tests := []struct {
+		desc           string
+		ip             string
+		expectedStatus int
+		expectedReason string
+	}{
+		{desc: "hostname matches pattern", ip: "198.51.100.7", expectedStatus: 403, expectedReason: "hostname:ec2-198-51-100-7.compute-1.amazonaws.com"},
+		{desc: "second hostname matches pattern", ip: "198.51.100.8", expectedStatus: 403, expectedReason: "hostname:host1.scan.example"},
+		{desc: "hostname does not match", ip: "198.51.100.9", expectedStatus: 200},
+		{desc: "no PTR record", ip: "203.0.113.10", expectedStatus: 200},
+		{desc: "blacklisted IP is not looked up", ip: "192.0.2.1", expectedStatus: 403, expectedReason: "blacklist:192.0.2.1/32"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			for i := 0; i < 2; i++ {
+				recorder := httptest.NewRecorder()
+				req, err := http.NewRequestWithContext(ctx, http.MethodGet, "http://localhost", nil)
+				if err != nil {
+					t.Fatal(err)
+				}
+				req.Header.Set("X-Forwarded-For", test.ip)
+
+				handler.ServeHTTP(recorder, req)
+
+				if recorder.Code != test.expectedStatus {
+					t.Errorf("got status code %d, want %d", recorder.Code, test.expectedStatus)
+				}
+				if reason := recorder.Header().Get("X-Blocked-Reason"); reason != test.expectedReason {
+					t.Errorf("got blocked reason %q, want %q", reason, test.expectedReason)
+				}
+			}
+		})
+	}
+
+	for ip, count := range resolver.lookups {
+		if count != 1 {
+			t.Errorf("got %d lookups for %s, want 1 as results are cached", count, ip)
+		}
+	}
+	if _, ok := resolver.lookups["192.0.2.1"]; ok {
+		t.Error("expected no lookup for a blacklisted IP")
+	}
+}
+
+func TestSimpleBlocklist_HostnameLookupFailure(t *testing.T) {
+	tests := []struct {
+		desc            string
+		action          string
+		ip              string
+		expectedStatus  int
+		expectedLookups int
+	}{
+		{desc: "failure allowed", action: "allow", ip: "203.0.113.10", expectedStatus: 200, expectedLookups: 2},
+		{desc: "failure denied", action: "deny", ip: "203.0.113.10", expectedStatus: 403, expectedLookups: 2},
+		{desc: "no PTR record with failures denied", action: "deny", ip: "203.0.113.11", expectedStatus: 200, expectedLookups: 1},
+		{desc: "unmatched hostname with failures denied", action: "deny", ip: "198.51.100.9", expectedStatus: 200, expectedLookups: 1},
+	}
+
+	// Failed lookups expire at once, unlike the other results
+	defer simpleblocklist.SetHostnameFailureCacheTTL(0)()
+
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			cfg := simpleblocklist.CreateConfig()
+			cfg.BlacklistPath = createBlacklistFile(t, "192.0.2.1\n")
+			cfg.BlockedHostnamePatterns = []string{"*.scan.example"}
+			cfg.HostnameLookupFailureAction = test.action
+
+			ctx := context.Background()
+			next := http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+				rw.WriteHeader(http.StatusOK)
+			})
+
+			handler, err := simpleblocklist.New(ctx, next, cfg, "simpleblocklist")
+			if err != nil {
+				t.Fatal(err)
+			}
+			resolver := &fakeResolver{
+				names:    map[string][]string{"198.51.100.9": {"mail.example.org."}},
+				failures: map[string]bool{"203.0.113.10": true},
+			}
+			simpleblocklist.SetResolver(handler, resolver)
+
+			for i := 0; i < 2; i++ {
+				recorder := httptest.NewRecorder()
+				req, err := http.NewRequestWithContext(ctx, http.MethodGet, "http://localhost", nil)
+				if err != nil {
+					t.Fatal(err)
+				}
+				req.Header.Set("X-Forwarded-For", test.ip)
+
+				handler.ServeHTTP(recorder, req)
+
+				if recorder.Code != test.expectedStatus {
+					t.Errorf("got status code %d, want %d", recorder.Code, test.expectedStatus)
+				}
+			}
+
+			if count := resolver.lookups[test.ip]; count != test.expectedLookups {
+				t.Errorf("got %d lookups, want %d", count, test.expectedLookups)
+			}
+		})
+	}
+}
+
+// fakeResolver answers reverse lookups from a fixed table and counts them. IPs in failures fail
+// with a timeout, and the other IPs missing from names have no PTR record.
+type fakeResolver struct {
+	mu       sync.Mutex
+	names    map[string][]string
+	failures map[string]bool
+	lookups  map[string]int
+}
+
+func (r *fakeResolver) LookupAddr(ctx context.Context, addr string) ([]string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.lookups == nil {
+		r.lookups = make(map[string]int)
+	}
+	r.lookups[addr]++
+
+	if r.failures[addr] {
+		return nil, &net.DNSError{Err: "i/o timeout", Name: addr, IsTimeout: true}
+	}
+	names, ok := r.names[addr]
+	if !ok {
+		return nil, &net.DNSError{Err: "no such host", Name: addr, IsNotFound: true}
+	}
+	return names, nil
+}
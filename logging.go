@@ -0,0 +1,108 @@
+package simpleblocklist
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"time"
+)
+
+const (
+	logFormatText = "text"
+	logFormatJSON = "json"
+)
+
+var jsonLogger = log.New(os.Stdout, "", 0)
+
+// logFields structured data attached to a log event, only emitted in the JSON format.
+type logFields map[string]interface{}
+
+// logger writes plugin log events either as prefixed plain text or as one JSON object per line.
+type logger struct {
+	json bool
+	name string
+	// info, warn and events write the text info, text warn and JSON events, to stdout through the
+	// package loggers unless the logs go to a file, see toFile.
+	info, warn, events *log.Logger
+}
+
+func newLogger(format, name string) *logger {
+	// Validate already checked the format
+	return &logger{json: format == logFormatJSON, name: name, info: infoLogger, warn: warnLogger, events: jsonLogger}
+}
+
+// newPluginLogger returns the logger of the plugin, writing to the log file of config if set.
+func newPluginLogger(ctx context.Context, config *Config, name string) (*logger, error) {
+	logger := newLogger(config.LogFormat, name)
+	if len(config.LogFilePath) == 0 {
+		return logger, nil
+	}
+
+	logFilePaths, err := expandEnvPaths([]string{config.LogFilePath})
+	if err != nil {
+		return nil, err
+	}
+	if err := logger.toFile(ctx, logFilePaths[0]); err != nil {
+		return nil, fmt.Errorf("failed to open log file: %v", err)
+	}
+	return logger, nil
+}
+
+// toFile makes l append its events to the file at path instead of writing them to stdout. The file
+// is created if needed, and closed once ctx is done.
+func (l *logger) toFile(ctx context.Context, path string) error {
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o644)
+	if err != nil {
+		return err
+	}
+
+	l.info = log.New(file, infoLogger.Prefix(), infoLogger.Flags())
+	l.warn = log.New(file, warnLogger.Prefix(), warnLogger.Flags())
+	l.events = log.New(file, "", 0)
+
+	go func() {
+		<-ctx.Done()
+		file.Close()
+	}()
+	return nil
+}
+
+// infof logs an informational event. The formatted message is used as-is in the text format
+// and as the "msg" field in the JSON format.
+func (l *logger) infof(fields logFields, format string, args ...interface{}) {
+	l.logf("info", fields, format, args...)
+}
+
+// warnf logs an event that needs the operator's attention.
+func (l *logger) warnf(fields logFields, format string, args ...interface{}) {
+	l.logf("warn", fields, format, args...)
+}
+
+func (l *logger) logf(level string, fields logFields, format string, args ...interface{}) {
+	if !l.json {
+		if level == "warn" {
+			l.warn.Printf(format, args...)
+		} else {
+			l.info.Printf(format, args...)
+		}
+		return
+	}
+
+	event := make(logFields, len(fields)+4)
+	for key, value := range fields {
+		event[key] = value
+	}
+	event["time"] = time.Now().UTC().Format(time.RFC3339)
+	event["level"] = level
+	event["middleware"] = l.name
+	event["msg"] = fmt.Sprintf(format, args...)
+
+	line, err := json.Marshal(event)
+	if err != nil {
+		l.info.Printf("Failed to encode log event: %v", err)
+		return
+	}
+	l.events.Print(string(line))
+}
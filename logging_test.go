@@ -0,0 +1,330 @@
+package simpleblocklist_test
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/LucaNori/traefik-simpleblocklist"
+)
+
+func TestSimpleBlocklist_JSONLogFormat(t *testing.T) {
+	var buf bytes.Buffer
+	defer simpleblocklist.SetLogOutput(&buf)()
+
+	cfg := simpleblocklist.CreateConfig()
+	cfg.BlacklistPath = createBlacklistFile(t, "198.51.100.0/24\n")
+	cfg.LogFormat = "json"
+
+	ctx := context.Background()
+	next := http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {})
+
+	handler, err := simpleblocklist.New(ctx, next, cfg, "simpleblocklist")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, "http://localhost", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("X-Forwarded-For", "198.51.100.7")
+
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	var denied map[string]interface{}
+	scanner := bufio.NewScanner(&buf)
+	for scanner.Scan() {
+		var event map[string]interface{}
+		if err := json.Unmarshal(scanner.Bytes(), &event); err != nil {
+			t.Fatalf("invalid JSON log line %q: %v", scanner.Text(), err)
+		}
+		for _, key := range []string{"time", "level", "middleware", "msg"} {
+			if _, ok := event[key]; !ok {
+				t.Errorf("log line %q is missing key %q", scanner.Text(), key)
+			}
+		}
+		if event["action"] == "deny" {
+			denied = event
+		}
+	}
+
+	if denied == nil {
+		t.Fatal("expected a log event for the denied request")
+	}
+	if denied["ip"] != "198.51.100.7" {
+		t.Errorf("got ip %v, want 198.51.100.7", denied["ip"])
+	}
+	if denied["matched_network"] != "198.51.100.0/24" {
+		t.Errorf("got matched_network %v, want 198.51.100.0/24", denied["matched_network"])
+	}
+}
+
+func TestSimpleBlocklist_TextLogFormat(t *testing.T) {
+	var buf bytes.Buffer
+	defer simpleblocklist.SetLogOutput(&buf)()
+
+	cfg := simpleblocklist.CreateConfig()
+	cfg.BlacklistPath = createBlacklistFile(t, "192.0.2.1\n")
+
+	ctx := context.Background()
+	next := http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {})
+
+	if _, err := simpleblocklist.New(ctx, next, cfg, "simpleblocklist"); err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.Contains(buf.String(), "INFO: SimpleBlocklist: ") {
+		t.Errorf("expected prefixed plain text logs, got %q", buf.String())
+	}
+}
+
+func TestSimpleBlocklist_InvalidLogFormat(t *testing.T) {
+	cfg := simpleblocklist.CreateConfig()
+	cfg.BlacklistPath = createBlacklistFile(t, "192.0.2.1\n")
+	cfg.LogFormat = "xml"
+
+	ctx := context.Background()
+	next := http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {})
+
+	_, err := simpleblocklist.New(ctx, next, cfg, "simpleblocklist")
+	if err == nil {
+		t.Error("expected error for an invalid log format")
+	}
+}
+
+func TestSimpleBlocklist_RequestIDLogging(t *testing.T) {
+	tests := []struct {
+		desc      string
+		requestID string
+		want      string
+	}{
+		{
+			desc:      "request ID from header",
+			requestID: "req-1234",
+			want:      "request denied [198.51.100.7] matched [198.51.100.0/24] - IP is blacklisted (request ID req-1234)",
+		},
+		{
+			desc: "generated request ID",
+			want: "request denied [198.51.100.7] matched [198.51.100.0/24] - IP is blacklisted (request ID ",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			var buf bytes.Buffer
+			defer simpleblocklist.SetLogOutput(&buf)()
+
+			cfg := simpleblocklist.CreateConfig()
+			cfg.BlacklistPath = createBlacklistFile(t, "198.51.100.0/24\n")
+
+			ctx := context.Background()
+			next := http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {})
+
+			handler, err := simpleblocklist.New(ctx, next, cfg, "simpleblocklist")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			req, err := http.NewRequestWithContext(ctx, http.MethodGet, "http://localhost", nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			req.Header.Set("X-Forwarded-For", "198.51.100.7")
+			if test.requestID != "" {
+				req.Header.Set("X-Request-ID", test.requestID)
+			}
+
+			handler.ServeHTTP(httptest.NewRecorder(), req)
+
+			if !strings.Contains(buf.String(), test.want) {
+				t.Errorf("expected log output to contain %q, got:\n%s", test.want, buf.String())
+			}
+		})
+	}
+}
+
+func TestSimpleBlocklist_LogAllRequests(t *testing.T) {
+	tests := []struct {
+		desc           string
+		logAllRequests bool
+		remoteAddr     string
+		want           string
+	}{
+		{
+			desc:           "public IP logged",
+			logAllRequests: true,
+			remoteAddr:     "203.0.113.10:1234",
+			want:           "simpleblocklist: request allowed [203.0.113.10] - public IP",
+		},
+		{
+			desc:           "local IP logged",
+			logAllRequests: true,
+			remoteAddr:     "10.0.0.1:1234",
+			want:           "Local IP allowed: 10.0.0.1",
+		},
+		{
+			desc:       "public IP not logged by default",
+			remoteAddr: "203.0.113.10:1234",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			cfg := simpleblocklist.CreateConfig()
+			cfg.BlacklistPath = createBlacklistFile(t, "192.0.2.1\n")
+			cfg.LogAllRequests = test.logAllRequests
+
+			ctx := context.Background()
+			next := http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {})
+
+			handler, err := simpleblocklist.New(ctx, next, cfg, "simpleblocklist")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			req, err := http.NewRequestWithContext(ctx, http.MethodGet, "http://localhost", nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			req.RemoteAddr = test.remoteAddr
+
+			var buf bytes.Buffer
+			restore := simpleblocklist.SetLogOutput(&buf)
+			handler.ServeHTTP(httptest.NewRecorder(), req)
+			restore()
+
+			if test.want == "" {
+				if buf.Len() != 0 {
+					t.Errorf("expected no log output, got:\n%s", buf.String())
+				}
+				return
+			}
+			if !strings.Contains(buf.String(), test.want) {
+				t.Errorf("expected log output to contain %q, got:\n%s", test.want, buf.String())
+			}
+		})
+	}
+}
+
+func TestSimpleBlocklist_AnonymizeLoggedIPs(t *testing.T) {
+	var buf bytes.Buffer
+	defer simpleblocklist.SetLogOutput(&buf)()
+
+	cfg := simpleblocklist.CreateConfig()
+	cfg.BlacklistPath = createBlacklistFile(t, "192.0.2.0/24\n2001:db8::/32\n")
+	cfg.LogFormat = "json"
+	cfg.LogAllRequests = true
+	cfg.AnonymizeLoggedIPs = true
+
+	ctx := context.Background()
+	next := http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		rw.WriteHeader(http.StatusOK)
+	})
+
+	handler, err := simpleblocklist.New(ctx, next, cfg, "simpleblocklist")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		ip             string
+		expectedStatus int
+		wantLogged     string
+	}{
+		{ip: "192.0.2.123", expectedStatus: 403, wantLogged: "192.0.2.0"},
+		{ip: "2001:db8:1234:5678:9abc::1", expectedStatus: 403, wantLogged: "2001:db8:1234::"},
+		{ip: "203.0.113.45", expectedStatus: 200, wantLogged: "203.0.113.0"},
+	}
+
+	for _, test := range tests {
+		buf.Reset()
+
+		recorder := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, "http://localhost", nil)
+		req.RemoteAddr = net.JoinHostPort(test.ip, "1234")
+
+		handler.ServeHTTP(recorder, req)
+
+		if recorder.Code != test.expectedStatus {
+			t.Errorf("%s: got status code %d, want %d", test.ip, recorder.Code, test.expectedStatus)
+		}
+
+		var event map[string]interface{}
+		if err := json.Unmarshal(bytes.TrimSpace(buf.Bytes()), &event); err != nil {
+			t.Fatalf("%s: invalid log event %q: %v", test.ip, buf.String(), err)
+		}
+		if event["ip"] != test.wantLogged {
+			t.Errorf("%s: got logged ip %v, want %s", test.ip, event["ip"], test.wantLogged)
+		}
+		if strings.Contains(buf.String(), test.ip) {
+			t.Errorf("%s: full IP found in log %q", test.ip, buf.String())
+		}
+	}
+}
+
+func TestSimpleBlocklist_LogFilePath(t *testing.T) {
+	var buf bytes.Buffer
+	defer simpleblocklist.SetLogOutput(&buf)()
+
+	logPath := filepath.Join(t.TempDir(), "simpleblocklist.log")
+	if err := os.WriteFile(logPath, []byte("previous line\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := simpleblocklist.CreateConfig()
+	cfg.BlacklistPath = createBlacklistFile(t, "198.51.100.0/24\n")
+	cfg.LogFilePath = logPath
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	next := http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {})
+
+	handler, err := simpleblocklist.New(ctx, next, cfg, "simpleblocklist")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, "http://localhost", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("X-Forwarded-For", "198.51.100.7")
+
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	logs, err := os.ReadFile(logPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(string(logs), "previous line\n") {
+		t.Errorf("expected the log file to be appended to, got %q", logs)
+	}
+	if want := "request denied [198.51.100.7]"; !strings.Contains(string(logs), want) {
+		t.Errorf("expected log line %q in the log file, got %q", want, logs)
+	}
+	if buf.Len() != 0 {
+		t.Errorf("expected nothing logged to stdout, got %q", buf.String())
+	}
+}
+
+func TestSimpleBlocklist_InvalidLogFilePath(t *testing.T) {
+	cfg := simpleblocklist.CreateConfig()
+	cfg.BlacklistPath = createBlacklistFile(t, "198.51.100.0/24\n")
+	cfg.LogFilePath = filepath.Join(t.TempDir(), "missing", "simpleblocklist.log")
+
+	next := http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {})
+
+	_, err := simpleblocklist.New(context.Background(), next, cfg, "simpleblocklist")
+	if err == nil || !strings.Contains(err.Error(), "failed to open log file") {
+		t.Errorf("got error %v, want a log file error", err)
+	}
+}
@@ -0,0 +1,41 @@
+package simpleblocklist
+
+import (
+	"sync"
+	"time"
+)
+
+// loadMetrics tracks how blacklist loads perform, so operators can alert on a slow or broken feed.
+type loadMetrics struct {
+	mu               sync.Mutex
+	lastLoadDuration time.Duration
+	reloadFailures   uint64
+}
+
+// loadMetricsSnapshot the metrics served on the status path.
+type loadMetricsSnapshot struct {
+	LastLoadDurationSeconds float64 `json:"last_load_duration_seconds"`
+	ReloadFailuresTotal     uint64  `json:"reload_failures_total"`
+}
+
+// observeLoad records the duration of a load that started at start, whether it succeeded or not.
+func (m *loadMetrics) observeLoad(start time.Time) {
+	m.mu.Lock()
+	m.lastLoadDuration = time.Since(start)
+	m.mu.Unlock()
+}
+
+func (m *loadMetrics) reloadFailed() {
+	m.mu.Lock()
+	m.reloadFailures++
+	m.mu.Unlock()
+}
+
+func (m *loadMetrics) snapshot() *loadMetricsSnapshot {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return &loadMetricsSnapshot{
+		LastLoadDurationSeconds: m.lastLoadDuration.Seconds(),
+		ReloadFailuresTotal:     m.reloadFailures,
+	}
+}
@@ -0,0 +1,362 @@
+package simpleblocklist
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"net"
+	"os"
+)
+
+// mmdbMetadataMarker precedes the metadata section at the end of a MaxMind DB file.
+var mmdbMetadataMarker = []byte("\xab\xcd\xefMaxMind.com")
+
+// mmdbDataSeparator the size of the zeroed gap between the search tree and the data section.
+const mmdbDataSeparator = 16
+
+// mmdbMaxDepth the maximum nesting of maps, arrays and pointers decoded, which stops pointer cycles
+// of a corrupted database from recursing forever.
+const mmdbMaxDepth = 64
+
+// mmdbMaxValues the maximum number of values decoded for one record. Pointers let a corrupted
+// database reuse the same nested values, which would otherwise decode to exponentially many.
+const mmdbMaxValues = 1 << 16
+
+// Data section field types of the MaxMind DB format.
+const (
+	mmdbExtended = iota
+	mmdbPointer
+	mmdbString
+	mmdbDouble
+	mmdbBytes
+	mmdbUint16
+	mmdbUint32
+	mmdbMap
+	mmdbInt32
+	mmdbUint64
+	mmdbUint128
+	mmdbArray
+	mmdbContainer
+	mmdbEndMarker
+	mmdbBool
+	mmdbFloat
+)
+
+// mmdbReader looks IPs up in a MaxMind DB file such as a GeoLite2 database. The whole file is read
+// into memory, so the reader holds no file descriptor or memory mapping and needs no closing, and
+// it only relies on packages the Yaegi interpreter Traefik loads plugins with provides.
+type mmdbReader struct {
+	buffer     []byte
+	nodeCount  uint
+	recordSize uint
+	ipVersion  uint
+	// treeSize the size of the search tree in bytes.
+	treeSize uint
+	// ipv4Start the node IPv4 lookups start from in an IPv6 database, the one of ::/96.
+	ipv4Start uint
+}
+
+// openMMDB reads the MaxMind DB file at path.
+func openMMDB(path string) (*mmdbReader, error) {
+	buffer, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return newMMDBReader(buffer)
+}
+
+// newMMDBReader parses the metadata of the MaxMind DB in buffer.
+func newMMDBReader(buffer []byte) (*mmdbReader, error) {
+	start := bytes.LastIndex(buffer, mmdbMetadataMarker)
+	if start < 0 {
+		return nil, errors.New("invalid MaxMind DB file: metadata not found")
+	}
+	start += len(mmdbMetadataMarker)
+
+	value, _, err := (&mmdbDecoder{buffer: buffer[start:]}).decode(0, 0)
+	if err != nil {
+		return nil, fmt.Errorf("invalid MaxMind DB metadata: %v", err)
+	}
+	metadata, ok := value.(map[string]interface{})
+	if !ok {
+		return nil, errors.New("invalid MaxMind DB metadata: not a map")
+	}
+	nodeCount, _ := metadata["node_count"].(uint64)
+	recordSize, _ := metadata["record_size"].(uint64)
+	ipVersion, _ := metadata["ip_version"].(uint64)
+
+	// Each node takes at least 6 bytes, which also keeps the tree size from overflowing
+	if nodeCount > uint64(len(buffer)) {
+		return nil, errors.New("invalid MaxMind DB file: search tree exceeds the file")
+	}
+
+	r := &mmdbReader{
+		buffer:     buffer,
+		nodeCount:  uint(nodeCount),
+		recordSize: uint(recordSize),
+		ipVersion:  uint(ipVersion),
+	}
+	switch r.recordSize {
+	case 24, 28, 32:
+	default:
+		return nil, fmt.Errorf("unsupported MaxMind DB record size %d", r.recordSize)
+	}
+	if r.ipVersion != 4 && r.ipVersion != 6 {
+		return nil, fmt.Errorf("unsupported MaxMind DB IP version %d", r.ipVersion)
+	}
+	r.treeSize = r.nodeCount * r.recordSize / 4
+	if r.treeSize+mmdbDataSeparator > uint(len(buffer)) {
+		return nil, errors.New("invalid MaxMind DB file: search tree exceeds the file")
+	}
+
+	if r.ipVersion == 6 {
+		for i := 0; i < 96 && r.ipv4Start < r.nodeCount; i++ {
+			r.ipv4Start = r.record(r.ipv4Start, 0)
+		}
+	}
+	return r, nil
+}
+
+// lookup returns the record of the network ip belongs to, or nil if ip isn't in the database.
+func (r *mmdbReader) lookup(ip net.IP) (map[string]interface{}, error) {
+	node, bits := uint(0), 128
+	if ip4 := ip.To4(); ip4 != nil {
+		ip, bits = ip4, 32
+		if r.ipVersion == 6 {
+			node = r.ipv4Start
+		}
+	} else if r.ipVersion == 4 {
+		return nil, fmt.Errorf("can't look up IPv6 address %s in an IPv4 database", ip)
+	}
+
+	for i := 0; i < bits && node < r.nodeCount; i++ {
+		node = r.record(node, uint(ip[i>>3]>>(7-uint(i&7)))&1)
+	}
+	if node == r.nodeCount {
+		return nil, nil
+	}
+	// Records point past the separator, an offset computed from a smaller one would wrap around
+	if node < r.nodeCount+mmdbDataSeparator {
+		return nil, fmt.Errorf("invalid MaxMind DB record: node %d points into the data separator", node)
+	}
+
+	offset := node - r.nodeCount - mmdbDataSeparator
+	decoder := &mmdbDecoder{buffer: r.buffer[r.treeSize+mmdbDataSeparator:]}
+	value, _, err := decoder.decode(offset, 0)
+	if err != nil {
+		return nil, fmt.Errorf("invalid MaxMind DB record: %v", err)
+	}
+	record, _ := value.(map[string]interface{})
+	return record, nil
+}
+
+// record returns the left (bit 0) or right (bit 1) record of node.
+func (r *mmdbReader) record(node, bit uint) uint {
+	b := r.buffer[node*r.recordSize/4:]
+	switch r.recordSize {
+	case 24:
+		b = b[bit*3:]
+		return uint(b[0])<<16 | uint(b[1])<<8 | uint(b[2])
+	case 28:
+		if bit == 0 {
+			return uint(b[3]&0xf0)<<20 | uint(b[0])<<16 | uint(b[1])<<8 | uint(b[2])
+		}
+		return uint(b[3]&0x0f)<<24 | uint(b[4])<<16 | uint(b[5])<<8 | uint(b[6])
+	default:
+		return uint(binary.BigEndian.Uint32(b[bit*4:]))
+	}
+}
+
+// mmdbDecoder decodes values of a MaxMind DB data section. Maps decode to map[string]interface{},
+// arrays to []interface{}, unsigned integers to uint64 and the other types to their Go equivalent.
+type mmdbDecoder struct {
+	buffer []byte
+	// values the number of values decoded so far, see mmdbMaxValues.
+	values int
+}
+
+// decode returns the value at offset and the offset following it. depth is the number of maps,
+// arrays and pointers the value is nested in.
+func (d *mmdbDecoder) decode(offset uint, depth int) (interface{}, uint, error) {
+	if depth > mmdbMaxDepth {
+		return nil, 0, fmt.Errorf("data nested deeper than %d levels", mmdbMaxDepth)
+	}
+	if d.values++; d.values > mmdbMaxValues {
+		return nil, 0, fmt.Errorf("record of more than %d values", mmdbMaxValues)
+	}
+	kind, size, offset, err := d.control(offset)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	if kind == mmdbPointer {
+		target, next, err := d.pointer(size, offset)
+		if err != nil {
+			return nil, 0, err
+		}
+		value, _, err := d.decode(target, depth+1)
+		return value, next, err
+	}
+
+	switch kind {
+	case mmdbMap:
+		return d.decodeMap(size, offset, depth)
+	case mmdbArray:
+		return d.decodeArray(size, offset, depth)
+	case mmdbBool:
+		return size != 0, offset, nil
+	}
+
+	end := offset + size
+	if end > uint(len(d.buffer)) {
+		return nil, 0, errors.New("value exceeds the data section")
+	}
+	b := d.buffer[offset:end]
+	switch kind {
+	case mmdbString:
+		return string(b), end, nil
+	case mmdbBytes, mmdbUint128:
+		// 128-bit integers are too large for the records used here, kept as raw bytes
+		return append([]byte(nil), b...), end, nil
+	default:
+		value, err := decodeMMDBNumber(kind, b)
+		return value, end, err
+	}
+}
+
+// decodeMap decodes the size entries of the map at offset.
+func (d *mmdbDecoder) decodeMap(size, offset uint, depth int) (interface{}, uint, error) {
+	// Keys and values take at least one byte each, a larger size can only be corrupted
+	if size > (uint(len(d.buffer))-offset)/2 {
+		return nil, 0, fmt.Errorf("map of %d entries exceeds the data section", size)
+	}
+	value := make(map[string]interface{}, size)
+	for i := uint(0); i < size; i++ {
+		var key, item interface{}
+		var err error
+		if key, offset, err = d.decode(offset, depth+1); err != nil {
+			return nil, 0, err
+		}
+		if item, offset, err = d.decode(offset, depth+1); err != nil {
+			return nil, 0, err
+		}
+		name, ok := key.(string)
+		if !ok {
+			return nil, 0, errors.New("map key is not a string")
+		}
+		value[name] = item
+	}
+	return value, offset, nil
+}
+
+// decodeArray decodes the size items of the array at offset.
+func (d *mmdbDecoder) decodeArray(size, offset uint, depth int) (interface{}, uint, error) {
+	if size > uint(len(d.buffer))-offset {
+		return nil, 0, fmt.Errorf("array of %d items exceeds the data section", size)
+	}
+	value := make([]interface{}, 0, size)
+	for i := uint(0); i < size; i++ {
+		var item interface{}
+		var err error
+		if item, offset, err = d.decode(offset, depth+1); err != nil {
+			return nil, 0, err
+		}
+		value = append(value, item)
+	}
+	return value, offset, nil
+}
+
+// decodeMMDBNumber decodes the payload b of a numeric field of the given kind.
+func decodeMMDBNumber(kind int, b []byte) (interface{}, error) {
+	switch kind {
+	case mmdbDouble:
+		if len(b) != 8 {
+			return nil, fmt.Errorf("invalid double size %d", len(b))
+		}
+		return math.Float64frombits(binary.BigEndian.Uint64(b)), nil
+	case mmdbFloat:
+		if len(b) != 4 {
+			return nil, fmt.Errorf("invalid float size %d", len(b))
+		}
+		return float64(math.Float32frombits(binary.BigEndian.Uint32(b))), nil
+	case mmdbUint16, mmdbUint32, mmdbUint64, mmdbInt32:
+		if len(b) > 8 {
+			return nil, fmt.Errorf("invalid integer size %d", len(b))
+		}
+		var n uint64
+		for _, c := range b {
+			n = n<<8 | uint64(c)
+		}
+		if kind == mmdbInt32 {
+			return int64(int32(n)), nil
+		}
+		return n, nil
+	default:
+		return nil, fmt.Errorf("unsupported field type %d", kind)
+	}
+}
+
+// control reads the control byte at offset, and returns the field type, the field size and the
+// offset of the field payload.
+func (d *mmdbDecoder) control(offset uint) (kind int, size uint, next uint, err error) {
+	if offset >= uint(len(d.buffer)) {
+		return 0, 0, 0, errors.New("unexpected end of the data section")
+	}
+	control := d.buffer[offset]
+	offset++
+
+	kind = int(control >> 5)
+	if kind == mmdbExtended {
+		if offset >= uint(len(d.buffer)) {
+			return 0, 0, 0, errors.New("unexpected end of the data section")
+		}
+		kind = 7 + int(d.buffer[offset])
+		offset++
+	}
+
+	size = uint(control & 0x1f)
+	if kind == mmdbPointer || size < 29 {
+		return kind, size, offset, nil
+	}
+	extra := size - 28
+	if offset+extra > uint(len(d.buffer)) {
+		return 0, 0, 0, errors.New("unexpected end of the data section")
+	}
+	var n uint
+	for _, c := range d.buffer[offset : offset+extra] {
+		n = n<<8 | uint(c)
+	}
+	switch extra {
+	case 1:
+		size = 29 + n
+	case 2:
+		size = 285 + n
+	default:
+		size = 65821 + n
+	}
+	return kind, size, offset + extra, nil
+}
+
+// pointer returns the data section offset a pointer field of the given size points to, and the
+// offset following the pointer.
+func (d *mmdbDecoder) pointer(size, offset uint) (uint, uint, error) {
+	length := (size>>3)&0x3 + 1
+	if offset+length > uint(len(d.buffer)) {
+		return 0, 0, errors.New("unexpected end of the data section")
+	}
+	var n uint
+	for _, c := range d.buffer[offset : offset+length] {
+		n = n<<8 | uint(c)
+	}
+	switch length {
+	case 1:
+		n |= (size & 0x7) << 8
+	case 2:
+		n = (n | (size&0x7)<<16) + 2048
+	case 3:
+		n = (n | (size&0x7)<<24) + 526336
+	}
+	return n, offset + length, nil
+}
@@ -0,0 +1,133 @@
+package simpleblocklist
+
+import (
+	"net"
+	"os"
+	"strings"
+	"testing"
+)
+
+// encodeMMDBUint16 encodes n as a MaxMind DB uint16 field.
+func encodeMMDBUint16(n byte) []byte {
+	return []byte{mmdbUint16<<5 | 1, n}
+}
+
+// encodeMMDBString encodes s as a MaxMind DB string field.
+func encodeMMDBString(s string) []byte {
+	return append([]byte{mmdbString<<5 | byte(len(s))}, s...)
+}
+
+// buildMMDB returns an IPv4 database of a single node whose 24-bit records are left and right,
+// followed by data and by metadata declaring nodeCount nodes.
+func buildMMDB(left, right uint32, data []byte, nodeCount []byte) []byte {
+	var db []byte
+	for _, record := range []uint32{left, right} {
+		db = append(db, byte(record>>16), byte(record>>8), byte(record))
+	}
+	db = append(db, make([]byte, mmdbDataSeparator)...)
+	db = append(db, data...)
+	db = append(db, mmdbMetadataMarker...)
+	db = append(db, mmdbMap<<5|3)
+	db = append(db, encodeMMDBString("node_count")...)
+	db = append(db, nodeCount...)
+	db = append(db, encodeMMDBString("record_size")...)
+	db = append(db, encodeMMDBUint16(24)...)
+	db = append(db, encodeMMDBString("ip_version")...)
+	db = append(db, encodeMMDBUint16(4)...)
+	return db
+}
+
+// sharedArrays returns levels nested arrays, each holding two pointers to the next one, which
+// decode to 2^levels values from a few bytes.
+func sharedArrays(levels int) []byte {
+	var data []byte
+	for i := 1; i <= levels; i++ {
+		next := byte(6 * i)
+		data = append(data, mmdbExtended<<5|2, mmdbArray-7, mmdbPointer<<5, next, mmdbPointer<<5, next)
+	}
+	return append(data, mmdbString<<5)
+}
+
+func TestMMDBReader_Corrupt(t *testing.T) {
+	// dataStart the record value pointing at the start of the data section of a single node tree.
+	const dataStart = 1 + mmdbDataSeparator
+
+	tests := []struct {
+		desc string
+		db   []byte
+		err  string
+	}{
+		{
+			desc: "record pointing into the data separator",
+			db:   buildMMDB(2, 1, encodeMMDBString("x"), encodeMMDBUint16(1)),
+			err:  "points into the data separator",
+		},
+		{
+			desc: "pointer to itself",
+			db:   buildMMDB(dataStart, 1, []byte{mmdbPointer << 5, 0}, encodeMMDBUint16(1)),
+			err:  "nested deeper",
+		},
+		{
+			desc: "map holding a pointer to itself",
+			db:   buildMMDB(dataStart, 1, append(append([]byte{mmdbMap<<5 | 1}, encodeMMDBString("a")...), mmdbPointer<<5, 0), encodeMMDBUint16(1)),
+			err:  "nested deeper",
+		},
+		{
+			desc: "pointers reusing the same nested arrays",
+			db:   buildMMDB(dataStart, 1, sharedArrays(30), encodeMMDBUint16(1)),
+			err:  "record of more than 65536 values",
+		},
+		{
+			desc: "map larger than the data section",
+			db:   buildMMDB(dataStart, 1, []byte{mmdbMap<<5 | 31, 0xff, 0xff, 0xff}, encodeMMDBUint16(1)),
+			err:  "map of 16843036 entries exceeds the data section",
+		},
+		{
+			desc: "array larger than the data section",
+			db:   buildMMDB(dataStart, 1, []byte{mmdbExtended<<5 | 31, mmdbArray - 7, 0xff, 0xff, 0xff}, encodeMMDBUint16(1)),
+			err:  "array of 16843036 items exceeds the data section",
+		},
+		{
+			desc: "node count larger than the file",
+			db:   buildMMDB(dataStart, 1, encodeMMDBString("x"), []byte{mmdbExtended<<5 | 8, mmdbUint64 - 7, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff}),
+			err:  "search tree exceeds the file",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			r, err := newMMDBReader(test.db)
+			if err == nil {
+				_, err = r.lookup(net.ParseIP("0.0.0.1"))
+			}
+
+			if err == nil || !strings.Contains(err.Error(), test.err) {
+				t.Errorf("got error %v, want an error containing %q", err, test.err)
+			}
+		})
+	}
+}
+
+func FuzzMMDBReader(f *testing.F) {
+	for _, path := range []string{"testdata/GeoLite2-Country-Test.mmdb", "testdata/GeoLite2-ASN-Test.mmdb"} {
+		db, err := os.ReadFile(path)
+		if err != nil {
+			f.Fatal(err)
+		}
+		f.Add(db)
+	}
+	f.Add(buildMMDB(1+mmdbDataSeparator, 1, []byte{mmdbPointer << 5, 0}, encodeMMDBUint16(1)))
+	f.Add(buildMMDB(1+mmdbDataSeparator, 1, sharedArrays(30), encodeMMDBUint16(1)))
+
+	f.Fuzz(func(t *testing.T, db []byte) {
+		r, err := newMMDBReader(db)
+		if err != nil {
+			return
+		}
+
+		// Corrupted databases may fail lookups, but must never panic or hang
+		for _, ip := range []string{"0.0.0.1", "81.2.69.160", "2001:218::1", "::1"} {
+			_, _ = r.lookup(net.ParseIP(ip))
+		}
+	})
+}
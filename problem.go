@@ -0,0 +1,38 @@
+package simpleblocklist
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+const deniedResponseFormatProblemJSON = "problem+json"
+
+// problemDetails an RFC 7807 problem details body.
+type problemDetails struct {
+	Type   string `json:"type"`
+	Title  string `json:"title"`
+	Status int    `json:"status"`
+	Detail string `json:"detail"`
+}
+
+// writeProblem answers a denied request with an RFC 7807 problem details body. The detail stays
+// generic, so the response never tells the client which network or rule matched it.
+func (a *SimpleBlocklist) writeProblem(rw http.ResponseWriter, ip string, statusCode int) {
+	body, err := json.Marshal(problemDetails{
+		Type:   "about:blank",
+		Title:  http.StatusText(statusCode),
+		Status: statusCode,
+		Detail: "The request was denied based on the client IP address.",
+	})
+	if err != nil {
+		a.logger.warnf(logFields{"ip": a.logIP(ip)}, "%s: failed to encode the problem details: %v", a.name, err)
+		rw.WriteHeader(statusCode)
+		return
+	}
+
+	rw.Header().Set("Content-Type", "application/problem+json")
+	rw.WriteHeader(statusCode)
+	if _, err := rw.Write(body); err != nil {
+		a.logger.warnf(logFields{"ip": a.logIP(ip)}, "%s: failed to write the problem details: %v", a.name, err)
+	}
+}
@@ -0,0 +1,176 @@
+package simpleblocklist
+
+import (
+	"context"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// rateLimitEvictInterval the minimum time between two evictions triggered by a full limiter.
+const rateLimitEvictInterval = time.Second
+
+// maxRateLimitEntries bounds the number of IPs or networks whose requests are counted.
+var maxRateLimitEntries int64 = 100000
+
+// rateLimiter temporarily blocks IPs that send more than limit requests within a sliding window.
+// Requests are counted per IP, or per network when an aggregate mask is set for the address family.
+type rateLimiter struct {
+	limit   int
+	window  time.Duration
+	mask4   net.IPMask
+	mask6   net.IPMask
+	clients sync.Map // IP or network string -> *rateLimitEntry
+	// size the number of entries in clients.
+	size int64
+
+	mu sync.Mutex
+	// lastEvict when a full limiter last evicted stale entries.
+	lastEvict time.Time
+}
+
+// rateLimitEntry the recent request times of one IP, and until when the IP is blocked.
+type rateLimitEntry struct {
+	mu           sync.Mutex
+	hits         []time.Time
+	blockedUntil time.Time
+	// evicted whether the entry was removed from the limiter, in which case a new one is used.
+	evicted bool
+}
+
+// newRateLimiter returns the rate limiter of config, or nil if rate limiting is disabled. The
+// aggregate masks are prefix lengths used to count requests per network, 0 counts them per IP.
+func newRateLimiter(config *Config, logger *logger) *rateLimiter {
+	if config.RateLimitRequests <= 0 {
+		return nil
+	}
+
+	logger.infof(nil, "Rate limit: %d requests per %ds", config.RateLimitRequests, config.RateLimitWindowSeconds)
+	r := &rateLimiter{limit: config.RateLimitRequests, window: time.Duration(config.RateLimitWindowSeconds) * time.Second}
+	if config.RateLimitAggregateMask > 0 {
+		r.mask4 = net.CIDRMask(config.RateLimitAggregateMask, 8*net.IPv4len)
+	}
+	if config.RateLimitAggregateMaskIPv6 > 0 {
+		r.mask6 = net.CIDRMask(config.RateLimitAggregateMaskIPv6, 8*net.IPv6len)
+	}
+	if r.mask4 != nil || r.mask6 != nil {
+		logger.infof(nil, "Rate limit aggregated per /%d (IPv4) and /%d (IPv6) networks",
+			config.RateLimitAggregateMask, config.RateLimitAggregateMaskIPv6)
+	}
+	return r
+}
+
+// key returns the counter key of ip: the IP itself, or its masked network such as "198.51.100.0/24".
+func (r *rateLimiter) key(ip net.IP) string {
+	mask := r.mask6
+	if ip4 := ip.To4(); ip4 != nil {
+		ip, mask = ip4, r.mask4
+	}
+	if mask == nil {
+		return ip.String()
+	}
+	return (&net.IPNet{IP: ip.Mask(mask), Mask: mask}).String()
+}
+
+// allow records a request from ip and reports whether it is within the limit. An IP or network that
+// exceeds the limit is blocked for the window duration, during which its requests are not counted.
+// Once maxRateLimitEntries IPs or networks are counted and none of them is stale, the requests of
+// new ones are allowed without being counted, so a flood of addresses can't exhaust the memory.
+func (r *rateLimiter) allow(ip net.IP, now time.Time) bool {
+	key := r.key(ip)
+	var entry *rateLimitEntry
+	for entry == nil {
+		value, ok := r.clients.Load(key)
+		if !ok {
+			if atomic.LoadInt64(&r.size) >= maxRateLimitEntries && !r.evictFull(now) {
+				return true
+			}
+			var loaded bool
+			if value, loaded = r.clients.LoadOrStore(key, &rateLimitEntry{}); !loaded {
+				atomic.AddInt64(&r.size, 1)
+			}
+		}
+
+		entry = value.(*rateLimitEntry)
+		entry.mu.Lock()
+		if entry.evicted {
+			// Removed between the lookup and the lock, retry with the entry that replaces it
+			entry.mu.Unlock()
+			entry = nil
+		}
+	}
+	defer entry.mu.Unlock()
+
+	if now.Before(entry.blockedUntil) {
+		return false
+	}
+
+	entry.hits = append(entry.pruneHits(now.Add(-r.window)), now)
+	if len(entry.hits) > r.limit {
+		entry.hits = entry.hits[:0]
+		entry.blockedUntil = now.Add(r.window)
+		return false
+	}
+
+	return true
+}
+
+// pruneHits drops the hits before since. The caller must hold entry.mu.
+func (e *rateLimitEntry) pruneHits(since time.Time) []time.Time {
+	i := 0
+	for i < len(e.hits) && e.hits[i].Before(since) {
+		i++
+	}
+	return append(e.hits[:0], e.hits[i:]...)
+}
+
+// evict removes the counters of IPs that are neither blocked nor seen within the window.
+func (r *rateLimiter) evict(now time.Time) {
+	r.clients.Range(func(key, value interface{}) bool {
+		entry := value.(*rateLimitEntry)
+
+		entry.mu.Lock()
+		entry.hits = entry.pruneHits(now.Add(-r.window))
+		if !now.Before(entry.blockedUntil) && len(entry.hits) == 0 {
+			entry.evicted = true
+			r.clients.Delete(key)
+			atomic.AddInt64(&r.size, -1)
+		}
+		entry.mu.Unlock()
+		return true
+	})
+}
+
+// evictFull evicts stale counters of a full limiter, at most once per rateLimitEvictInterval so a
+// flood of new addresses doesn't scan the counters on every request, and reports whether there is
+// room for a new counter.
+func (r *rateLimiter) evictFull(now time.Time) bool {
+	r.mu.Lock()
+	due := now.Sub(r.lastEvict) >= rateLimitEvictInterval
+	if due {
+		r.lastEvict = now
+	}
+	r.mu.Unlock()
+
+	if due {
+		r.evict(now)
+	}
+	return atomic.LoadInt64(&r.size) < maxRateLimitEntries
+}
+
+// evictPeriodically evicts stale counters once per window until ctx is done, which bounds the
+// memory used by IPs that stopped sending requests.
+func (r *rateLimiter) evictPeriodically(ctx context.Context) {
+	ticker := time.NewTicker(r.window)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			r.evict(now)
+		}
+	}
+}
@@ -0,0 +1,270 @@
+package simpleblocklist_test
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/LucaNori/traefik-simpleblocklist"
+)
+
+func TestSimpleBlocklist_RateLimit(t *testing.T) {
+	cfg := simpleblocklist.CreateConfig()
+	cfg.BlacklistPath = createBlacklistFile(t, "192.0.2.1\n")
+	cfg.RateLimitRequests = 3
+	cfg.RateLimitWindowSeconds = 60
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	next := http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		rw.WriteHeader(http.StatusOK)
+	})
+
+	handler, err := simpleblocklist.New(ctx, next, cfg, "simpleblocklist")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	serve := func(ip string) int {
+		recorder := httptest.NewRecorder()
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, "http://localhost", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.RemoteAddr = net.JoinHostPort(ip, "1234")
+
+		handler.ServeHTTP(recorder, req)
+		return recorder.Code
+	}
+
+	for i := 1; i <= 3; i++ {
+		if code := serve("203.0.113.10"); code != http.StatusOK {
+			t.Fatalf("request %d: got status code %d, want %d", i, code, http.StatusOK)
+		}
+	}
+
+	for i := 4; i <= 6; i++ {
+		if code := serve("203.0.113.10"); code != http.StatusForbidden {
+			t.Errorf("request %d: got status code %d, want %d", i, code, http.StatusForbidden)
+		}
+	}
+
+	if code := serve("203.0.113.11"); code != http.StatusOK {
+		t.Errorf("other IP: got status code %d, want %d", code, http.StatusOK)
+	}
+}
+
+func TestSimpleBlocklist_RateLimitAggregateMask(t *testing.T) {
+	cfg := simpleblocklist.CreateConfig()
+	cfg.BlacklistPath = createBlacklistFile(t, "192.0.2.1\n")
+	cfg.RateLimitRequests = 4
+	cfg.RateLimitWindowSeconds = 60
+	cfg.RateLimitAggregateMask = 24
+	cfg.RateLimitAggregateMaskIPv6 = 64
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	next := http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		rw.WriteHeader(http.StatusOK)
+	})
+
+	handler, err := simpleblocklist.New(ctx, next, cfg, "simpleblocklist")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	serve := func(ip string) int {
+		recorder := httptest.NewRecorder()
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, "http://localhost", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.RemoteAddr = net.JoinHostPort(ip, "1234")
+
+		handler.ServeHTTP(recorder, req)
+		return recorder.Code
+	}
+
+	tests := []struct {
+		ip             string
+		expectedStatus int
+	}{
+		{ip: "203.0.113.1", expectedStatus: 200},
+		{ip: "203.0.113.2", expectedStatus: 200},
+		{ip: "203.0.113.3", expectedStatus: 200},
+		{ip: "203.0.113.4", expectedStatus: 200},
+		{ip: "203.0.113.5", expectedStatus: 403},
+		{ip: "203.0.113.1", expectedStatus: 403},
+		{ip: "198.51.100.1", expectedStatus: 200},
+		{ip: "2001:db8::1", expectedStatus: 200},
+		{ip: "2001:db8::2", expectedStatus: 200},
+		{ip: "2001:db8::3", expectedStatus: 200},
+		{ip: "2001:db8::4", expectedStatus: 200},
+		{ip: "2001:db8::5", expectedStatus: 403},
+		{ip: "2001:db8:0:1::1", expectedStatus: 200},
+	}
+
+	for i, test := range tests {
+		if code := serve(test.ip); code != test.expectedStatus {
+			t.Errorf("request %d from %s: got status code %d, want %d", i+1, test.ip, code, test.expectedStatus)
+		}
+	}
+}
+
+func TestSimpleBlocklist_RateLimitClientIP(t *testing.T) {
+	tests := []struct {
+		desc           string
+		trustedProxies []string
+		remoteAddr     string
+		// forwardedFor returns the X-Forwarded-For header of the i-th request.
+		forwardedFor   func(i int) string
+		expectedStatus []int
+	}{
+		{
+			desc:           "forwarded IPs rotated by an untrusted peer",
+			remoteAddr:     "203.0.113.10:1234",
+			forwardedFor:   func(i int) string { return fmt.Sprintf("198.51.100.%d", i) },
+			expectedStatus: []int{200, 200, 403, 403},
+		},
+		{
+			desc:           "clients behind a trusted proxy",
+			trustedProxies: []string{"10.0.0.2"},
+			remoteAddr:     "10.0.0.2:1234",
+			forwardedFor:   func(i int) string { return fmt.Sprintf("198.51.100.%d", i%2) },
+			expectedStatus: []int{200, 200, 200, 200, 403, 403},
+		},
+		{
+			desc:           "forwarded IPs rotated behind a trusted proxy",
+			trustedProxies: []string{"10.0.0.2"},
+			remoteAddr:     "10.0.0.2:1234",
+			forwardedFor:   func(i int) string { return fmt.Sprintf("198.51.100.%d, 203.0.113.10", i) },
+			expectedStatus: []int{200, 200, 403, 403},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			cfg := simpleblocklist.CreateConfig()
+			cfg.BlacklistPath = createBlacklistFile(t, "192.0.2.1\n")
+			cfg.RateLimitRequests = 2
+			cfg.RateLimitWindowSeconds = 60
+			cfg.TrustedProxies = test.trustedProxies
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			next := http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+				rw.WriteHeader(http.StatusOK)
+			})
+
+			handler, err := simpleblocklist.New(ctx, next, cfg, "simpleblocklist")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			for i, expectedStatus := range test.expectedStatus {
+				recorder := httptest.NewRecorder()
+				req, err := http.NewRequestWithContext(ctx, http.MethodGet, "http://localhost", nil)
+				if err != nil {
+					t.Fatal(err)
+				}
+				req.RemoteAddr = test.remoteAddr
+				req.Header.Set("X-Forwarded-For", test.forwardedFor(i))
+
+				handler.ServeHTTP(recorder, req)
+
+				if recorder.Code != expectedStatus {
+					t.Errorf("request %d: got status code %d, want %d", i+1, recorder.Code, expectedStatus)
+				}
+			}
+		})
+	}
+}
+
+func TestSimpleBlocklist_RateLimitMaxEntries(t *testing.T) {
+	defer simpleblocklist.SetMaxRateLimitEntries(2)()
+
+	cfg := simpleblocklist.CreateConfig()
+	cfg.BlacklistPath = createBlacklistFile(t, "192.0.2.1\n")
+	cfg.RateLimitRequests = 1
+	cfg.RateLimitWindowSeconds = 60
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	next := http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		rw.WriteHeader(http.StatusOK)
+	})
+
+	handler, err := simpleblocklist.New(ctx, next, cfg, "simpleblocklist")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	serve := func(ip string) int {
+		recorder := httptest.NewRecorder()
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, "http://localhost", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.RemoteAddr = net.JoinHostPort(ip, "1234")
+
+		handler.ServeHTTP(recorder, req)
+		return recorder.Code
+	}
+
+	// The first two IPs fill the limiter: they stay counted, while a third one isn't
+	tests := []struct {
+		ip             string
+		expectedStatus int
+	}{
+		{ip: "203.0.113.1", expectedStatus: 200},
+		{ip: "203.0.113.2", expectedStatus: 200},
+		{ip: "203.0.113.3", expectedStatus: 200},
+		{ip: "203.0.113.3", expectedStatus: 200},
+		{ip: "203.0.113.1", expectedStatus: 403},
+		{ip: "203.0.113.2", expectedStatus: 403},
+	}
+
+	for i, test := range tests {
+		if code := serve(test.ip); code != test.expectedStatus {
+			t.Errorf("request %d from %s: got status code %d, want %d", i+1, test.ip, code, test.expectedStatus)
+		}
+	}
+}
+
+func TestSimpleBlocklist_InvalidRateLimit(t *testing.T) {
+	tests := []struct {
+		desc     string
+		requests int
+		window   int
+		mask     int
+	}{
+		{desc: "negative limit", requests: -1, window: 60},
+		{desc: "missing window", requests: 10, window: 0},
+		{desc: "negative window", requests: 10, window: -5},
+		{desc: "aggregate mask too long", requests: 10, window: 60, mask: 33},
+		{desc: "negative aggregate mask", requests: 10, window: 60, mask: -1},
+	}
+
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			cfg := simpleblocklist.CreateConfig()
+			cfg.BlacklistPath = createBlacklistFile(t, "192.0.2.1\n")
+			cfg.RateLimitRequests = test.requests
+			cfg.RateLimitWindowSeconds = test.window
+			cfg.RateLimitAggregateMask = test.mask
+
+			next := http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {})
+
+			if _, err := simpleblocklist.New(context.Background(), next, cfg, "simpleblocklist"); err == nil {
+				t.Error("expected error for an invalid rate limit")
+			}
+		})
+	}
+}
@@ -0,0 +1,146 @@
+package simpleblocklist
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// redisTimeout caps how long loading the Redis set may take, connection included.
+	redisTimeout = 5 * time.Second
+	// maxRedisBulkLength caps the length of a set member, which are IP addresses or networks.
+	maxRedisBulkLength = maxLineLength
+)
+
+// SetStore returns the members of a set, such as a Redis set shared by several Traefik instances.
+type SetStore interface {
+	SetMembers(ctx context.Context, key string) ([]string, error)
+}
+
+// newSetStore creates the store of the set at addr. It is a variable so tests can replace the
+// Redis client with an in-memory store.
+var newSetStore = func(addr string) SetStore {
+	return &redisClient{addr: addr}
+}
+
+// redisClient a minimal Redis client that only supports SMEMBERS. It speaks the RESP protocol
+// directly, so the plugin doesn't depend on a client library that Yaegi may not be able to run.
+type redisClient struct {
+	addr string
+}
+
+// SetMembers returns the members of the Redis set at key, opening a new connection for each call
+// since the set is only loaded on start and reload.
+func (c *redisClient) SetMembers(ctx context.Context, key string) ([]string, error) {
+	ctx, cancel := context.WithTimeout(ctx, redisTimeout)
+	defer cancel()
+
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", c.addr)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = conn.Close() }()
+
+	if deadline, ok := ctx.Deadline(); ok {
+		if err := conn.SetDeadline(deadline); err != nil {
+			return nil, err
+		}
+	}
+
+	command := fmt.Sprintf("*2\r\n$8\r\nSMEMBERS\r\n$%d\r\n%s\r\n", len(key), key)
+	if _, err := io.WriteString(conn, command); err != nil {
+		return nil, err
+	}
+
+	return readRedisArray(bufio.NewReader(conn))
+}
+
+// readRedisArray reads a RESP array of bulk strings, the reply to SMEMBERS.
+func readRedisArray(r *bufio.Reader) ([]string, error) {
+	line, err := readRedisLine(r)
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case strings.HasPrefix(line, "-"):
+		return nil, fmt.Errorf("redis error: %s", line[1:])
+	case !strings.HasPrefix(line, "*"):
+		return nil, fmt.Errorf("unexpected redis reply %q", line)
+	}
+
+	count, err := strconv.Atoi(line[1:])
+	if err != nil {
+		return nil, fmt.Errorf("invalid redis array length %q", line[1:])
+	}
+
+	members := make([]string, 0)
+	for i := 0; i < count; i++ {
+		line, err := readRedisLine(r)
+		if err != nil {
+			return nil, err
+		}
+		if !strings.HasPrefix(line, "$") {
+			return nil, fmt.Errorf("unexpected redis reply %q", line)
+		}
+		length, err := strconv.Atoi(line[1:])
+		if err != nil || length < 0 || length > maxRedisBulkLength {
+			return nil, fmt.Errorf("invalid redis bulk string length %q", line[1:])
+		}
+
+		member := make([]byte, length+2)
+		if _, err := io.ReadFull(r, member); err != nil {
+			return nil, err
+		}
+		members = append(members, string(member[:length]))
+	}
+
+	return members, nil
+}
+
+// readRedisLine reads a RESP line without its "\r\n" ending.
+func readRedisLine(r *bufio.Reader) (string, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSuffix(strings.TrimSuffix(line, "\n"), "\r"), nil
+}
+
+// loadSetMembers loads the members of the set at key as blacklist entries. Invalid members are
+// skipped, or returned as an error in strict mode. An unreachable store fails the load unless
+// unreadable blacklists are skipped.
+func loadSetMembers(store SetStore, key string, opts blacklistOptions, logger *logger) (*parseResult, error) {
+	result := &parseResult{}
+
+	members, err := store.SetMembers(context.Background(), key)
+	if err != nil {
+		if !opts.skipUnreadable {
+			return nil, fmt.Errorf("failed to load set %q: %v", key, err)
+		}
+		logger.warnf(logFields{"key": key}, "Skipping unreadable set %q: %v", key, err)
+		return result, nil
+	}
+
+	for _, member := range members {
+		networks := parseEntry(strings.TrimSpace(member))
+		if networks == nil {
+			if opts.strict {
+				return nil, fmt.Errorf("set %q: invalid IP address or network %q", key, member)
+			}
+			result.skip(member)
+			continue
+		}
+		result.networks = append(result.networks, networks...)
+	}
+
+	logger.infof(logFields{"key": key, "entries": len(result.networks), "skipped": result.skipped},
+		"Loaded %d IPs/Networks from set %s", len(result.networks), key)
+	return result, nil
+}
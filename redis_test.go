@@ -0,0 +1,169 @@
+package simpleblocklist_test
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/LucaNori/traefik-simpleblocklist"
+)
+
+// memorySetStore an in-memory simpleblocklist.SetStore.
+type memorySetStore struct {
+	mu   sync.Mutex
+	sets map[string][]string
+	err  error
+}
+
+func (s *memorySetStore) SetMembers(_ context.Context, key string) ([]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.sets[key], s.err
+}
+
+func (s *memorySetStore) set(key string, members ...string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sets[key] = members
+}
+
+func TestSimpleBlocklist_RedisSet(t *testing.T) {
+	store := &memorySetStore{sets: map[string][]string{}}
+	store.set("blocklist", "192.0.2.1", "198.51.100.0/24", "not-an-ip")
+	defer simpleblocklist.SetSetStore(store)()
+
+	cfg := simpleblocklist.CreateConfig()
+	cfg.BlacklistPath = createBlacklistFile(t, "203.0.113.1\n")
+	cfg.RedisAddr = "redis:6379"
+	cfg.RedisKey = "blocklist"
+
+	ctx := context.Background()
+	next := http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		rw.WriteHeader(http.StatusOK)
+	})
+
+	handler, err := simpleblocklist.New(ctx, next, cfg, "simpleblocklist")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	serve := func(ip string) int {
+		recorder := httptest.NewRecorder()
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, "http://localhost", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("X-Forwarded-For", ip)
+
+		handler.ServeHTTP(recorder, req)
+		return recorder.Code
+	}
+
+	for ip, expectedStatus := range map[string]int{"192.0.2.1": 403, "198.51.100.7": 403, "203.0.113.1": 403, "192.0.2.2": 200} {
+		if code := serve(ip); code != expectedStatus {
+			t.Errorf("%s: got status code %d, want %d", ip, code, expectedStatus)
+		}
+	}
+
+	store.set("blocklist", "192.0.2.2")
+	if err := simpleblocklist.Reload(handler); err != nil {
+		t.Fatal(err)
+	}
+
+	for ip, expectedStatus := range map[string]int{"192.0.2.1": 200, "192.0.2.2": 403} {
+		if code := serve(ip); code != expectedStatus {
+			t.Errorf("after reload, %s: got status code %d, want %d", ip, code, expectedStatus)
+		}
+	}
+}
+
+func TestSimpleBlocklist_RedisSetUnreachable(t *testing.T) {
+	store := &memorySetStore{err: errors.New("connection refused")}
+	defer simpleblocklist.SetSetStore(store)()
+
+	tests := []struct {
+		desc           string
+		skipUnreadable bool
+		wantErr        bool
+	}{
+		{desc: "fails by default", wantErr: true},
+		{desc: "skipped when unreadable blacklists are skipped", skipUnreadable: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			cfg := simpleblocklist.CreateConfig()
+			cfg.BlacklistPath = createBlacklistFile(t, "192.0.2.1\n")
+			cfg.RedisAddr = "redis:6379"
+			cfg.RedisKey = "blocklist"
+			cfg.SkipUnreadableBlacklists = test.skipUnreadable
+
+			next := http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {})
+
+			_, err := simpleblocklist.New(context.Background(), next, cfg, "simpleblocklist")
+			if (err != nil) != test.wantErr {
+				t.Errorf("got error %v, want error: %t", err, test.wantErr)
+			}
+		})
+	}
+}
+
+func TestSimpleBlocklist_RedisProtocol(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = listener.Close() }()
+
+	commands := make(chan string, 1)
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer func() { _ = conn.Close() }()
+
+		// *2 $8 SMEMBERS $<n> <key>, one line each
+		r := bufio.NewReader(conn)
+		var command string
+		for i := 0; i < 5; i++ {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				return
+			}
+			command += line
+		}
+		commands <- command
+
+		_, _ = io.WriteString(conn, "*2\r\n$9\r\n192.0.2.1\r\n$15\r\n198.51.100.0/24\r\n")
+	}()
+
+	cfg := simpleblocklist.CreateConfig()
+	cfg.RedisAddr = listener.Addr().String()
+	cfg.RedisKey = "blocklist"
+	cfg.StrictParsing = true
+
+	next := http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {})
+
+	handler, err := simpleblocklist.New(context.Background(), next, cfg, "simpleblocklist")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if command := <-commands; command != "*2\r\n$8\r\nSMEMBERS\r\n$9\r\nblocklist\r\n" {
+		t.Errorf("got command %q", command)
+	}
+
+	blocklist := handler.(*simpleblocklist.SimpleBlocklist)
+	for ip, expected := range map[string]bool{"192.0.2.1": true, "198.51.100.7": true, "203.0.113.1": false} {
+		if blocked, _ := blocklist.IsBlocked(net.ParseIP(ip)); blocked != expected {
+			t.Errorf("IsBlocked(%s) = %t, want %t", ip, blocked, expected)
+		}
+	}
+}
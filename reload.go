@@ -0,0 +1,76 @@
+package simpleblocklist
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+)
+
+// reloadResult the JSON payload served on the reload path.
+type reloadResult struct {
+	Networks   int       `json:"networks"`
+	LastReload time.Time `json:"last_reload"`
+}
+
+// serveReload reloads the lists on demand, so a job updating the files can apply them right away.
+// Only POST requests from admin callers are answered, since any client could otherwise make the
+// middleware read its files over and over.
+func (a *SimpleBlocklist) serveReload(rw http.ResponseWriter, req *http.Request) {
+	if !a.isAdminCaller(req) {
+		a.writeJSONError(rw, http.StatusForbidden, "the reload path is only available to the admin allowed IPs")
+		return
+	}
+	if req.Method != http.MethodPost {
+		rw.Header().Set("Allow", http.MethodPost)
+		a.writeJSONError(rw, http.StatusMethodNotAllowed, "the reload path only accepts POST requests")
+		return
+	}
+
+	if err := a.Reload(); err != nil {
+		a.logger.warnf(nil, "Failed to reload blacklist, keeping the current one: %v", err)
+		a.writeJSONError(rw, http.StatusInternalServerError, "the lists could not be reloaded, the current ones are kept")
+		return
+	}
+
+	a.mu.RLock()
+	result := reloadResult{
+		Networks:   a.networks,
+		LastReload: a.lastReload,
+	}
+	a.mu.RUnlock()
+
+	rw.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(rw).Encode(result); err != nil {
+		a.logger.warnf(nil, "Failed to write reload result: %v", err)
+	}
+}
+
+// isAdminCaller reports whether req comes from one of the admin allowed IPs, which the status,
+// reload and check paths are restricted to. The caller is the client behind the trusted proxies,
+// see collectTrustedIP: a load balancer or sidecar in front of Traefik doesn't make every caller
+// allowed unless it is itself allowed, and headers are only believed from the trusted proxies.
+func (a *SimpleBlocklist) isAdminCaller(req *http.Request) bool {
+	ips, err := a.collectTrustedIP(req)
+	if err != nil {
+		return false
+	}
+	caller := parseIP(ips[0])
+	if caller == nil {
+		return false
+	}
+	for _, network := range a.adminAllowedIPs {
+		if network.Contains(caller) {
+			return true
+		}
+	}
+	return false
+}
+
+// writeJSONError answers a request to one of the middleware's own paths with statusCode and message.
+func (a *SimpleBlocklist) writeJSONError(rw http.ResponseWriter, statusCode int, message string) {
+	rw.Header().Set("Content-Type", "application/json")
+	rw.WriteHeader(statusCode)
+	if err := json.NewEncoder(rw).Encode(map[string]string{"error": message}); err != nil {
+		a.logger.warnf(nil, "Failed to write error response: %v", err)
+	}
+}
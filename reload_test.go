@@ -0,0 +1,145 @@
+package simpleblocklist_test
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/LucaNori/traefik-simpleblocklist"
+)
+
+func TestSimpleBlocklist_ReloadPath(t *testing.T) {
+	blacklistPath := createBlacklistFile(t, "192.0.2.1\n")
+
+	cfg := simpleblocklist.CreateConfig()
+	cfg.BlacklistPath = blacklistPath
+	cfg.ReloadPath = "/_blocklist/reload"
+	cfg.AdminAllowedIPs = []string{"127.0.0.1"}
+
+	ctx := context.Background()
+	next := http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		rw.WriteHeader(http.StatusTeapot)
+	})
+
+	handler, err := simpleblocklist.New(ctx, next, cfg, "simpleblocklist")
+	if err != nil {
+		t.Fatal(err)
+	}
+	blocklist := handler.(*simpleblocklist.SimpleBlocklist)
+
+	if err := os.WriteFile(blacklistPath, []byte("192.0.2.2\n198.51.100.0/24\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	// The cases share the handler: only the last one reloads the updated file
+	tests := []struct {
+		desc           string
+		method         string
+		remoteAddr     string
+		forwardedFor   string
+		expectedStatus int
+		reloaded       bool
+	}{
+		{
+			desc:           "GET from an allowed caller",
+			method:         http.MethodGet,
+			remoteAddr:     "127.0.0.1:1234",
+			expectedStatus: 405,
+		},
+		{
+			desc:           "POST from a local caller that is not allowed",
+			method:         http.MethodPost,
+			remoteAddr:     "10.0.0.5:1234",
+			expectedStatus: 403,
+		},
+		{
+			desc:           "POST from a public caller",
+			method:         http.MethodPost,
+			remoteAddr:     "203.0.113.1:1234",
+			expectedStatus: 403,
+		},
+		{
+			desc:           "POST from a public caller with a forged allowed header",
+			method:         http.MethodPost,
+			remoteAddr:     "203.0.113.1:1234",
+			forwardedFor:   "127.0.0.1",
+			expectedStatus: 403,
+		},
+		{
+			desc:           "POST from an allowed caller",
+			method:         http.MethodPost,
+			remoteAddr:     "127.0.0.1:1234",
+			expectedStatus: 200,
+			reloaded:       true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			recorder := httptest.NewRecorder()
+			req := httptest.NewRequest(test.method, "http://localhost/_blocklist/reload", nil)
+			req.RemoteAddr = test.remoteAddr
+			if test.forwardedFor != "" {
+				req.Header.Set("X-Forwarded-For", test.forwardedFor)
+			}
+
+			handler.ServeHTTP(recorder, req)
+
+			if recorder.Code != test.expectedStatus {
+				t.Errorf("got status code %d, want %d", recorder.Code, test.expectedStatus)
+			}
+			if blocked, _ := blocklist.IsBlocked(net.ParseIP("192.0.2.2")); blocked != test.reloaded {
+				t.Errorf("got added IP blocked %v, want %v", blocked, test.reloaded)
+			}
+			if !test.reloaded {
+				return
+			}
+
+			var result struct {
+				Networks int `json:"networks"`
+			}
+			if err := json.NewDecoder(recorder.Body).Decode(&result); err != nil {
+				t.Fatal(err)
+			}
+			if result.Networks != 2 {
+				t.Errorf("got %d networks, want 2", result.Networks)
+			}
+		})
+	}
+}
+
+func TestSimpleBlocklist_ReloadPathFailure(t *testing.T) {
+	blacklistPath := createBlacklistFile(t, "192.0.2.1\n")
+
+	cfg := simpleblocklist.CreateConfig()
+	cfg.BlacklistPath = blacklistPath
+	cfg.ReloadPath = "/_blocklist/reload"
+	cfg.AdminAllowedIPs = []string{"127.0.0.1"}
+
+	next := http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {})
+
+	handler, err := simpleblocklist.New(context.Background(), next, cfg, "simpleblocklist")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.Remove(blacklistPath); err != nil {
+		t.Fatal(err)
+	}
+
+	recorder := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "http://localhost/_blocklist/reload", nil)
+	req.RemoteAddr = "127.0.0.1:1234"
+	handler.ServeHTTP(recorder, req)
+
+	if recorder.Code != http.StatusInternalServerError {
+		t.Errorf("got status code %d, want %d", recorder.Code, http.StatusInternalServerError)
+	}
+	if blocked, _ := handler.(*simpleblocklist.SimpleBlocklist).IsBlocked(net.ParseIP("192.0.2.1")); !blocked {
+		t.Error("expected the current blacklist to be kept after a failed reload")
+	}
+}
@@ -0,0 +1,78 @@
+package simpleblocklist
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+const (
+	// remoteTimeout caps how long downloading a remote blacklist may take, body included.
+	remoteTimeout = 30 * time.Second
+	// maxRemoteBlacklistSize caps the size of a downloaded blacklist, so a misconfigured URL can't
+	// exhaust memory.
+	maxRemoteBlacklistSize = 64 << 20
+)
+
+// maxRemoteDecompressedSize caps the size of a compressed downloaded blacklist once decompressed,
+// so a small gzip bomb can't exhaust memory either. It leaves room for large aggregated lists,
+// which compress well.
+var maxRemoteDecompressedSize int64 = 256 << 20
+
+// remoteClient the client remote blacklists are downloaded with.
+var remoteClient = &http.Client{Timeout: remoteTimeout}
+
+// fetchBlacklist downloads the blacklist at rawURL and parses it as it is read, like a blacklist
+// file. A gzip-compressed body is decompressed as a file would be, whether the URL path ends in
+// ".gz" or the body starts with the gzip header. Includes are not supported, and a response other
+// than 200 OK, larger than maxRemoteBlacklistSize or decompressing to more than
+// maxRemoteDecompressedSize is an error.
+func fetchBlacklist(rawURL string, opts blacklistOptions) (*parseResult, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := remoteClient.Get(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %s", resp.Status)
+	}
+
+	opts.maxDecompressedSize = maxRemoteDecompressedSize
+	result, err := parseBlacklistFile(u.Path, &sizeLimitReader{r: resp.Body, limit: maxRemoteBlacklistSize}, opts)
+	if err != nil {
+		return nil, err
+	}
+	if len(result.includes) != 0 {
+		return nil, errors.New("includes are not supported in remote blacklists")
+	}
+	return result, nil
+}
+
+// sizeLimitReader reads from r, and fails once more than limit bytes have been read rather than
+// truncating the input like io.LimitReader, so a partial list is never loaded.
+type sizeLimitReader struct {
+	r     io.Reader
+	limit int64
+	read  int64
+}
+
+func (l *sizeLimitReader) Read(p []byte) (int, error) {
+	if max := l.limit - l.read + 1; int64(len(p)) > max {
+		p = p[:max]
+	}
+	n, err := l.r.Read(p)
+	l.read += int64(n)
+	if l.read > l.limit {
+		return 0, fmt.Errorf("blacklist exceeds %d bytes", l.limit)
+	}
+	return n, err
+}
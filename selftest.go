@@ -0,0 +1,46 @@
+package simpleblocklist
+
+import (
+	"fmt"
+	"net"
+	"strings"
+)
+
+// runSelfTest runs the self-test if any IP to check is configured, and logs its success.
+func (a *SimpleBlocklist) runSelfTest(blockedIPs, allowedIPs []string) error {
+	if len(blockedIPs) == 0 && len(allowedIPs) == 0 {
+		return nil
+	}
+	if err := a.selfTest(blockedIPs, allowedIPs); err != nil {
+		return err
+	}
+
+	a.logger.infof(nil, "Self-test passed: %d blocked and %d allowed IPs checked", len(blockedIPs), len(allowedIPs))
+	return nil
+}
+
+// selfTest checks that each of blockedIPs is blocked and each of allowedIPs isn't, which catches a
+// corrupted, truncated or empty feed that would otherwise load silently. Every failed expectation
+// is reported in the error.
+func (a *SimpleBlocklist) selfTest(blockedIPs, allowedIPs []string) error {
+	var failures []string
+	for _, ip := range blockedIPs {
+		if blocked, _ := a.IsBlocked(net.ParseIP(strings.TrimSpace(ip))); !blocked {
+			failures = append(failures, fmt.Sprintf("%s is not blocked", strings.TrimSpace(ip)))
+		}
+	}
+	for _, ip := range allowedIPs {
+		if blocked, network := a.IsBlocked(net.ParseIP(strings.TrimSpace(ip))); blocked {
+			failure := fmt.Sprintf("%s is blocked", strings.TrimSpace(ip))
+			if network != nil {
+				failure += " by " + network.String()
+			}
+			failures = append(failures, failure)
+		}
+	}
+
+	if len(failures) != 0 {
+		return fmt.Errorf("self-test failed: %s", strings.Join(failures, "; "))
+	}
+	return nil
+}
@@ -0,0 +1,63 @@
+package simpleblocklist_test
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/LucaNori/traefik-simpleblocklist"
+)
+
+func TestSimpleBlocklist_SelfTest(t *testing.T) {
+	tests := []struct {
+		desc         string
+		blocked      []string
+		allowed      []string
+		wantFailures []string
+	}{
+		{
+			desc:    "expectations met",
+			blocked: []string{"192.0.2.1", "198.51.100.7"},
+			allowed: []string{"203.0.113.1"},
+		},
+		{
+			desc:         "allowed IP in the list",
+			allowed:      []string{"203.0.113.1", "198.51.100.7"},
+			wantFailures: []string{"198.51.100.7 is blocked by 198.51.100.0/24"},
+		},
+		{
+			desc:         "blocked IP missing from the list",
+			blocked:      []string{"192.0.2.2"},
+			allowed:      []string{"192.0.2.1"},
+			wantFailures: []string{"192.0.2.2 is not blocked", "192.0.2.1 is blocked by 192.0.2.1/32"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			cfg := simpleblocklist.CreateConfig()
+			cfg.BlacklistPath = createBlacklistFile(t, "192.0.2.1\n198.51.100.0/24\n")
+			cfg.SelfTestBlockedIPs = test.blocked
+			cfg.SelfTestAllowedIPs = test.allowed
+
+			next := http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {})
+
+			_, err := simpleblocklist.New(context.Background(), next, cfg, "simpleblocklist")
+			if len(test.wantFailures) == 0 {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.HasPrefix(err.Error(), "self-test failed") {
+				t.Fatalf("expected a self-test error, got %v", err)
+			}
+			for _, failure := range test.wantFailures {
+				if !strings.Contains(err.Error(), failure) {
+					t.Errorf("error %q doesn't report %q", err, failure)
+				}
+			}
+		})
+	}
+}
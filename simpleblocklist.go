@@ -2,197 +2,1598 @@
 package simpleblocklist
 
 import (
-	"bufio"
+	"bytes"
 	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
 	"fmt"
 	"log"
 	"net"
 	"net/http"
 	"os"
+	"path/filepath"
+	"strconv"
 	"strings"
+	"sync"
+	"text/template"
+	"time"
 )
 
 const (
-	xForwardedFor = "X-Forwarded-For"
-	xRealIP       = "X-Real-IP"
+	xForwardedFor                      = "X-Forwarded-For"
+	xRealIP                            = "X-Real-IP"
+	xBlockedReason                     = "X-Blocked-Reason"
+	defaultBypassHeader                = "X-Blocklist-Bypass"
+	defaultRequestIDHeader             = "X-Request-ID"
 	defaultDeniedRequestHTTPStatusCode = 403
+	defaultDeniedRedirectStatusCode    = 302
+	defaultMaxForwardedForEntries      = 20
+	blacklistDirPattern                = "*.txt"
+
+	ipEvaluationModeAll        = "all"
+	ipEvaluationModeRemoteOnly = "remote-only"
+	ipEvaluationModeXFFFirst   = "xff-first"
+	ipEvaluationModeXFFLast    = "xff-last"
+
+	ipSourceRemoteAddr = "remoteaddr"
+	ipSourceXRealIP    = "xrealip"
+	ipSourceXFF        = "xff"
+
+	defaultActionAllow = "allow"
+	defaultActionDeny  = "deny"
 )
 
 var (
 	infoLogger = log.New(os.Stdout, "INFO: SimpleBlocklist: ", log.Ldate|log.Ltime)
+	warnLogger = log.New(os.Stdout, "WARN: SimpleBlocklist: ", log.Ldate|log.Ltime)
 )
 
 // Config the plugin configuration.
 type Config struct {
-	BlacklistPath              string `yaml:"blacklistPath"`
-	AllowLocalRequests         bool   `yaml:"allowLocalRequests"`
-	LogLocalRequests          bool   `yaml:"logLocalRequests"`
-	HTTPStatusCodeDeniedRequest int   `yaml:"httpStatusCodeDeniedRequest"`
+	BlacklistPath               string   `yaml:"blacklistPath"`
+	BlacklistPaths              []string `yaml:"blacklistPaths"`
+	BlacklistDir                string   `yaml:"blacklistDir"`
+	BinaryBlacklistPath         string   `yaml:"binaryBlacklistPath"`
+	BlacklistInline             string   `yaml:"blacklistInline"`
+	BlacklistBase64             string   `yaml:"blacklistBase64"`
+	BlacklistedIPs              []string `yaml:"blacklistedIPs"`
+	BlacklistURL                string   `yaml:"blacklistURL"`
+	BlacklistURLs               []string `yaml:"blacklistURLs"`
+	SkipUnreadableBlacklists    bool     `yaml:"skipUnreadableBlacklists"`
+	FailOpenOnLoadError         bool     `yaml:"failOpenOnLoadError"`
+	StrictParsing               bool     `yaml:"strictParsing"`
+	BlacklistFormat             string   `yaml:"blacklistFormat"`
+	BlockScoreThreshold         int      `yaml:"blockScoreThreshold"`
+	RedisAddr                   string   `yaml:"redisAddr"`
+	RedisKey                    string   `yaml:"redisKey"`
+	WhitelistPath               string   `yaml:"whitelistPath"`
+	DefaultDeny                 bool     `yaml:"defaultDeny"`
+	ConflictResolution          string   `yaml:"conflictResolution"`
+	MaxBlacklistEntries         int      `yaml:"maxBlacklistEntries"`
+	CollapseAdjacentNetworks    bool     `yaml:"collapseAdjacentNetworks"`
+	SelfTestBlockedIPs          []string `yaml:"selfTestBlockedIPs"`
+	SelfTestAllowedIPs          []string `yaml:"selfTestAllowedIPs"`
+	DisabledTags                []string `yaml:"disabledTags"`
+	AllowLocalRequests          bool     `yaml:"allowLocalRequests"`
+	LogLocalRequests            bool     `yaml:"logLocalRequests"`
+	LogAllRequests              bool     `yaml:"logAllRequests"`
+	LocalIPRanges               []string `yaml:"localIPRanges"`
+	AllowLocalRequestsPaths     []string `yaml:"allowLocalRequestsPaths"`
+	StillCheckBlacklistForLocal bool     `yaml:"stillCheckBlacklistForLocal"`
+	TreatCGNATAsPublic          bool     `yaml:"treatCGNATAsPublic"`
+	HTTPStatusCodeDeniedRequest int      `yaml:"httpStatusCodeDeniedRequest"`
+	LocalDeniedStatusCode       int      `yaml:"localDeniedStatusCode"`
+	ClientIPHeaders             []string `yaml:"clientIPHeaders"`
+	ExcludedPaths               []string `yaml:"excludedPaths"`
+	ExcludedMethods             []string `yaml:"excludedMethods"`
+	BlockedMethodsForListedIPs  []string `yaml:"blockedMethodsForListedIPs"`
+	GeoIPDatabasePath           string   `yaml:"geoIPDatabasePath"`
+	BlockedCountries            []string `yaml:"blockedCountries"`
+	ASNDatabasePath             string   `yaml:"asnDatabasePath"`
+	BlockedASNs                 []uint   `yaml:"blockedASNs"`
+	BlockedHostnamePatterns     []string `yaml:"blockedHostnamePatterns"`
+	HostnameLookupFailureAction string   `yaml:"hostnameLookupFailureAction"`
+	BlockedCertFingerprints     []string `yaml:"blockedCertFingerprints"`
+	BlockedHosts                []string `yaml:"blockedHosts"`
+	LogFormat                   string   `yaml:"logFormat"`
+	LogFilePath                 string   `yaml:"logFilePath"`
+	Enabled                     bool     `yaml:"enabled"`
+	DryRun                      bool     `yaml:"dryRun"`
+	DeniedRedirectURL           string   `yaml:"deniedRedirectURL"`
+	DeniedRedirectStatusCode    int      `yaml:"deniedRedirectStatusCode"`
+	DeniedRequestTemplate       string   `yaml:"deniedRequestTemplate"`
+	DeniedResponseFile          string   `yaml:"deniedResponseFile"`
+	DeniedResponseContentType   string   `yaml:"deniedResponseContentType"`
+	DeniedResponseFormat        string   `yaml:"deniedResponseFormat"`
+	ChallengeMode               bool     `yaml:"challengeMode"`
+	ChallengeSecret             string   `yaml:"challengeSecret"`
+	DecisionCacheSize           int      `yaml:"decisionCacheSize"`
+	MaxForwardedForEntries      int      `yaml:"maxForwardedForEntries"`
+	DebugHeaders                bool     `yaml:"debugHeaders"`
+	ReloadIntervalSeconds       int      `yaml:"reloadIntervalSeconds"`
+	StatusPath                  string   `yaml:"statusPath"`
+	ReloadPath                  string   `yaml:"reloadPath"`
+	CheckPath                   string   `yaml:"checkPath"`
+	AdminAllowedIPs             []string `yaml:"adminAllowedIPs"`
+	StatusTopBlockedIPs         int      `yaml:"statusTopBlockedIPs"`
+	MetricsEnabled              bool     `yaml:"metricsEnabled"`
+	AllowNilNext                bool     `yaml:"allowNilNext"`
+	AnonymizeLoggedIPs          bool     `yaml:"anonymizeLoggedIPs"`
+	BypassHeader                string   `yaml:"bypassHeader"`
+	BypassToken                 string   `yaml:"bypassToken"`
+	IPEvaluationMode            string   `yaml:"ipEvaluationMode"`
+	IgnoreProxyHeaders          bool     `yaml:"ignoreProxyHeaders"`
+	ProxyProtocolHeader         string   `yaml:"proxyProtocolHeader"`
+	TrustedProxies              []string `yaml:"trustedProxies"`
+	IPPrecedence                []string `yaml:"ipPrecedence"`
+	EvaluationStrategy          string   `yaml:"evaluationStrategy"`
+	DefaultActionOnNoIP         string   `yaml:"defaultActionOnNoIP"`
+	RateLimitRequests           int      `yaml:"rateLimitRequests"`
+	RateLimitWindowSeconds      int      `yaml:"rateLimitWindowSeconds"`
+	RateLimitAggregateMask      int      `yaml:"rateLimitAggregateMask"`
+	RateLimitAggregateMaskIPv6  int      `yaml:"rateLimitAggregateMaskIPv6"`
+	RetryAfterSeconds           int      `yaml:"retryAfterSeconds"`
+	RequestIDHeader             string   `yaml:"requestIDHeader"`
+
+	// TieredLists additional blacklists with their own response, checked in order after the others.
+	TieredLists []TieredList `yaml:"tieredLists"`
+	// BlocklistPrecedenceOverLocal checks local IPs against the lists before allowing them.
+	BlocklistPrecedenceOverLocal bool `yaml:"blocklistPrecedenceOverLocal"`
 }
 
 // CreateConfig creates the default plugin configuration.
 func CreateConfig() *Config {
 	return &Config{
 		HTTPStatusCodeDeniedRequest: defaultDeniedRequestHTTPStatusCode,
-		AllowLocalRequests: true,
-		LogLocalRequests: false,
+		Enabled:                     true,
+		AllowLocalRequests:          true,
+		LogLocalRequests:            false,
+		LogFormat:                   logFormatText,
+		BlacklistFormat:             blacklistFormatPlain,
+		DeniedRedirectStatusCode:    defaultDeniedRedirectStatusCode,
+		MaxForwardedForEntries:      defaultMaxForwardedForEntries,
+		IPEvaluationMode:            ipEvaluationModeAll,
+		DefaultActionOnNoIP:         defaultActionAllow,
+		HostnameLookupFailureAction: defaultActionAllow,
+		RequestIDHeader:             defaultRequestIDHeader,
+		StatusTopBlockedIPs:         defaultStatusTopBlockedIPs,
 	}
 }
 
 // SimpleBlocklist a Traefik plugin.
 type SimpleBlocklist struct {
-	next                        http.Handler
-	blacklistedIPs             []*net.IPNet
-	allowLocalRequests         bool
-	logLocalRequests          bool
-	privateIPRanges           []*net.IPNet
+	next http.Handler
+	// mu guards the fields from blacklist to deniedPage. Reload builds new lists off to the side
+	// and swaps them all at once, so a lookup never sees a half-updated set of lists.
+	mu                          sync.RWMutex
+	blacklist                   *ipTrie
+	exceptions                  *ipTrie
+	annotations                 map[string]entryAnnotation
+	whitelist                   *ipTrie
+	tiers                       []*listTier
+	whitelistPaths              []string
+	tieredLists                 []TieredList
+	defaultDeny                 bool
+	blacklistWins               bool
+	networks                    int
+	lastReload                  time.Time
+	deniedPage                  *deniedPage
+	sources                     []BlocklistSource
+	blacklistOptions            blacklistOptions
+	whitelistOptions            blacklistOptions
+	cache                       *decisionCache
+	reloadDone                  chan struct{}
+	reloadMu                    sync.Mutex
+	allowLocalRequests          bool
+	logLocalRequests            bool
+	logAllRequests              bool
+	privateIPRanges             []*net.IPNet
+	treatCGNATAsPublic          bool
+	proxyProtocolHeader         string
+	trustedProxies              []*net.IPNet
+	localRequestsPaths          []string
+	stillCheckBlacklistForLocal bool
+	blacklistBeforeLocal        bool
 	httpStatusCodeDeniedRequest int
-	name                       string
+	localDeniedStatusCode       int
+	clientIPHeaders             []string
+	maxForwardedForEntries      int
+	ipEvaluationMode            string
+	ipPrecedence                []string
+	evaluationStrategy          string
+	denyWithoutIP               bool
+	excludedPaths               []string
+	excludedMethods             map[string]struct{}
+	blockedMethods              map[string]struct{}
+	countryBlocker              *countryBlocker
+	asnBlocker                  *asnBlocker
+	hostnameBlocker             *hostnameBlocker
+	blockedCertFingerprints     map[string]struct{}
+	blockedHosts                []string
+	anonymizeLoggedIPs          bool
+	resolver                    Resolver
+	rateLimiter                 *rateLimiter
+	logger                      *logger
+	disabled                    bool
+	dryRun                      bool
+	deniedRedirectURL           string
+	deniedRedirectStatusCode    int
+	deniedTemplate              *template.Template
+	deniedResponseFile          string
+	deniedResponseContentType   string
+	problemJSON                 bool
+	challengeSecret             []byte
+	retryAfterSeconds           int
+	debugHeaders                bool
+	statusPath                  string
+	reloadPath                  string
+	checkPath                   string
+	adminAllowedIPs             []*net.IPNet
+	statusTopBlockedIPs         int
+	metrics                     *loadMetrics
+	blockCounter                *blockCounter
+	bypassHeader                string
+	bypassToken                 []byte
+	requestIDHeader             string
+	name                        string
 }
 
 // New created a new SimpleBlocklist plugin.
-func New(_ context.Context, next http.Handler, config *Config, name string) (http.Handler, error) {
-	if len(config.BlacklistPath) == 0 {
-		return nil, fmt.Errorf("no blacklist file path provided")
+// Background work such as periodic reloads and rate limit eviction stops when ctx is done.
+func New(ctx context.Context, next http.Handler, config *Config, name string) (http.Handler, error) {
+	if err := config.Validate(); err != nil {
+		return nil, err
 	}
+	applyConfigDefaults(config)
 
-	blacklistedIPs, err := loadBlacklistedIPs(config.BlacklistPath)
+	logger, err := newPluginLogger(ctx, config, name)
+	if err != nil {
+		return nil, err
+	}
+	next, err = newNextHandler(next, config, logger)
 	if err != nil {
-		return nil, fmt.Errorf("failed to load blacklist: %v", err)
+		return nil, err
 	}
 
-	if config.HTTPStatusCodeDeniedRequest != 0 {
-		if len(http.StatusText(config.HTTPStatusCodeDeniedRequest)) == 0 {
-			return nil, fmt.Errorf("invalid denied request status code supplied")
-		}
-	} else {
-		config.HTTPStatusCodeDeniedRequest = defaultDeniedRequestHTTPStatusCode
+	lists, err := loadInitialLists(config, logger)
+	if err != nil {
+		return nil, err
 	}
+	logLocalRequestHandling(config, logger)
+	logEnforcement(config, logger)
 
-	infoLogger.Printf("Loaded %d blacklisted IPs/Networks", len(blacklistedIPs))
-	infoLogger.Printf("Allow local IPs: %t", config.AllowLocalRequests)
-	infoLogger.Printf("Log local requests: %t", config.LogLocalRequests)
-	infoLogger.Printf("Denied request status code: %d", config.HTTPStatusCodeDeniedRequest)
+	denied, err := newDeniedResponse(config, logger)
+	if err != nil {
+		return nil, err
+	}
+	logIPEvaluation(config, logger)
+	logRequestFilters(config, logger)
 
-	return &SimpleBlocklist{
+	countryBlocker, err := newCountryBlocker(config, logger)
+	if err != nil {
+		return nil, err
+	}
+	asnBlocker, err := newASNBlocker(config, logger)
+	if err != nil {
+		return nil, err
+	}
+	logAdminPaths(config, logger)
+
+	a := &SimpleBlocklist{
 		next:                        next,
-		blacklistedIPs:             blacklistedIPs,
-		allowLocalRequests:         config.AllowLocalRequests,
-		logLocalRequests:          config.LogLocalRequests,
-		privateIPRanges:           initPrivateIPBlocks(),
+		blacklist:                   newIPTrie(lists.blacklist.networks),
+		exceptions:                  newIPTrie(lists.blacklist.exceptions),
+		annotations:                 lists.blacklist.annotations,
+		whitelist:                   newIPTrie(lists.whitelist.networks),
+		whitelistPaths:              lists.whitelistPaths,
+		tiers:                       lists.tiers,
+		tieredLists:                 lists.tieredLists,
+		defaultDeny:                 config.DefaultDeny,
+		blacklistWins:               config.ConflictResolution == conflictResolutionBlacklistWins,
+		networks:                    len(lists.blacklist.networks),
+		lastReload:                  lists.lastReload,
+		sources:                     lists.sources,
+		blacklistOptions:            lists.blacklistOptions,
+		whitelistOptions:            lists.whitelistOptions,
+		cache:                       newDecisionCache(config, logger),
+		allowLocalRequests:          config.AllowLocalRequests,
+		logLocalRequests:            config.LogLocalRequests,
+		logAllRequests:              config.LogAllRequests,
+		privateIPRanges:             append(initPrivateIPBlocks(), parseNetworks(config.LocalIPRanges)...),
+		treatCGNATAsPublic:          config.TreatCGNATAsPublic,
+		proxyProtocolHeader:         config.ProxyProtocolHeader,
+		trustedProxies:              parseNetworks(config.TrustedProxies),
+		localRequestsPaths:          config.AllowLocalRequestsPaths,
+		stillCheckBlacklistForLocal: config.StillCheckBlacklistForLocal,
+		blacklistBeforeLocal:        config.BlocklistPrecedenceOverLocal,
 		httpStatusCodeDeniedRequest: config.HTTPStatusCodeDeniedRequest,
-		name:                       name,
-	}, nil
+		localDeniedStatusCode:       config.LocalDeniedStatusCode,
+		clientIPHeaders:             config.ClientIPHeaders,
+		maxForwardedForEntries:      config.MaxForwardedForEntries,
+		ipEvaluationMode:            config.IPEvaluationMode,
+		ipPrecedence:                config.IPPrecedence,
+		evaluationStrategy:          config.EvaluationStrategy,
+		denyWithoutIP:               config.DefaultActionOnNoIP == defaultActionDeny,
+		excludedPaths:               config.ExcludedPaths,
+		excludedMethods:             newMethodSet(config.ExcludedMethods),
+		blockedMethods:              newMethodSet(config.BlockedMethodsForListedIPs),
+		countryBlocker:              countryBlocker,
+		asnBlocker:                  asnBlocker,
+		hostnameBlocker:             newHostnameBlocker(config, logger),
+		blockedCertFingerprints:     newBlockedCertFingerprints(config, logger),
+		blockedHosts:                newBlockedHosts(config, logger),
+		anonymizeLoggedIPs:          config.AnonymizeLoggedIPs,
+		resolver:                    net.DefaultResolver,
+		rateLimiter:                 newRateLimiter(config, logger),
+		logger:                      logger,
+		disabled:                    !config.Enabled,
+		dryRun:                      config.DryRun,
+		deniedRedirectURL:           config.DeniedRedirectURL,
+		deniedRedirectStatusCode:    config.DeniedRedirectStatusCode,
+		deniedTemplate:              denied.template,
+		deniedPage:                  denied.page,
+		deniedResponseFile:          config.DeniedResponseFile,
+		deniedResponseContentType:   config.DeniedResponseContentType,
+		problemJSON:                 config.DeniedResponseFormat == deniedResponseFormatProblemJSON,
+		challengeSecret:             denied.challengeSecret,
+		retryAfterSeconds:           config.RetryAfterSeconds,
+		debugHeaders:                config.DebugHeaders,
+		statusPath:                  config.StatusPath,
+		reloadPath:                  config.ReloadPath,
+		checkPath:                   config.CheckPath,
+		adminAllowedIPs:             parseNetworks(config.AdminAllowedIPs),
+		statusTopBlockedIPs:         config.StatusTopBlockedIPs,
+		metrics:                     lists.metrics,
+		blockCounter:                newBlockCounter(config),
+		bypassHeader:                config.BypassHeader,
+		bypassToken:                 []byte(config.BypassToken),
+		requestIDHeader:             config.RequestIDHeader,
+		reloadDone:                  make(chan struct{}),
+		name:                        name,
+	}
+	a.warnListConflicts(a.blacklist, a.whitelist, lists.blacklist.networks, lists.whitelist.networks)
+
+	if err := a.runSelfTest(config.SelfTestBlockedIPs, config.SelfTestAllowedIPs); err != nil {
+		return nil, err
+	}
+	a.startBackgroundWork(ctx, config.ReloadIntervalSeconds)
+
+	return a, nil
+}
+
+// applyConfigDefaults fills in the options of config left unset whose default depends on other
+// options, so the rest of the plugin reads a single value.
+func applyConfigDefaults(config *Config) {
+	if len(config.BlacklistFormat) == 0 {
+		config.BlacklistFormat = blacklistFormatPlain
+	}
+	if config.HTTPStatusCodeDeniedRequest == 0 {
+		config.HTTPStatusCodeDeniedRequest = defaultDeniedRequestHTTPStatusCode
+	}
+	if config.LocalDeniedStatusCode == 0 {
+		config.LocalDeniedStatusCode = config.HTTPStatusCodeDeniedRequest
+	}
+	if len(config.DeniedRedirectURL) != 0 && config.DeniedRedirectStatusCode == 0 {
+		config.DeniedRedirectStatusCode = defaultDeniedRedirectStatusCode
+	}
+	if len(config.ClientIPHeaders) == 0 {
+		config.ClientIPHeaders = []string{xForwardedFor, xRealIP}
+	}
+	applyIPEvaluationDefaults(config)
+	if len(config.BypassHeader) == 0 {
+		config.BypassHeader = defaultBypassHeader
+	}
+	if len(config.RequestIDHeader) == 0 {
+		config.RequestIDHeader = defaultRequestIDHeader
+	}
+}
+
+// applyIPEvaluationDefaults fills in the unset options deciding which client IPs are evaluated.
+func applyIPEvaluationDefaults(config *Config) {
+	if len(config.IPEvaluationMode) == 0 {
+		config.IPEvaluationMode = ipEvaluationModeAll
+	}
+	if config.IgnoreProxyHeaders {
+		config.IPEvaluationMode = ipEvaluationModeRemoteOnly
+	}
+	precedence := make([]string, 0, len(config.IPPrecedence))
+	for _, source := range config.IPPrecedence {
+		precedence = append(precedence, strings.ToLower(strings.TrimSpace(source)))
+	}
+	config.IPPrecedence = precedence
+	if len(config.DefaultActionOnNoIP) == 0 {
+		config.DefaultActionOnNoIP = defaultActionAllow
+	}
 }
 
-func loadBlacklistedIPs(path string) ([]*net.IPNet, error) {
-	file, err := os.Open(path)
+// newNextHandler returns next, or, if there is none and config allows it, a handler answering the
+// allowed requests with 502 Bad Gateway.
+func newNextHandler(next http.Handler, config *Config, logger *logger) (http.Handler, error) {
+	if next != nil {
+		return next, nil
+	}
+	if !config.AllowNilNext {
+		return nil, fmt.Errorf("no next handler provided")
+	}
+
+	logger.warnf(nil, "No next handler provided, allowed requests are answered with 502 Bad Gateway")
+	return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		rw.WriteHeader(http.StatusBadGateway)
+	}), nil
+}
+
+// initialLists the lists loaded when the plugin is created, and what reloads them.
+type initialLists struct {
+	sources          []BlocklistSource
+	blacklist        *parseResult
+	whitelist        *parseResult
+	whitelistPaths   []string
+	tiers            []*listTier
+	tieredLists      []TieredList
+	blacklistOptions blacklistOptions
+	whitelistOptions blacklistOptions
+	metrics          *loadMetrics
+	lastReload       time.Time
+}
+
+// loadInitialLists loads the blacklist, whitelist and tiered lists of config.
+func loadInitialLists(config *Config, logger *logger) (*initialLists, error) {
+	paths, whitelistPaths, err := expandListPaths(config)
 	if err != nil {
 		return nil, err
 	}
-	defer file.Close()
 
-	var ips []*net.IPNet
-	scanner := bufio.NewScanner(file)
-	for scanner.Scan() {
-		line := strings.TrimSpace(scanner.Text())
-		if line == "" || strings.HasPrefix(line, "#") {
-			continue
+	lists := &initialLists{
+		whitelistPaths:   whitelistPaths,
+		blacklistOptions: newBlacklistOptions(config, logger),
+		// The format, filters and limits of the blacklist feeds don't apply to the whitelist
+		whitelistOptions: blacklistOptions{
+			format:         blacklistFormatPlain,
+			skipUnreadable: config.SkipUnreadableBlacklists,
+			strict:         config.StrictParsing,
+		},
+	}
+	if config.MetricsEnabled {
+		lists.metrics = &loadMetrics{}
+	}
+
+	lists.sources = newBlocklistSources(config, paths, lists.blacklistOptions, logger)
+	if err := lists.loadBlacklist(config, logger); err != nil {
+		return nil, err
+	}
+
+	lists.whitelist, err = loadBlacklists(whitelistPaths, lists.whitelistOptions, logger)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load whitelist: %v", err)
+	}
+
+	lists.tieredLists, err = expandTieredLists(config.TieredLists)
+	if err != nil {
+		return nil, err
+	}
+	lists.tiers, err = loadTiers(lists.tieredLists, lists.blacklistOptions, logger)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load tiered list: %v", err)
+	}
+
+	lists.log(config, logger)
+	return lists, nil
+}
+
+// expandListPaths returns the blacklist and whitelist paths of config, with their environment
+// variables expanded. The binary blacklist path is expanded in place.
+func expandListPaths(config *Config) (paths, whitelistPaths []string, err error) {
+	paths = config.BlacklistPaths
+	if len(config.BlacklistPath) != 0 {
+		paths = append([]string{config.BlacklistPath}, paths...)
+	}
+	if len(config.BlacklistDir) != 0 {
+		// Matched again on every reload, so files added to the directory are picked up
+		paths = append(paths, filepath.Join(config.BlacklistDir, blacklistDirPattern))
+	}
+	if paths, err = expandEnvPaths(paths); err != nil {
+		return nil, nil, err
+	}
+
+	if len(config.BinaryBlacklistPath) != 0 {
+		binaryPaths, err := expandEnvPaths([]string{config.BinaryBlacklistPath})
+		if err != nil {
+			return nil, nil, err
 		}
+		config.BinaryBlacklistPath = binaryPaths[0]
+	}
 
-		// Try parsing as CIDR first
-		if _, ipNet, err := net.ParseCIDR(line); err == nil {
-			ips = append(ips, ipNet)
-			continue
+	if len(config.WhitelistPath) != 0 {
+		if whitelistPaths, err = expandEnvPaths([]string{config.WhitelistPath}); err != nil {
+			return nil, nil, err
+		}
+	}
+	if config.DefaultDeny && len(whitelistPaths) == 0 {
+		// The whitelist path expanded to nothing, e.g. an unset environment variable
+		return nil, nil, fmt.Errorf("default deny requires a whitelist path")
+	}
+
+	return paths, whitelistPaths, nil
+}
+
+// newBlacklistOptions returns the options the blacklist feeds of config are parsed with.
+func newBlacklistOptions(config *Config, logger *logger) blacklistOptions {
+	opts := blacklistOptions{
+		format:         config.BlacklistFormat,
+		skipUnreadable: config.SkipUnreadableBlacklists,
+		strict:         config.StrictParsing,
+		maxEntries:     config.MaxBlacklistEntries,
+		scoreThreshold: config.BlockScoreThreshold,
+		collapse:       config.CollapseAdjacentNetworks,
+	}
+	if len(config.DisabledTags) > 0 {
+		opts.disabledTags = make(map[string]struct{}, len(config.DisabledTags))
+		for _, tag := range config.DisabledTags {
+			opts.disabledTags[strings.TrimSpace(tag)] = struct{}{}
 		}
+		logger.infof(nil, "Disabled tags: %s", strings.Join(config.DisabledTags, ", "))
+	}
+	return opts
+}
 
-		// If not CIDR, try as single IP
-		if ip := net.ParseIP(line); ip != nil {
-			// Convert single IP to /32 CIDR
-			ipNet := &net.IPNet{
-				IP:   ip,
-				Mask: net.CIDRMask(32, 32),
-			}
-			ips = append(ips, ipNet)
+// loadBlacklist loads the blacklist from the sources. If that fails and config fails open, the
+// plugin starts with an empty blacklist, which a later reload fills.
+func (l *initialLists) loadBlacklist(config *Config, logger *logger) error {
+	l.lastReload = time.Now()
+	blacklist, err := loadSources(l.sources, l.blacklistOptions.maxEntries)
+	if l.metrics != nil {
+		l.metrics.observeLoad(l.lastReload)
+	}
+	if err != nil {
+		if !config.FailOpenOnLoadError {
+			return fmt.Errorf("failed to load blacklist: %v", err)
 		}
+		// Start without blocking anything, a later reload loads the list once it is available
+		logger.warnf(nil, "Failed to load blacklist, starting with an empty one: %v", err)
+		blacklist, l.lastReload = &parseResult{}, time.Time{}
+		if config.ReloadIntervalSeconds == 0 && len(config.ReloadPath) == 0 {
+			logger.warnf(nil, "No reload is configured, the blacklist stays empty until Traefik rebuilds the middleware")
+		}
+	}
+	if l.blacklistOptions.collapse {
+		blacklist.collapse(logger)
 	}
 
-	if err := scanner.Err(); err != nil {
-		return nil, err
+	l.blacklist = blacklist
+	return nil
+}
+
+// expandTieredLists returns lists with the environment variables of their paths expanded.
+func expandTieredLists(lists []TieredList) ([]TieredList, error) {
+	expandedLists := make([]TieredList, len(lists))
+	for i, list := range lists {
+		expanded, err := expandEnvPaths([]string{list.Path})
+		if err != nil {
+			return nil, err
+		}
+		list.Path = expanded[0]
+		expandedLists[i] = list
+	}
+	return expandedLists, nil
+}
+
+// log logs what was loaded.
+func (l *initialLists) log(config *Config, logger *logger) {
+	blacklist := l.blacklist
+	logger.infof(logFields{"entries": len(blacklist.networks)}, "Loaded %d blacklisted IPs/Networks", len(blacklist.networks))
+	if len(blacklist.exceptions) > 0 {
+		logger.infof(logFields{"exceptions": len(blacklist.exceptions)},
+			"Loaded %d blacklist exceptions", len(blacklist.exceptions))
+	}
+	if blacklist.duplicates > 0 {
+		logger.infof(logFields{"duplicates": blacklist.duplicates},
+			"Ignored %d duplicate blacklist entries", blacklist.duplicates)
+	}
+	if blacklist.skipped > 0 {
+		logger.infof(logFields{"skipped": blacklist.skipped, "sample": blacklist.skippedSample},
+			"Skipped %d invalid blacklist lines, e.g. %q", blacklist.skipped, blacklist.skippedSample)
+	}
+	if len(l.whitelistPaths) != 0 {
+		logger.infof(logFields{"entries": len(l.whitelist.networks)}, "Loaded %d whitelisted IPs/Networks", len(l.whitelist.networks))
+	}
+	if config.DefaultDeny {
+		logger.infof(nil, "Default deny: only whitelisted IPs are allowed, the blacklist is ignored")
+	} else if len(l.whitelistPaths) != 0 && config.ConflictResolution == conflictResolutionBlacklistWins {
+		logger.infof(nil, "Blacklisted IPs are blocked even when whitelisted")
+	}
+}
+
+// logLocalRequestHandling logs how requests from local IPs are handled.
+func logLocalRequestHandling(config *Config, logger *logger) {
+	logger.infof(nil, "Allow local IPs: %t", config.AllowLocalRequests)
+	if config.AllowLocalRequests && config.StillCheckBlacklistForLocal {
+		logger.infof(nil, "Local IPs are only allowed if no other client IP is blacklisted")
+	}
+	if config.BlocklistPrecedenceOverLocal {
+		logger.infof(nil, "Blacklisted local IPs are denied")
+	}
+	logger.infof(nil, "Log local requests: %t", config.LogLocalRequests)
+	logger.infof(nil, "Log all requests: %t", config.LogAllRequests)
+
+	if len(config.LocalIPRanges) > 0 {
+		logger.infof(nil, "Additional local IP ranges: %s", strings.Join(config.LocalIPRanges, ", "))
 	}
+	if config.TreatCGNATAsPublic {
+		logger.infof(nil, "CGNAT IPs (%s) are never treated as local", cgnatRange)
+	}
+	if len(config.AllowLocalRequestsPaths) > 0 {
+		logger.infof(nil, "Local request handling limited to paths: %s", strings.Join(config.AllowLocalRequestsPaths, ", "))
+	}
+}
 
-	return ips, nil
+// logEnforcement logs whether and how the lists are enforced.
+func logEnforcement(config *Config, logger *logger) {
+	if !config.Enabled {
+		logger.warnf(nil, "Disabled: every request is allowed, the lists are loaded but not enforced")
+	}
+	logger.infof(nil, "Dry run: %t", config.DryRun)
+	if config.AnonymizeLoggedIPs {
+		logger.infof(nil, "Logged IPs are anonymized")
+	}
+}
+
+// logIPEvaluation logs how the client IPs of a request are collected and evaluated.
+func logIPEvaluation(config *Config, logger *logger) {
+	logger.infof(nil, "Client IP headers: %s", strings.Join(config.ClientIPHeaders, ", "))
+	if len(config.TrustedProxies) > 0 {
+		logger.infof(nil, "Trusted proxies: %s", strings.Join(config.TrustedProxies, ", "))
+	} else if len(config.IPPrecedence) > 0 {
+		logger.infof(nil, "IP precedence: %s", strings.Join(config.IPPrecedence, ", "))
+	} else {
+		logger.infof(nil, "IP evaluation mode: %s", config.IPEvaluationMode)
+	}
+
+	if len(config.EvaluationStrategy) != 0 {
+		logger.infof(nil, "Evaluation strategy: %s", config.EvaluationStrategy)
+	}
+	logger.infof(nil, "Default action for requests without a client IP: %s", config.DefaultActionOnNoIP)
+}
+
+// logRequestFilters logs the requests that skip the lists, and the methods the lists apply to.
+func logRequestFilters(config *Config, logger *logger) {
+	if len(config.BlockedMethodsForListedIPs) > 0 {
+		logger.infof(nil, "Blacklisted IPs are only denied for methods: %s", strings.Join(config.BlockedMethodsForListedIPs, ", "))
+	}
+	if len(config.ExcludedPaths) > 0 || len(config.ExcludedMethods) > 0 {
+		logger.infof(nil, "Excluded paths: %s, excluded methods: %s",
+			strings.Join(config.ExcludedPaths, ", "), strings.Join(config.ExcludedMethods, ", "))
+	}
+	if len(config.BypassToken) != 0 {
+		logger.infof(nil, "Bypass header: %s", config.BypassHeader)
+	}
+}
+
+// logAdminPaths logs the paths the middleware answers itself, and who may call them.
+func logAdminPaths(config *Config, logger *logger) {
+	if len(config.StatusPath) != 0 {
+		logger.infof(nil, "Status path: %s", config.StatusPath)
+	}
+	if len(config.ReloadPath) != 0 {
+		logger.infof(nil, "Reload path: %s", config.ReloadPath)
+	}
+	if len(config.CheckPath) != 0 {
+		logger.infof(nil, "Check path: %s", config.CheckPath)
+	}
+	if len(config.AdminAllowedIPs) > 0 {
+		logger.infof(nil, "Admin allowed IPs: %s", strings.Join(config.AdminAllowedIPs, ", "))
+	}
+}
+
+// newMethodSet returns the set of the uppercased methods, or nil if there are none.
+func newMethodSet(methods []string) map[string]struct{} {
+	if len(methods) == 0 {
+		return nil
+	}
+
+	set := make(map[string]struct{}, len(methods))
+	for _, method := range methods {
+		set[strings.ToUpper(strings.TrimSpace(method))] = struct{}{}
+	}
+	return set
+}
+
+// startBackgroundWork starts the periodic reloads, if any, and the eviction of stale rate limit
+// counters, until ctx is done.
+func (a *SimpleBlocklist) startBackgroundWork(ctx context.Context, reloadIntervalSeconds int) {
+	if reloadIntervalSeconds > 0 {
+		a.logger.infof(nil, "Reload interval: %ds", reloadIntervalSeconds)
+		go a.reloadPeriodically(ctx, time.Duration(reloadIntervalSeconds)*time.Second)
+	} else {
+		close(a.reloadDone)
+	}
+
+	if a.rateLimiter != nil {
+		go a.rateLimiter.evictPeriodically(ctx)
+	}
 }
 
 func (a *SimpleBlocklist) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
-	ipAddresses := a.collectRemoteIP(req)
+	if a.serveAdminPath(rw, req) {
+		return
+	}
+
+	if a.disabled || a.isExcluded(req) {
+		a.next.ServeHTTP(rw, req)
+		return
+	}
+
+	if a.isBypassed(req) {
+		remoteAddr := a.logIP(req.RemoteAddr)
+		a.logger.infof(logFields{"ip": remoteAddr, "action": "bypass"},
+			"%s: blocklist bypassed by %s [%s]", a.name, a.bypassHeader, remoteAddr)
+		req.Header.Del(a.bypassHeader)
+		a.next.ServeHTTP(rw, req)
+		return
+	}
+
+	if decision := a.checkRequest(req); decision != nil {
+		a.deny(rw, req, req.RemoteAddr, decision)
+		return
+	}
+
+	ipAddresses, err := a.collectRemoteIP(req)
+	if err != nil {
+		a.logger.warnf(logFields{"ip": a.logIP(req.RemoteAddr)}, "%s: %v", a.name, err)
+		a.deny(rw, req, req.RemoteAddr, &blockDecision{
+			matched: "max-forwarded-for-entries",
+			code:    "max-forwarded-for-entries",
+			reason:  err.Error(),
+			fields:  logFields{},
+		})
+		return
+	}
+
+	clientIP, clientAddr, answered := a.enforceIPs(rw, req, ipAddresses)
+	if answered {
+		return
+	}
+
+	if a.enforceClient(rw, req, clientAddr) {
+		return
+	}
+
+	if a.logAllRequests {
+		a.logger.infof(logFields{"ip": a.logIP(clientIP), "action": "allow", "scope": "public"},
+			"%s: request allowed [%s] - public IP", a.name, a.logIP(clientIP))
+	}
 
-	for _, ipStr := range ipAddresses {
-		ip := net.ParseIP(ipStr)
-		if ip == nil {
-			infoLogger.Printf("Failed to parse IP: %s", ipStr)
+	a.next.ServeHTTP(rw, req)
+}
+
+// serveAdminPath answers a request to the status, reload or check path, and reports whether req
+// was for one of them.
+func (a *SimpleBlocklist) serveAdminPath(rw http.ResponseWriter, req *http.Request) bool {
+	switch {
+	case len(a.statusPath) != 0 && req.URL.Path == a.statusPath:
+		a.serveStatus(rw, req)
+	case len(a.reloadPath) != 0 && req.URL.Path == a.reloadPath:
+		a.serveReload(rw, req)
+	case len(a.checkPath) != 0 && req.URL.Path == a.checkPath:
+		a.serveCheck(rw, req)
+	default:
+		return false
+	}
+	return true
+}
+
+// checkRequest returns the decision denying req whatever its client IP, local IPs included, or nil.
+func (a *SimpleBlocklist) checkRequest(req *http.Request) *blockDecision {
+	if fingerprint := a.blockedCertificate(req); fingerprint != "" {
+		return &blockDecision{
+			matched: "cert:" + fingerprint,
+			code:    "cert:" + fingerprint,
+			reason:  "client certificate is blocked",
+			fields:  logFields{"cert_fingerprint": fingerprint},
+		}
+	}
+
+	// The Host header is checked too, against domain fronting
+	if host := a.blockedHost(req); host != "" {
+		return &blockDecision{
+			matched: "host:" + host,
+			code:    "host:" + host,
+			reason:  "host " + host + " is blocked",
+			fields:  logFields{"host": host},
+		}
+	}
+	return nil
+}
+
+// enforceIPs checks the collected client IPs against the lists and answers req if one of them is
+// blocked or local. Otherwise it returns the first public IP, nil if there is none, for the checks
+// that apply to the client.
+func (a *SimpleBlocklist) enforceIPs(rw http.ResponseWriter, req *http.Request, ipAddresses []string) (clientIP string, clientAddr net.IP, answered bool) {
+	checkLocal, deferLocal := a.localIPPolicy()
+
+	var localIP string
+	for _, classified := range a.selectIPs(a.classifyIPs(req, ipAddresses)) {
+		ipStr, ip := classified.addr, classified.ip
+
+		if !classified.local {
+			if a.enforce(rw, req, ipStr, ip) {
+				return "", nil, true
+			}
+			if clientIP == "" {
+				clientIP, clientAddr = ipStr, ip
+			}
 			continue
 		}
 
-		if isPrivateIP(ip, a.privateIPRanges) {
-			if a.allowLocalRequests {
-				if a.logLocalRequests {
-					infoLogger.Printf("Local IP allowed: %s", ipStr)
-				}
-				a.next.ServeHTTP(rw, req)
-			} else {
-				if a.logLocalRequests {
-					infoLogger.Printf("Local IP denied: %s", ipStr)
-				}
-				rw.WriteHeader(a.httpStatusCodeDeniedRequest)
+		if checkLocal && a.enforce(rw, req, ipStr, ip) {
+			return "", nil, true
+		}
+		if deferLocal {
+			// Allowed once the other IPs passed, so a spoofed private IP can't hide a blacklisted one
+			if localIP == "" {
+				localIP = ipStr
 			}
+			continue
+		}
+		a.serveLocal(rw, req, ipStr)
+		return "", nil, true
+	}
+
+	if localIP != "" {
+		a.serveLocal(rw, req, localIP)
+		return "", nil, true
+	}
+	return clientIP, clientAddr, false
+}
+
+// localIPPolicy reports whether local IPs are checked against the lists, and whether they are only
+// allowed once every other client IP passed. Both apply with deny-if-any-blocked.
+func (a *SimpleBlocklist) localIPPolicy() (check, deferAllow bool) {
+	denyIfAnyBlocked := a.evaluationStrategy == evaluationStrategyDenyIfAnyBlocked
+	return a.blacklistBeforeLocal || denyIfAnyBlocked, a.allowLocalRequests && (a.stillCheckBlacklistForLocal || denyIfAnyBlocked)
+}
+
+// serveLocal allows or denies req from the local IP ipStr, depending on whether local requests
+// are allowed.
+func (a *SimpleBlocklist) serveLocal(rw http.ResponseWriter, req *http.Request, ipStr string) {
+	if a.allowLocalRequests {
+		if a.logLocalRequests || a.logAllRequests {
+			a.logger.infof(logFields{"ip": a.logIP(ipStr), "action": "allow", "scope": "local"}, "Local IP allowed: %s", a.logIP(ipStr))
+		}
+		a.next.ServeHTTP(rw, req)
+		return
+	}
+
+	if a.logLocalRequests && !a.dryRun {
+		a.logger.infof(logFields{"ip": a.logIP(ipStr), "action": "deny"}, "Local IP denied: %s", a.logIP(ipStr))
+	}
+	a.reject(rw, req, ipStr, &blockDecision{
+		matched:    "local",
+		code:       "local-denied",
+		statusCode: a.localDeniedStatusCode,
+	})
+}
+
+// enforceClient denies req if no client IP could be determined and such requests are denied, or if
+// the client exceeds the rate limit, and reports whether the request was answered.
+func (a *SimpleBlocklist) enforceClient(rw http.ResponseWriter, req *http.Request, clientAddr net.IP) bool {
+	// None of the IP sources yielded a valid IP
+	if clientAddr == nil {
+		if !a.denyWithoutIP {
+			return false
+		}
+		a.deny(rw, req, req.RemoteAddr, &blockDecision{
+			matched: "no-ip",
+			code:    "no-ip",
+			reason:  "no client IP could be determined",
+			fields:  logFields{},
+		})
+		return true
+	}
+
+	if a.rateLimiter == nil {
+		return false
+	}
+	limited := a.rateLimitIP(req)
+	if limited == nil || a.rateLimiter.allow(limited, time.Now()) {
+		return false
+	}
+	a.deny(rw, req, limited.String(), &blockDecision{
+		matched: "rate-limit",
+		code:    "rate-limit",
+		reason:  "rate limit exceeded",
+		fields:  logFields{},
+	})
+	return true
+}
+
+// enforce checks ip against the lists and denies or challenges the request if ip is blocked, and
+// reports whether the request was answered. Log-only matches are flagged and not answered.
+func (a *SimpleBlocklist) enforce(rw http.ResponseWriter, req *http.Request, ipStr string, ip net.IP) bool {
+	decision := a.check(ip)
+	if decision == nil || !a.blocksMethod(req, decision) {
+		return false
+	}
+
+	switch {
+	case decision.logOnly:
+		a.flag(ipStr, decision)
+	case a.challengeSecret == nil || a.dryRun:
+		a.deny(rw, req, ipStr, decision)
+		return true
+	case !a.passedChallenge(req, ipStr):
+		a.challenge(rw, req, ipStr, decision)
+		return true
+	}
+	return false
+}
+
+// blockDecision describes why an IP is blocked.
+type blockDecision struct {
+	// matched the network, country or rule that matched.
+	matched string
+	// code a short machine-readable reason, e.g. "blacklist:192.0.2.0/24" or "local-denied".
+	code string
+	// reason a human-readable reason used in logs.
+	reason string
+	// network the blacklisted network that matched, nil for other decisions.
+	network *net.IPNet
+	// statusCode overrides the denied request status code when not 0.
+	statusCode int
+	// expires when the decision stops applying because the matched entry expires, zero if it doesn't.
+	expires time.Time
+	// logOnly the request is only logged, for IPs on a log-only tiered list.
+	logOnly bool
+	fields  logFields
+}
+
+// IsBlocked reports whether ip is blocked by the blacklist, a blocked country, a blocked autonomous
+// system or a blocked hostname, and which blacklisted network matched. The network is nil when ip
+// is blocked by another rule.
+// Local IP handling, exclusions and the rate limit are request-level and not taken into account.
+func (a *SimpleBlocklist) IsBlocked(ip net.IP) (bool, *net.IPNet) {
+	if ip4 := ip.To4(); ip4 != nil {
+		ip = ip4
+	}
+
+	decision := a.check(ip)
+	if decision == nil || decision.logOnly {
+		return false, nil
+	}
+	return true, decision.network
+}
+
+// check returns why ip is blocked by the blacklist, a blocked country, a blocked autonomous system
+// or a blocked hostname, or nil if it isn't. Whitelisted IPs skip the hostname check, see checkLists.
+// Reverse lookups for blocked hostnames run last, outside the lock, and are cached separately.
+func (a *SimpleBlocklist) check(ip net.IP) *blockDecision {
+	if decision := a.checkLists(ip); decision != nil || a.defaultDeny || a.hostnameBlocker == nil || a.isWhitelisted(ip) {
+		return decision
+	}
+
+	hostname, failed := a.hostnameBlocker.match(a.resolver, ip)
+	if len(hostname) != 0 {
+		return &blockDecision{
+			matched: "hostname:" + hostname,
+			code:    "hostname:" + hostname,
+			reason:  "hostname " + hostname + " is blocked",
+			fields:  logFields{"hostname": hostname},
+		}
+	}
+	if failed && a.hostnameBlocker.denyOnFailure {
+		return &blockDecision{
+			matched: "hostname:lookup-failed",
+			code:    "hostname:lookup-failed",
+			reason:  "hostname lookup failed",
+		}
+	}
+	return nil
+}
+
+// isWhitelisted reports whether ip is whitelisted outside default deny mode, where it bypasses the
+// checks.
+func (a *SimpleBlocklist) isWhitelisted(ip net.IP) bool {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	return !a.defaultDeny && a.whitelist.lookup(ip)
+}
+
+// checkLists returns why ip is blocked by the blacklist, a blocked country, a blocked autonomous system
+// or a tiered list, or nil if it isn't.
+// Exceptions from "!" entries take precedence over blacklisted networks, and whitelisted IPs are
+// never blocked, unless the blacklist wins conflicts, in which case only the blacklist applies to them.
+// In default deny mode it instead blocks every IP that isn't whitelisted.
+// Decisions are cached when the decision cache is enabled.
+func (a *SimpleBlocklist) checkLists(ip net.IP) *blockDecision {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	key := ip.String()
+	now := time.Now()
+	if decision, ok := a.cachedDecision(key, now); ok {
+		return decision
+	}
+
+	decision := a.decideLists(ip, now)
+	if a.cache != nil {
+		a.cache.add(key, decision)
+	}
+	return decision
+}
+
+// cachedDecision returns the cached decision for key, unless there is none or it expired at now.
+func (a *SimpleBlocklist) cachedDecision(key string, now time.Time) (*blockDecision, bool) {
+	if a.cache == nil {
+		return nil, false
+	}
+	decision, ok := a.cache.get(key)
+	if !ok || decision != nil && !decision.expires.IsZero() && !now.Before(decision.expires) {
+		return nil, false
+	}
+	return decision, true
+}
+
+// decideLists returns why ip is blocked by the lists, see checkLists. The caller must hold a.mu.
+func (a *SimpleBlocklist) decideLists(ip net.IP, now time.Time) *blockDecision {
+	if a.defaultDeny {
+		// Only whitelisted IPs are allowed, the blacklist and blocked countries don't apply
+		if a.whitelist.lookup(ip) {
+			return nil
+		}
+		return &blockDecision{
+			matched: "default-deny",
+			code:    "default-deny",
+			reason:  "IP is not whitelisted",
+			fields:  logFields{},
+		}
+	}
+
+	// Whitelisted IPs skip every check, except the blacklist when it wins conflicts
+	whitelisted := a.whitelist.lookup(ip)
+	if !whitelisted || a.blacklistWins {
+		if decision := a.matchBlacklist(ip, now); decision != nil {
+			return decision
+		}
+	}
+	if whitelisted {
+		return nil
+	}
+
+	if decision := a.matchCountry(ip); decision != nil {
+		return decision
+	}
+	if decision := a.matchASN(ip); decision != nil {
+		return decision
+	}
+	return a.matchTiers(ip)
+}
+
+// matchBlacklist returns the decision blocking ip if it is blacklisted, and not excepted, at now.
+// The caller must hold a.mu.
+func (a *SimpleBlocklist) matchBlacklist(ip net.IP, now time.Time) *blockDecision {
+	network := a.blacklist.matchWhere(ip, a.activeAt(now))
+	if network == nil || a.exceptions.lookup(ip) {
+		return nil
+	}
+
+	decision := &blockDecision{
+		matched: network.String(),
+		code:    "blacklist:" + network.String(),
+		reason:  "IP is blacklisted",
+		network: network,
+		fields:  logFields{"matched_network": network.String()},
+	}
+	if annotation, ok := a.annotations[network.String()]; ok {
+		if len(annotation.reason) != 0 {
+			decision.reason += ": " + annotation.reason
+			decision.fields["entry_reason"] = annotation.reason
+		}
+		if !annotation.expires.IsZero() {
+			decision.expires = annotation.expires
+			decision.fields["entry_expires"] = annotation.expires.Format(time.RFC3339)
+		}
+	}
+	return decision
+}
+
+// matchCountry returns the decision blocking ip if it is located in a blocked country.
+func (a *SimpleBlocklist) matchCountry(ip net.IP) *blockDecision {
+	if a.countryBlocker == nil {
+		return nil
+	}
+
+	country, blocked, err := a.countryBlocker.lookup(ip)
+	if err != nil {
+		a.logger.infof(logFields{"ip": a.logIP(ip.String())}, "Failed to look up country for IP %s: %v", a.logIP(ip.String()), err)
+		return nil
+	}
+	if !blocked {
+		return nil
+	}
+	return &blockDecision{
+		matched: "country:" + country,
+		code:    "country:" + country,
+		reason:  "country " + country + " is blocked",
+		fields:  logFields{"country": country},
+	}
+}
+
+// matchASN returns the decision blocking ip if it is announced by a blocked autonomous system.
+func (a *SimpleBlocklist) matchASN(ip net.IP) *blockDecision {
+	if a.asnBlocker == nil {
+		return nil
+	}
+
+	as, blocked, err := a.asnBlocker.lookup(ip)
+	if err != nil {
+		a.logger.infof(logFields{"ip": a.logIP(ip.String())}, "Failed to look up ASN for IP %s: %v", a.logIP(ip.String()), err)
+		return nil
+	}
+	if !blocked {
+		return nil
+	}
+	asn := "AS" + strconv.FormatUint(uint64(as.Number), 10)
+	return &blockDecision{
+		matched: "asn:" + asn,
+		code:    "asn:" + asn,
+		reason:  asn + " (" + as.Organization + ") is blocked",
+		fields:  logFields{"asn": as.Number, "as_organization": as.Organization},
+	}
+}
+
+// activeAt returns a filter accepting the blacklisted networks that haven't expired at now.
+// The caller must hold a.mu.
+func (a *SimpleBlocklist) activeAt(now time.Time) func(*net.IPNet) bool {
+	if len(a.annotations) == 0 {
+		return nil
+	}
+	return func(network *net.IPNet) bool {
+		return !a.annotations[network.String()].expired(now)
+	}
+}
+
+// Reload reloads the blacklist sources and whitelist files and swaps in the new lists, invalidating cached
+// decisions. Requests keep being served from the current lists while the files are loaded, and the
+// current lists are kept if loading fails.
+func (a *SimpleBlocklist) Reload() error {
+	a.reloadMu.Lock()
+	defer a.reloadMu.Unlock()
+
+	start := time.Now()
+	result, whitelistResult, tiers, err := a.loadLists()
+	if a.metrics != nil {
+		a.metrics.observeLoad(start)
+		if err != nil {
+			a.metrics.reloadFailed()
+		}
+	}
+	if err != nil {
+		return err
+	}
+	blacklist := newIPTrie(result.networks)
+	exceptions := newIPTrie(result.exceptions)
+	whitelist := newIPTrie(whitelistResult.networks)
+
+	a.mu.Lock()
+	a.blacklist = blacklist
+	a.exceptions = exceptions
+	a.annotations = result.annotations
+	a.whitelist = whitelist
+	a.tiers = tiers
+	a.networks = len(result.networks)
+	a.lastReload = time.Now()
+	if a.cache != nil {
+		a.cache.purge()
+	}
+	a.mu.Unlock()
+
+	a.logger.infof(logFields{"entries": len(result.networks)}, "Reloaded %d blacklisted IPs/Networks", len(result.networks))
+	a.warnListConflicts(blacklist, whitelist, result.networks, whitelistResult.networks)
+	a.reloadDeniedPage()
+	return nil
+}
+
+// loadLists loads the blacklist sources, the whitelist and the tiered lists.
+func (a *SimpleBlocklist) loadLists() (blacklist, whitelist *parseResult, tiers []*listTier, err error) {
+	if blacklist, err = loadSources(a.sources, a.blacklistOptions.maxEntries); err != nil {
+		return nil, nil, nil, err
+	}
+	if a.blacklistOptions.collapse {
+		blacklist.collapse(a.logger)
+	}
+	if whitelist, err = loadBlacklists(a.whitelistPaths, a.whitelistOptions, a.logger); err != nil {
+		return nil, nil, nil, err
+	}
+	if tiers, err = loadTiers(a.tieredLists, a.blacklistOptions, a.logger); err != nil {
+		return nil, nil, nil, err
+	}
+	return blacklist, whitelist, tiers, nil
+}
+
+// reloadPeriodically reloads the blacklist every interval until ctx is done, so the goroutine
+// doesn't outlive the middleware when Traefik rebuilds it on a configuration change.
+func (a *SimpleBlocklist) reloadPeriodically(ctx context.Context, interval time.Duration) {
+	defer close(a.reloadDone)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
 			return
+		case <-ticker.C:
+			if err := a.Reload(); err != nil {
+				a.logger.warnf(nil, "Failed to reload blacklist, keeping the current one: %v", err)
+			}
 		}
+	}
+}
+
+// deny logs why the request from ip is blocked, with the request ID for correlation, and rejects it.
+func (a *SimpleBlocklist) deny(rw http.ResponseWriter, req *http.Request, ip string, decision *blockDecision) {
+	// Decisions that aren't about an IP, e.g. a blocked certificate, report RemoteAddr with its port
+	if a.blockCounter != nil && parseIP(ip) != nil {
+		a.blockCounter.add(ip)
+	}
+	if !a.dryRun {
+		requestID := a.requestID(req)
+		fields := logFields{"ip": a.logIP(ip), "action": "deny", "matched": decision.matched, "request_id": requestID}
+		for key, value := range decision.fields {
+			fields[key] = value
+		}
+		a.logger.infof(fields, "%s: request denied [%s] matched [%s] - %s (request ID %s)",
+			a.name, a.logIP(ip), decision.matched, decision.reason, requestID)
+	}
+	a.reject(rw, req, ip, decision)
+}
+
+// reject writes the denied status code, or the decision's own status code if it has one, with a
+// Retry-After header if configured. It redirects to the denied redirect URL instead if one is configured.
+// In dry-run mode the decision is only logged and the request is forwarded to the next handler.
+func (a *SimpleBlocklist) reject(rw http.ResponseWriter, req *http.Request, ip string, decision *blockDecision) {
+	if a.dryRun {
+		a.logger.infof(logFields{"ip": a.logIP(ip), "action": "would-block", "matched": decision.matched},
+			"%s: would block [%s] matched [%s]", a.name, a.logIP(ip), decision.matched)
+		a.next.ServeHTTP(rw, req)
+		return
+	}
+
+	if a.debugHeaders {
+		rw.Header().Set(xBlockedReason, decision.code)
+	}
+
+	if len(a.deniedRedirectURL) != 0 {
+		http.Redirect(rw, req, a.deniedRedirectURL, a.deniedRedirectStatusCode)
+		return
+	}
+
+	statusCode := a.httpStatusCodeDeniedRequest
+	if decision.statusCode != 0 {
+		statusCode = decision.statusCode
+	}
+
+	// Retry-After is only meaningful for 429 Too Many Requests and 503 Service Unavailable
+	if a.retryAfterSeconds > 0 && (statusCode == http.StatusTooManyRequests || statusCode == http.StatusServiceUnavailable) {
+		rw.Header().Set("Retry-After", strconv.Itoa(a.retryAfterSeconds))
+	}
+
+	switch {
+	case a.problemJSON:
+		a.writeProblem(rw, ip, statusCode)
+	case a.deniedTemplate != nil:
+		a.writeDeniedTemplate(rw, ip, statusCode, decision)
+	default:
+		a.writeDeniedPage(rw, ip, statusCode)
+	}
+}
+
+// writeDeniedPage answers a denied request with the denied response file, if any, or an empty body.
+func (a *SimpleBlocklist) writeDeniedPage(rw http.ResponseWriter, ip string, statusCode int) {
+	a.mu.RLock()
+	page := a.deniedPage
+	a.mu.RUnlock()
 
-		for _, blacklistedNet := range a.blacklistedIPs {
-			if blacklistedNet.Contains(ip) {
-				infoLogger.Printf("%s: request denied [%s] - IP is blacklisted", a.name, ipStr)
-				rw.WriteHeader(a.httpStatusCodeDeniedRequest)
-				return
+	if page == nil {
+		rw.WriteHeader(statusCode)
+		return
+	}
+
+	rw.Header().Set("Content-Type", page.contentType)
+	rw.WriteHeader(statusCode)
+	if _, err := rw.Write(page.body); err != nil {
+		a.logger.warnf(logFields{"ip": a.logIP(ip)}, "%s: failed to write the denied response file: %v", a.name, err)
+	}
+}
+
+// writeDeniedTemplate answers a denied request with the rendered denied request template.
+func (a *SimpleBlocklist) writeDeniedTemplate(rw http.ResponseWriter, ip string, statusCode int, decision *blockDecision) {
+	// Render before writing anything, so a failed template still gets a clean status
+	var body bytes.Buffer
+	if err := a.deniedTemplate.Execute(&body, deniedTemplateData{
+		IP:             ip,
+		MatchedNetwork: decision.matched,
+		Reason:         decision.reason,
+		Timestamp:      time.Now().UTC(),
+	}); err != nil {
+		a.logger.warnf(logFields{"ip": a.logIP(ip)}, "%s: failed to render the denied request template: %v", a.name, err)
+		rw.WriteHeader(statusCode)
+		return
+	}
+
+	rw.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	rw.WriteHeader(statusCode)
+	if _, err := body.WriteTo(rw); err != nil {
+		a.logger.warnf(logFields{"ip": a.logIP(ip)}, "%s: failed to write the denied request body: %v", a.name, err)
+	}
+}
+
+// deniedTemplateData the fields available to the denied request template.
+type deniedTemplateData struct {
+	IP string
+	// MatchedNetwork the network, country or rule that matched.
+	MatchedNetwork string
+	Reason         string
+	Timestamp      time.Time
+}
+
+// requestID returns the correlation ID carried in the request ID header, or a random short ID
+// if the request has none, so every logged decision can be traced.
+func (a *SimpleBlocklist) requestID(req *http.Request) string {
+	if id := req.Header.Get(a.requestIDHeader); len(id) != 0 {
+		return id
+	}
+
+	id := make([]byte, 8)
+	if _, err := rand.Read(id); err != nil {
+		return "unknown"
+	}
+	return hex.EncodeToString(id)
+}
+
+// isBypassed reports whether the request carries the bypass token. The token is compared
+// in constant time so it can't be guessed from response timings.
+func (a *SimpleBlocklist) isBypassed(req *http.Request) bool {
+	if len(a.bypassToken) == 0 {
+		return false
+	}
+
+	token := req.Header.Get(a.bypassHeader)
+	return subtle.ConstantTimeCompare([]byte(token), a.bypassToken) == 1
+}
+
+// validateDeniedStatusCode checks that code is a known client or server error status code.
+func validateDeniedStatusCode(code int) error {
+	if len(http.StatusText(code)) == 0 {
+		return fmt.Errorf("invalid denied request status code supplied")
+	}
+	if code < 400 || code > 599 {
+		return fmt.Errorf("denied request status code %d is not a client or server error (400-599)", code)
+	}
+	return nil
+}
+
+// blocksMethod reports whether decision applies to the method of req. Blacklist matches only apply
+// to the blocked methods when some are configured, e.g. so flagged IPs can still read public pages.
+// Other decisions apply to every method.
+func (a *SimpleBlocklist) blocksMethod(req *http.Request, decision *blockDecision) bool {
+	if len(a.blockedMethods) == 0 || decision.network == nil {
+		return true
+	}
+	_, blocked := a.blockedMethods[req.Method]
+	return blocked
+}
+
+// isLocalRequestPath reports whether local IP handling applies to the request path. It always does
+// unless it is limited to path prefixes, in which case local IPs go through the normal checks elsewhere.
+func (a *SimpleBlocklist) isLocalRequestPath(req *http.Request) bool {
+	if len(a.localRequestsPaths) == 0 {
+		return true
+	}
+
+	for _, prefix := range a.localRequestsPaths {
+		if strings.HasPrefix(req.URL.Path, prefix) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// isExcluded reports whether the request skips all IP checks, based on its method or path.
+// Paths match exactly, or by prefix when the configured path ends with "*".
+func (a *SimpleBlocklist) isExcluded(req *http.Request) bool {
+	if _, ok := a.excludedMethods[req.Method]; ok {
+		return true
+	}
+
+	for _, path := range a.excludedPaths {
+		if prefix := strings.TrimSuffix(path, "*"); prefix != path {
+			if strings.HasPrefix(req.URL.Path, prefix) {
+				return true
 			}
+		} else if req.URL.Path == path {
+			return true
 		}
 	}
 
-	a.next.ServeHTTP(rw, req)
+	return false
 }
 
-func (a *SimpleBlocklist) collectRemoteIP(req *http.Request) []string {
+// collectRemoteIP returns the client IPs to evaluate according to the IP evaluation mode:
+// all IPs from the configured headers followed by RemoteAddr, only RemoteAddr, or only the
+// first or last header IP (falling back to RemoteAddr when the headers are empty). The IP from the
+// PROXY protocol header, when present, or the client IP behind the trusted proxies, when they are
+// configured, is evaluated alone instead.
+func (a *SimpleBlocklist) collectRemoteIP(req *http.Request) ([]string, error) {
+	if ip := a.proxyProtocolIP(req); ip != "" {
+		return []string{ip}, nil
+	}
+	if len(a.trustedProxies) > 0 {
+		return a.collectTrustedIP(req)
+	}
+	if len(a.ipPrecedence) > 0 {
+		return a.collectIPByPrecedence(req)
+	}
+
 	var ipList []string
 
-	// Get IPs from X-Forwarded-For
-	xff := req.Header.Get(xForwardedFor)
-	if xff != "" {
-		for _, addr := range strings.Split(xff, ",") {
-			addr = strings.TrimSpace(addr)
-			if addr != "" {
-				ipList = append(ipList, addr)
-			}
+	if a.ipEvaluationMode != ipEvaluationModeRemoteOnly {
+		headerIPs, err := a.collectHeaderIPs(req)
+		if err != nil {
+			return nil, err
+		}
+
+		switch {
+		case len(headerIPs) == 0:
+		case a.ipEvaluationMode == ipEvaluationModeXFFFirst:
+			return headerIPs[:1], nil
+		case a.ipEvaluationMode == ipEvaluationModeXFFLast:
+			return headerIPs[len(headerIPs)-1:], nil
+		default:
+			ipList = headerIPs
 		}
 	}
 
-	// Get IP from X-Real-IP
-	if xRealIP := req.Header.Get(xRealIP); xRealIP != "" {
-		ipList = append(ipList, strings.TrimSpace(xRealIP))
+	if ip := remoteAddrIP(req); ip != "" {
+		ipList = append(ipList, ip)
+	}
+
+	return ipList, nil
+}
+
+// rateLimitIP returns the IP the requests of req are counted against: the PROXY protocol client IP,
+// or the client behind the trusted proxies, which is the connection IP when there are none. Unlike
+// the IPs checked against the lists, it never comes from a header set by an untrusted peer, since
+// a client could otherwise spread its requests over as many counters as it likes.
+func (a *SimpleBlocklist) rateLimitIP(req *http.Request) net.IP {
+	if ip := a.proxyProtocolIP(req); ip != "" {
+		return parseIP(ip)
+	}
+	ips, err := a.collectTrustedIP(req)
+	if err != nil {
+		return parseIP(remoteAddrIP(req))
+	}
+	return parseIP(ips[0])
+}
+
+// proxyProtocolIP returns the client IP from the PROXY protocol header, if configured, or an empty
+// string if the header is missing or holds no valid IP. The header holds either the client IP, as
+// set by Traefik or the load balancer, or the PROXY protocol v1 line as received, e.g.
+// "PROXY TCP4 192.0.2.1 198.51.100.1 56324 443". The header is only honored on requests from a
+// trusted proxy, since any client could send it otherwise.
+func (a *SimpleBlocklist) proxyProtocolIP(req *http.Request) string {
+	if len(a.proxyProtocolHeader) == 0 {
+		return ""
+	}
+	if remote := parseIP(remoteAddrIP(req)); remote == nil || !a.isTrustedProxy(remote) {
+		return ""
+	}
+
+	value := strings.TrimSpace(req.Header.Get(a.proxyProtocolHeader))
+	if fields := strings.Fields(value); len(fields) >= 3 && fields[0] == "PROXY" {
+		value = fields[2]
+	}
+	value = stripPort(value)
+	if parseIP(value) == nil {
+		return ""
 	}
+	return value
+}
+
+// collectIPByPrecedence returns the first valid IP of the first IP source, in precedence order,
+// that has one. Only that IP is evaluated. The leftmost valid X-Forwarded-For entry is used.
+func (a *SimpleBlocklist) collectIPByPrecedence(req *http.Request) ([]string, error) {
+	for _, source := range a.ipPrecedence {
+		var candidates []string
+		switch source {
+		case ipSourceRemoteAddr:
+			candidates = []string{remoteAddrIP(req)}
+		case ipSourceXRealIP:
+			candidates = []string{strings.TrimSpace(req.Header.Get(xRealIP))}
+		case ipSourceXFF:
+			value := req.Header.Get(xForwardedFor)
+			if a.maxForwardedForEntries > 0 && strings.Count(value, ",") >= a.maxForwardedForEntries {
+				return nil, fmt.Errorf("%s header has more than %d entries", xForwardedFor, a.maxForwardedForEntries)
+			}
+			candidates = strings.Split(value, ",")
+		}
+
+		for _, candidate := range candidates {
+			candidate = stripPort(strings.TrimSpace(candidate))
+			if parseIP(candidate) != nil {
+				return []string{candidate}, nil
+			}
+		}
+	}
+
+	return nil, nil
+}
 
-	// Get IP from RemoteAddr
+// remoteAddrIP returns the IP of the connection, or RemoteAddr as-is if it has no port, without
+// its IPv6 zone.
+func remoteAddrIP(req *http.Request) string {
 	ip, _, err := net.SplitHostPort(req.RemoteAddr)
 	if err != nil {
 		// If SplitHostPort fails, try using RemoteAddr directly
-		remoteAddr := strings.TrimSpace(req.RemoteAddr)
-		if remoteAddr != "" {
-			ipList = append(ipList, remoteAddr)
+		return stripZone(strings.TrimSpace(req.RemoteAddr))
+	}
+	return stripZone(ip)
+}
+
+// collectHeaderIPs returns the IPs found in the configured headers, in order.
+// It fails without splitting a header that holds more than maxForwardedForEntries IPs,
+// so a flood of forwarded addresses can't be used to amplify the work done per request.
+func (a *SimpleBlocklist) collectHeaderIPs(req *http.Request) ([]string, error) {
+	var ipList []string
+	for _, header := range a.clientIPHeaders {
+		value := req.Header.Get(header)
+		if a.maxForwardedForEntries > 0 && strings.Count(value, ",") >= a.maxForwardedForEntries {
+			return nil, fmt.Errorf("%s header has more than %d entries", header, a.maxForwardedForEntries)
 		}
-	} else {
-		ipList = append(ipList, ip)
+
+		for _, addr := range strings.Split(value, ",") {
+			addr = stripPort(strings.TrimSpace(addr))
+			if addr != "" {
+				ipList = append(ipList, addr)
+			}
+		}
+	}
+
+	return ipList, nil
+}
+
+// stripPort removes the port some proxies add to the addresses of the client IP headers, as in
+// "192.0.2.1:443" or "[2001:db8::1]:8080", and the brackets of "[2001:db8::1]", as well as the
+// zone of link-local IPv6 addresses, see stripZone. Other values, bare IPv6 addresses included,
+// are returned unchanged.
+func stripPort(addr string) string {
+	if host, _, err := net.SplitHostPort(addr); err == nil {
+		return stripZone(host)
+	}
+	if strings.HasPrefix(addr, "[") && strings.HasSuffix(addr, "]") {
+		addr = addr[1 : len(addr)-1]
 	}
+	return stripZone(addr)
+}
 
-	return ipList
+// stripZone removes the zone of an IPv6 address such as "fe80::1%eth0", which net.ParseIP rejects.
+// The zone only names the interface of a link-local address, so it doesn't matter for matching.
+func stripZone(addr string) string {
+	if i := strings.IndexByte(addr, '%'); i >= 0 && strings.Contains(addr[:i], ":") {
+		return addr[:i]
+	}
+	return addr
+}
+
+// parseIP parses an IP address. IPv4-mapped IPv6 addresses such as "::ffff:192.0.2.1", as seen
+// on dual-stack listeners, are returned in their 4-byte form so they compare equal to IPv4 entries.
+func parseIP(s string) net.IP {
+	ip := net.ParseIP(s)
+	if ip4 := ip.To4(); ip4 != nil {
+		return ip4
+	}
+	return ip
 }
 
 func initPrivateIPBlocks() []*net.IPNet {
@@ -216,6 +1617,19 @@ func initPrivateIPBlocks() []*net.IPNet {
 	return privateIPBlocks
 }
 
+// cgnatRange the RFC 6598 shared address space used by carrier-grade NAT.
+var cgnatRange = &net.IPNet{IP: net.IPv4(100, 64, 0, 0).To4(), Mask: net.CIDRMask(10, 8*net.IPv4len)}
+
+// isLocalIP reports whether ip is local, see isPrivateIP. CGNAT IPs represent real external users
+// behind a carrier NAT, so they aren't local when treated as public, even if a configured local
+// range covers them.
+func (a *SimpleBlocklist) isLocalIP(ip net.IP) bool {
+	if a.treatCGNATAsPublic && cgnatRange.Contains(ip) {
+		return false
+	}
+	return isPrivateIP(ip, a.privateIPRanges)
+}
+
 func isPrivateIP(ip net.IP, privateIPBlocks []*net.IPNet) bool {
 	if ip.IsLoopback() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() {
 		return true
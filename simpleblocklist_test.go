@@ -1,11 +1,18 @@
 package simpleblocklist_test
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
+	"net"
 	"net/http"
 	"net/http/httptest"
 	"os"
+	"path/filepath"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/LucaNori/traefik-simpleblocklist"
 )
@@ -24,7 +31,7 @@ func TestSimpleBlocklist(t *testing.T) {
 203.0.113.2
 
 # Network blocks
-192.168.1.0/24
+198.51.100.0/24
 2001:db8::/32
 
 # IPv6 addresses
@@ -32,7 +39,7 @@ func TestSimpleBlocklist(t *testing.T) {
 
 # Empty lines and comments should be ignored
 
-10.0.0.1  # With comment
+203.0.113.10  # With comment
 `)
 	if _, err := tmpfile.Write(content); err != nil {
 		t.Fatal(err)
@@ -63,7 +70,7 @@ func TestSimpleBlocklist(t *testing.T) {
 		},
 		{
 			desc:           "IP in blacklisted network range",
-			xForwardedFor:  "192.168.1.100",
+			xForwardedFor:  "198.51.100.100",
 			blacklisted:    true,
 			expectedStatus: 403,
 		},
@@ -105,7 +112,7 @@ func TestSimpleBlocklist(t *testing.T) {
 		},
 		{
 			desc:           "IP with inline comment",
-			xForwardedFor:  "10.0.0.1",
+			xForwardedFor:  "203.0.113.10",
 			blacklisted:    true,
 			expectedStatus: 403,
 		},
@@ -153,6 +160,27 @@ func TestSimpleBlocklist(t *testing.T) {
 	}
 }
 
+func TestSimpleBlocklist_BlacklistPathsLoadedOnce(t *testing.T) {
+	var buf bytes.Buffer
+	defer simpleblocklist.SetLogOutput(&buf)()
+
+	path := createBlacklistFile(t, "192.0.2.1\n")
+
+	cfg := simpleblocklist.CreateConfig()
+	cfg.BlacklistPath = path
+	cfg.BlacklistPaths = []string{path, filepath.Join(filepath.Dir(path), "*.txt")}
+
+	next := http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {})
+
+	if _, err := simpleblocklist.New(context.Background(), next, cfg, "simpleblocklist"); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := strings.Count(buf.String(), "Loaded 1 IPs/Networks from "+path); got != 1 {
+		t.Errorf("got the file loaded %d times, want once: %q", got, buf.String())
+	}
+}
+
 func TestSimpleBlocklist_NoBlacklistFile(t *testing.T) {
 	cfg := simpleblocklist.CreateConfig()
 	cfg.BlacklistPath = "nonexistent.txt"
@@ -166,6 +194,354 @@ func TestSimpleBlocklist_NoBlacklistFile(t *testing.T) {
 	}
 }
 
+func TestSimpleBlocklist_MultipleBlacklists(t *testing.T) {
+	first := createBlacklistFile(t, "192.0.2.1\n")
+	second := createBlacklistFile(t, "203.0.113.2\n")
+
+	tests := []struct {
+		desc        string
+		paths       []string
+		skip        bool
+		expectError bool
+	}{
+		{
+			desc:  "Both files",
+			paths: []string{first, second},
+		},
+		{
+			desc:        "Unreadable file is fatal",
+			paths:       []string{first, "nonexistent.txt", second},
+			expectError: true,
+		},
+		{
+			desc:  "Unreadable file is skipped",
+			paths: []string{first, "nonexistent.txt", second},
+			skip:  true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			cfg := simpleblocklist.CreateConfig()
+			cfg.BlacklistPaths = test.paths
+			cfg.SkipUnreadableBlacklists = test.skip
+
+			ctx := context.Background()
+			next := http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+				rw.WriteHeader(http.StatusOK)
+			})
+
+			handler, err := simpleblocklist.New(ctx, next, cfg, "simpleblocklist")
+			if test.expectError {
+				if err == nil {
+					t.Error("expected error when a blacklist file doesn't exist")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			for _, ip := range []string{"192.0.2.1", "203.0.113.2"} {
+				recorder := httptest.NewRecorder()
+				req, err := http.NewRequestWithContext(ctx, http.MethodGet, "http://localhost", nil)
+				if err != nil {
+					t.Fatal(err)
+				}
+				req.Header.Set("X-Forwarded-For", ip)
+
+				handler.ServeHTTP(recorder, req)
+
+				if recorder.Code != 403 {
+					t.Errorf("%s: got status code %d, want 403", ip, recorder.Code)
+				}
+			}
+		})
+	}
+}
+
+func TestSimpleBlocklist_BlacklistDir(t *testing.T) {
+	dir := t.TempDir()
+	for name, content := range map[string]string{
+		"team-a.txt": "192.0.2.1\n",
+		"team-b.txt": "203.0.113.2\n",
+		"notes.md":   "198.51.100.3\n",
+	} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	cfg := simpleblocklist.CreateConfig()
+	cfg.BlacklistDir = dir
+
+	ctx := context.Background()
+	next := http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		rw.WriteHeader(http.StatusOK)
+	})
+
+	handler, err := simpleblocklist.New(ctx, next, cfg, "simpleblocklist")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		desc           string
+		ip             string
+		expectedStatus int
+	}{
+		{desc: "First team file", ip: "192.0.2.1", expectedStatus: http.StatusForbidden},
+		{desc: "Second team file", ip: "203.0.113.2", expectedStatus: http.StatusForbidden},
+		{desc: "File without the .txt extension is ignored", ip: "198.51.100.3", expectedStatus: http.StatusOK},
+	}
+
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			recorder := httptest.NewRecorder()
+			req, err := http.NewRequestWithContext(ctx, http.MethodGet, "http://localhost", nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			req.Header.Set("X-Forwarded-For", test.ip)
+
+			handler.ServeHTTP(recorder, req)
+
+			if recorder.Code != test.expectedStatus {
+				t.Errorf("got status code %d, want %d", recorder.Code, test.expectedStatus)
+			}
+		})
+	}
+}
+
+func TestSimpleBlocklist_FailOpenOnLoadError(t *testing.T) {
+	blacklistPath := filepath.Join(t.TempDir(), "blacklist.txt")
+
+	cfg := simpleblocklist.CreateConfig()
+	cfg.BlacklistPath = blacklistPath
+	cfg.FailOpenOnLoadError = true
+
+	ctx := context.Background()
+	next := http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		rw.WriteHeader(http.StatusOK)
+	})
+
+	handler, err := simpleblocklist.New(ctx, next, cfg, "simpleblocklist")
+	if err != nil {
+		t.Fatalf("expected New to succeed with a missing blacklist, got %v", err)
+	}
+
+	serve := func() int {
+		recorder := httptest.NewRecorder()
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, "http://localhost", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("X-Forwarded-For", "192.0.2.1")
+
+		handler.ServeHTTP(recorder, req)
+		return recorder.Code
+	}
+
+	if code := serve(); code != http.StatusOK {
+		t.Errorf("before the file exists: got status code %d, want %d", code, http.StatusOK)
+	}
+
+	if err := os.WriteFile(blacklistPath, []byte("192.0.2.1\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := simpleblocklist.Reload(handler); err != nil {
+		t.Fatal(err)
+	}
+
+	if code := serve(); code != http.StatusForbidden {
+		t.Errorf("after the file appeared: got status code %d, want %d", code, http.StatusForbidden)
+	}
+}
+
+func TestSimpleBlocklist_DeniedRequestTemplate(t *testing.T) {
+	tests := []struct {
+		desc         string
+		template     string
+		expectedBody string
+	}{
+		{
+			desc:         "renders the request fields",
+			template:     "Access denied for {{.IP}}: {{.Reason}} [{{.MatchedNetwork}}]",
+			expectedBody: "Access denied for 192.0.2.1: IP is blacklisted [192.0.2.0/24]",
+		},
+		{
+			desc:         "execution error sends no body",
+			template:     "Access denied for {{.Missing}}",
+			expectedBody: "",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			cfg := simpleblocklist.CreateConfig()
+			cfg.BlacklistPath = createBlacklistFile(t, "192.0.2.0/24\n")
+			cfg.DeniedRequestTemplate = test.template
+
+			ctx := context.Background()
+			next := http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+				rw.WriteHeader(http.StatusOK)
+			})
+
+			handler, err := simpleblocklist.New(ctx, next, cfg, "simpleblocklist")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			recorder := httptest.NewRecorder()
+			req, err := http.NewRequestWithContext(ctx, http.MethodGet, "http://localhost", nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			req.Header.Set("X-Forwarded-For", "192.0.2.1")
+
+			handler.ServeHTTP(recorder, req)
+
+			if recorder.Code != http.StatusForbidden {
+				t.Errorf("got status code %d, want %d", recorder.Code, http.StatusForbidden)
+			}
+			if body := recorder.Body.String(); body != test.expectedBody {
+				t.Errorf("got body %q, want %q", body, test.expectedBody)
+			}
+		})
+	}
+}
+
+func TestSimpleBlocklist_InvalidDeniedRequestTemplate(t *testing.T) {
+	cfg := simpleblocklist.CreateConfig()
+	cfg.BlacklistPath = createBlacklistFile(t, "192.0.2.1\n")
+	cfg.DeniedRequestTemplate = "Access denied for {{.IP"
+
+	next := http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {})
+
+	if _, err := simpleblocklist.New(context.Background(), next, cfg, "simpleblocklist"); err == nil {
+		t.Error("expected error for an unparsable template")
+	}
+}
+
+func TestSimpleBlocklist_DeniedResponseProblemJSON(t *testing.T) {
+	cfg := simpleblocklist.CreateConfig()
+	cfg.BlacklistPath = createBlacklistFile(t, "192.0.2.0/24\n")
+	cfg.DeniedResponseFormat = "problem+json"
+
+	ctx := context.Background()
+	next := http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		rw.WriteHeader(http.StatusOK)
+	})
+
+	handler, err := simpleblocklist.New(ctx, next, cfg, "simpleblocklist")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	recorder := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "http://localhost", nil)
+	req.RemoteAddr = "192.0.2.1:1234"
+	handler.ServeHTTP(recorder, req)
+
+	if recorder.Code != http.StatusForbidden {
+		t.Errorf("got status code %d, want %d", recorder.Code, http.StatusForbidden)
+	}
+	if contentType := recorder.Header().Get("Content-Type"); contentType != "application/problem+json" {
+		t.Errorf("got Content-Type %q, want application/problem+json", contentType)
+	}
+
+	var problem map[string]interface{}
+	if err := json.Unmarshal(recorder.Body.Bytes(), &problem); err != nil {
+		t.Fatalf("invalid problem details %q: %v", recorder.Body.String(), err)
+	}
+	for _, field := range []string{"type", "title", "status", "detail"} {
+		if _, ok := problem[field]; !ok {
+			t.Errorf("missing problem details field %q in %q", field, recorder.Body.String())
+		}
+	}
+	if problem["status"] != float64(http.StatusForbidden) || problem["title"] != "Forbidden" {
+		t.Errorf("got status %v and title %v, want 403 and Forbidden", problem["status"], problem["title"])
+	}
+	if strings.Contains(recorder.Body.String(), "192.0.2.0/24") {
+		t.Errorf("the problem details leak the matched network: %q", recorder.Body.String())
+	}
+}
+
+func TestSimpleBlocklist_DeniedResponseFile(t *testing.T) {
+	pagePath := filepath.Join(t.TempDir(), "denied.html")
+	if err := os.WriteFile(pagePath, []byte("<h1>Access denied</h1>\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := simpleblocklist.CreateConfig()
+	cfg.BlacklistPath = createBlacklistFile(t, "192.0.2.1\n")
+	cfg.DeniedResponseFile = pagePath
+	cfg.HTTPStatusCodeDeniedRequest = http.StatusUnavailableForLegalReasons
+
+	ctx := context.Background()
+	next := http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		rw.WriteHeader(http.StatusOK)
+	})
+
+	handler, err := simpleblocklist.New(ctx, next, cfg, "simpleblocklist")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	serve := func() *httptest.ResponseRecorder {
+		recorder := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, "http://localhost", nil)
+		req.RemoteAddr = "192.0.2.1:1234"
+		handler.ServeHTTP(recorder, req)
+		return recorder
+	}
+
+	recorder := serve()
+	if recorder.Code != http.StatusUnavailableForLegalReasons {
+		t.Errorf("got status code %d, want %d", recorder.Code, http.StatusUnavailableForLegalReasons)
+	}
+	if contentType := recorder.Header().Get("Content-Type"); !strings.HasPrefix(contentType, "text/html") {
+		t.Errorf("got Content-Type %q, want text/html", contentType)
+	}
+	if body := recorder.Body.String(); body != "<h1>Access denied</h1>\n" {
+		t.Errorf("got body %q, want the denied response file", body)
+	}
+
+	// The file is read again on reload, and kept if it can't be
+	if err := os.WriteFile(pagePath, []byte("<h1>Blocked</h1>\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := simpleblocklist.Reload(handler); err != nil {
+		t.Fatal(err)
+	}
+	if body := serve().Body.String(); body != "<h1>Blocked</h1>\n" {
+		t.Errorf("got body %q after reload, want the updated file", body)
+	}
+
+	if err := os.Remove(pagePath); err != nil {
+		t.Fatal(err)
+	}
+	if err := simpleblocklist.Reload(handler); err != nil {
+		t.Fatal(err)
+	}
+	if body := serve().Body.String(); body != "<h1>Blocked</h1>\n" {
+		t.Errorf("got body %q after a failed reload, want the current file", body)
+	}
+}
+
+func TestSimpleBlocklist_MissingDeniedResponseFile(t *testing.T) {
+	cfg := simpleblocklist.CreateConfig()
+	cfg.BlacklistPath = createBlacklistFile(t, "192.0.2.1\n")
+	cfg.DeniedResponseFile = filepath.Join(t.TempDir(), "missing.html")
+
+	next := http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {})
+
+	_, err := simpleblocklist.New(context.Background(), next, cfg, "simpleblocklist")
+	if err == nil || !strings.Contains(err.Error(), "failed to load denied response file") {
+		t.Fatalf("expected a missing denied response file error, got %v", err)
+	}
+}
+
 func TestSimpleBlocklist_CustomStatusCode(t *testing.T) {
 	// Create a temporary blacklist file
 	tmpfile, err := os.CreateTemp("", "blacklist")
@@ -209,6 +585,61 @@ func TestSimpleBlocklist_CustomStatusCode(t *testing.T) {
 	}
 }
 
+func TestSimpleBlocklist_DeniedStatusCodeValidation(t *testing.T) {
+	tests := []struct {
+		desc        string
+		statusCode  int
+		expectError bool
+	}{
+		{
+			desc:        "Unknown status code",
+			statusCode:  999,
+			expectError: true,
+		},
+		{
+			desc:        "Redirect status code",
+			statusCode:  302,
+			expectError: true,
+		},
+		{
+			desc:        "Success status code",
+			statusCode:  200,
+			expectError: true,
+		},
+		{
+			desc:        "Unavailable for legal reasons",
+			statusCode:  451,
+			expectError: false,
+		},
+		{
+			desc:        "Service unavailable",
+			statusCode:  503,
+			expectError: false,
+		},
+	}
+
+	blacklistPath := createBlacklistFile(t, "192.0.2.1\n")
+
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			cfg := simpleblocklist.CreateConfig()
+			cfg.BlacklistPath = blacklistPath
+			cfg.HTTPStatusCodeDeniedRequest = test.statusCode
+
+			ctx := context.Background()
+			next := http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {})
+
+			_, err := simpleblocklist.New(ctx, next, cfg, "simpleblocklist")
+			if test.expectError && err == nil {
+				t.Errorf("expected error for status code %d", test.statusCode)
+			}
+			if !test.expectError && err != nil {
+				t.Errorf("unexpected error for status code %d: %v", test.statusCode, err)
+			}
+		})
+	}
+}
+
 func TestSimpleBlocklist_InvalidBlacklistEntries(t *testing.T) {
 	// Create a temporary blacklist file
 	tmpfile, err := os.CreateTemp("", "blacklist")
@@ -220,12 +651,12 @@ func TestSimpleBlocklist_InvalidBlacklistEntries(t *testing.T) {
 	// Write test entries with some invalid ones
 	content := []byte(`# Valid entries
 192.0.2.1
-192.168.1.0/24
+198.51.100.0/24
 
 # Invalid entries that should be ignored
 invalid.ip.address
 256.256.256.256
-192.168.1.0/33
+198.51.100.0/33
 not-an-ip
 
 # Valid entry after invalid ones
@@ -263,7 +694,7 @@ not-an-ip
 		},
 		{
 			desc:           "IP in valid CIDR range",
-			ip:             "192.168.1.100",
+			ip:             "198.51.100.100",
 			expectedStatus: 403,
 		},
 		{
@@ -295,3 +726,2251 @@ not-an-ip
 		})
 	}
 }
+
+func TestSimpleBlocklist_ClientIPHeaders(t *testing.T) {
+	cfg := simpleblocklist.CreateConfig()
+	cfg.BlacklistPath = createBlacklistFile(t, "192.0.2.1\n")
+	cfg.ClientIPHeaders = []string{"CF-Connecting-IP"}
+
+	ctx := context.Background()
+	next := http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		rw.WriteHeader(http.StatusOK)
+	})
+
+	handler, err := simpleblocklist.New(ctx, next, cfg, "simpleblocklist")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		desc           string
+		header         string
+		expectedStatus int
+	}{
+		{
+			desc:           "Blacklisted IP in configured header",
+			header:         "CF-Connecting-IP",
+			expectedStatus: 403,
+		},
+		{
+			desc:           "Blacklisted IP in header that is not configured",
+			header:         "X-Forwarded-For",
+			expectedStatus: 200,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			recorder := httptest.NewRecorder()
+			req, err := http.NewRequestWithContext(ctx, http.MethodGet, "http://localhost", nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			req.Header.Set(test.header, "192.0.2.1")
+
+			handler.ServeHTTP(recorder, req)
+
+			if recorder.Code != test.expectedStatus {
+				t.Errorf("got status code %d, want %d", recorder.Code, test.expectedStatus)
+			}
+		})
+	}
+}
+
+func TestSimpleBlocklist_MaxForwardedForEntries(t *testing.T) {
+	var buf bytes.Buffer
+	defer simpleblocklist.SetLogOutput(&buf)()
+
+	cfg := simpleblocklist.CreateConfig()
+	cfg.BlacklistPath = createBlacklistFile(t, "192.0.2.1\n")
+
+	ctx := context.Background()
+	next := http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		rw.WriteHeader(http.StatusOK)
+	})
+
+	handler, err := simpleblocklist.New(ctx, next, cfg, "simpleblocklist")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	forwardedFor := func(n int) string {
+		ips := make([]string, n)
+		for i := range ips {
+			ips[i] = "203.0.113.1"
+		}
+		return strings.Join(ips, ", ")
+	}
+
+	tests := []struct {
+		desc           string
+		xForwardedFor  string
+		expectedStatus int
+	}{
+		{
+			desc:           "At the limit",
+			xForwardedFor:  forwardedFor(20),
+			expectedStatus: 200,
+		},
+		{
+			desc:           "Over the limit",
+			xForwardedFor:  forwardedFor(21),
+			expectedStatus: 403,
+		},
+		{
+			desc:           "Header flood",
+			xForwardedFor:  forwardedFor(50000),
+			expectedStatus: 403,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			recorder := httptest.NewRecorder()
+			req, err := http.NewRequestWithContext(ctx, http.MethodGet, "http://localhost", nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			req.Header.Set("X-Forwarded-For", test.xForwardedFor)
+
+			handler.ServeHTTP(recorder, req)
+
+			if recorder.Code != test.expectedStatus {
+				t.Errorf("got status code %d, want %d", recorder.Code, test.expectedStatus)
+			}
+		})
+	}
+
+	if !strings.Contains(buf.String(), "WARN: SimpleBlocklist: ") ||
+		!strings.Contains(buf.String(), "X-Forwarded-For header has more than 20 entries") {
+		t.Errorf("expected a warning about the oversized header, got %q", buf.String())
+	}
+}
+
+func TestSimpleBlocklist_BypassToken(t *testing.T) {
+	cfg := simpleblocklist.CreateConfig()
+	cfg.BlacklistPath = createBlacklistFile(t, "192.0.2.1\n")
+	cfg.BypassHeader = "X-Oncall-Token"
+	cfg.BypassToken = "s3cret"
+
+	ctx := context.Background()
+	var forwardedToken string
+	next := http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		forwardedToken = req.Header.Get("X-Oncall-Token")
+		rw.WriteHeader(http.StatusOK)
+	})
+
+	handler, err := simpleblocklist.New(ctx, next, cfg, "simpleblocklist")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		desc           string
+		token          string
+		expectedStatus int
+	}{
+		{
+			desc:           "Correct token bypasses the blacklist",
+			token:          "s3cret",
+			expectedStatus: 200,
+		},
+		{
+			desc:           "Wrong token is blocked",
+			token:          "s3cret!",
+			expectedStatus: 403,
+		},
+		{
+			desc:           "Missing token is blocked",
+			expectedStatus: 403,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			forwardedToken = ""
+
+			recorder := httptest.NewRecorder()
+			req, err := http.NewRequestWithContext(ctx, http.MethodGet, "http://localhost", nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			req.Header.Set("X-Forwarded-For", "192.0.2.1")
+			if test.token != "" {
+				req.Header.Set("X-Oncall-Token", test.token)
+			}
+
+			handler.ServeHTTP(recorder, req)
+
+			if recorder.Code != test.expectedStatus {
+				t.Errorf("got status code %d, want %d", recorder.Code, test.expectedStatus)
+			}
+			if forwardedToken != "" {
+				t.Errorf("bypass token %q was forwarded to the next handler", forwardedToken)
+			}
+		})
+	}
+}
+
+func TestSimpleBlocklist_IPEvaluationMode(t *testing.T) {
+	blacklistPath := createBlacklistFile(t, "192.0.2.1\n")
+
+	tests := []struct {
+		desc           string
+		mode           string
+		remoteAddr     string
+		xForwardedFor  string
+		expectedStatus int
+	}{
+		{
+			desc:           "all: blacklisted IP anywhere in the header",
+			mode:           "all",
+			remoteAddr:     "203.0.113.1:1234",
+			xForwardedFor:  "203.0.113.2, 192.0.2.1",
+			expectedStatus: 403,
+		},
+		{
+			desc:           "all: blacklisted RemoteAddr",
+			mode:           "all",
+			remoteAddr:     "192.0.2.1:1234",
+			xForwardedFor:  "203.0.113.2",
+			expectedStatus: 403,
+		},
+		{
+			desc:           "remote-only: blacklisted header IP is ignored",
+			mode:           "remote-only",
+			remoteAddr:     "203.0.113.1:1234",
+			xForwardedFor:  "192.0.2.1",
+			expectedStatus: 200,
+		},
+		{
+			desc:           "remote-only: blacklisted RemoteAddr",
+			mode:           "remote-only",
+			remoteAddr:     "192.0.2.1:1234",
+			xForwardedFor:  "203.0.113.2",
+			expectedStatus: 403,
+		},
+		{
+			desc:           "xff-first: blacklisted first entry",
+			mode:           "xff-first",
+			remoteAddr:     "203.0.113.1:1234",
+			xForwardedFor:  "192.0.2.1, 203.0.113.2",
+			expectedStatus: 403,
+		},
+		{
+			desc:           "xff-first: blacklisted later entry is ignored",
+			mode:           "xff-first",
+			remoteAddr:     "192.0.2.1:1234",
+			xForwardedFor:  "203.0.113.2, 192.0.2.1",
+			expectedStatus: 200,
+		},
+		{
+			desc:           "xff-last: blacklisted last entry",
+			mode:           "xff-last",
+			remoteAddr:     "203.0.113.1:1234",
+			xForwardedFor:  "203.0.113.2, 192.0.2.1",
+			expectedStatus: 403,
+		},
+		{
+			desc:           "xff-last: blacklisted earlier entry is ignored",
+			mode:           "xff-last",
+			remoteAddr:     "192.0.2.1:1234",
+			xForwardedFor:  "192.0.2.1, 203.0.113.2",
+			expectedStatus: 200,
+		},
+		{
+			desc:           "xff-last: falls back to RemoteAddr without header",
+			mode:           "xff-last",
+			remoteAddr:     "192.0.2.1:1234",
+			expectedStatus: 403,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			cfg := simpleblocklist.CreateConfig()
+			cfg.BlacklistPath = blacklistPath
+			cfg.IPEvaluationMode = test.mode
+
+			ctx := context.Background()
+			next := http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+				rw.WriteHeader(http.StatusOK)
+			})
+
+			handler, err := simpleblocklist.New(ctx, next, cfg, "simpleblocklist")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			recorder := httptest.NewRecorder()
+			req, err := http.NewRequestWithContext(ctx, http.MethodGet, "http://localhost", nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			req.RemoteAddr = test.remoteAddr
+			if test.xForwardedFor != "" {
+				req.Header.Set("X-Forwarded-For", test.xForwardedFor)
+			}
+
+			handler.ServeHTTP(recorder, req)
+
+			if recorder.Code != test.expectedStatus {
+				t.Errorf("got status code %d, want %d", recorder.Code, test.expectedStatus)
+			}
+		})
+	}
+}
+
+func TestSimpleBlocklist_IgnoreProxyHeaders(t *testing.T) {
+	blacklistPath := createBlacklistFile(t, "192.0.2.1\n")
+
+	tests := []struct {
+		desc           string
+		remoteAddr     string
+		xForwardedFor  string
+		xRealIP        string
+		expectedStatus int
+	}{
+		{
+			desc:           "benign headers don't hide a blacklisted RemoteAddr",
+			remoteAddr:     "192.0.2.1:1234",
+			xForwardedFor:  "203.0.113.2",
+			xRealIP:        "203.0.113.2",
+			expectedStatus: 403,
+		},
+		{
+			desc:           "spoofed headers don't block a benign RemoteAddr",
+			remoteAddr:     "203.0.113.1:1234",
+			xForwardedFor:  "192.0.2.1",
+			xRealIP:        "192.0.2.1",
+			expectedStatus: 200,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			cfg := simpleblocklist.CreateConfig()
+			cfg.BlacklistPath = blacklistPath
+			cfg.IgnoreProxyHeaders = true
+
+			ctx := context.Background()
+			next := http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+				rw.WriteHeader(http.StatusOK)
+			})
+
+			handler, err := simpleblocklist.New(ctx, next, cfg, "simpleblocklist")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			recorder := httptest.NewRecorder()
+			req, err := http.NewRequestWithContext(ctx, http.MethodGet, "http://localhost", nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			req.RemoteAddr = test.remoteAddr
+			req.Header.Set("X-Forwarded-For", test.xForwardedFor)
+			req.Header.Set("X-Real-IP", test.xRealIP)
+
+			handler.ServeHTTP(recorder, req)
+
+			if recorder.Code != test.expectedStatus {
+				t.Errorf("got status code %d, want %d", recorder.Code, test.expectedStatus)
+			}
+		})
+	}
+}
+
+func TestSimpleBlocklist_IgnoreProxyHeadersConflict(t *testing.T) {
+	cfg := simpleblocklist.CreateConfig()
+	cfg.BlacklistPath = createBlacklistFile(t, "192.0.2.1\n")
+	cfg.IgnoreProxyHeaders = true
+	cfg.IPEvaluationMode = "xff-first"
+
+	next := http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {})
+
+	if _, err := simpleblocklist.New(context.Background(), next, cfg, "simpleblocklist"); err == nil {
+		t.Error("expected error when ignoring proxy headers with an XFF evaluation mode")
+	}
+}
+
+func TestSimpleBlocklist_IPPrecedence(t *testing.T) {
+	blacklistPath := createBlacklistFile(t, "192.0.2.1\n")
+
+	tests := []struct {
+		desc           string
+		precedence     []string
+		remoteAddr     string
+		xRealIP        string
+		xForwardedFor  string
+		expectedStatus int
+	}{
+		{
+			desc:           "remoteaddr first: blacklisted header IPs are ignored",
+			precedence:     []string{"remoteaddr", "xrealip", "xff"},
+			remoteAddr:     "203.0.113.1:1234",
+			xRealIP:        "192.0.2.1",
+			xForwardedFor:  "192.0.2.1",
+			expectedStatus: 200,
+		},
+		{
+			desc:           "xff first: leftmost entry is evaluated",
+			precedence:     []string{"xff", "remoteaddr"},
+			remoteAddr:     "203.0.113.1:1234",
+			xForwardedFor:  "192.0.2.1, 203.0.113.2",
+			expectedStatus: 403,
+		},
+		{
+			desc:           "xff first: later entries are ignored",
+			precedence:     []string{"xff", "remoteaddr"},
+			remoteAddr:     "192.0.2.1:1234",
+			xForwardedFor:  "203.0.113.2, 192.0.2.1",
+			expectedStatus: 200,
+		},
+		{
+			desc:           "xff first: invalid entries are skipped",
+			precedence:     []string{"xff", "remoteaddr"},
+			remoteAddr:     "203.0.113.1:1234",
+			xForwardedFor:  "unknown, 192.0.2.1",
+			expectedStatus: 403,
+		},
+		{
+			desc:           "xrealip first: falls back to xff when missing",
+			precedence:     []string{"xrealip", "xff"},
+			remoteAddr:     "203.0.113.1:1234",
+			xForwardedFor:  "192.0.2.1",
+			expectedStatus: 403,
+		},
+		{
+			desc:           "xrealip first: xff is ignored when present",
+			precedence:     []string{"XRealIP", "xff"},
+			remoteAddr:     "203.0.113.1:1234",
+			xRealIP:        "203.0.113.3",
+			xForwardedFor:  "192.0.2.1",
+			expectedStatus: 200,
+		},
+		{
+			desc:           "unlisted RemoteAddr is never used",
+			precedence:     []string{"xff"},
+			remoteAddr:     "192.0.2.1:1234",
+			expectedStatus: 200,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			cfg := simpleblocklist.CreateConfig()
+			cfg.BlacklistPath = blacklistPath
+			cfg.IPPrecedence = test.precedence
+
+			ctx := context.Background()
+			next := http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+				rw.WriteHeader(http.StatusOK)
+			})
+
+			handler, err := simpleblocklist.New(ctx, next, cfg, "simpleblocklist")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			recorder := httptest.NewRecorder()
+			req, err := http.NewRequestWithContext(ctx, http.MethodGet, "http://localhost", nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			req.RemoteAddr = test.remoteAddr
+			if test.xRealIP != "" {
+				req.Header.Set("X-Real-IP", test.xRealIP)
+			}
+			if test.xForwardedFor != "" {
+				req.Header.Set("X-Forwarded-For", test.xForwardedFor)
+			}
+
+			handler.ServeHTTP(recorder, req)
+
+			if recorder.Code != test.expectedStatus {
+				t.Errorf("got status code %d, want %d", recorder.Code, test.expectedStatus)
+			}
+		})
+	}
+}
+
+func TestSimpleBlocklist_InvalidIPPrecedence(t *testing.T) {
+	tests := []struct {
+		desc       string
+		precedence []string
+		mode       string
+	}{
+		{desc: "unknown source", precedence: []string{"remoteaddr", "cf-connecting-ip"}},
+		{desc: "duplicate source", precedence: []string{"xff", "remoteaddr", "xff"}},
+		{desc: "combined with an evaluation mode", precedence: []string{"xff"}, mode: "xff-last"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			cfg := simpleblocklist.CreateConfig()
+			cfg.BlacklistPath = createBlacklistFile(t, "192.0.2.1\n")
+			cfg.IPPrecedence = test.precedence
+			cfg.IPEvaluationMode = test.mode
+
+			next := http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {})
+
+			if _, err := simpleblocklist.New(context.Background(), next, cfg, "simpleblocklist"); err == nil {
+				t.Error("expected error for an invalid IP precedence")
+			}
+		})
+	}
+}
+
+func TestSimpleBlocklist_BlockedMethodsForListedIPs(t *testing.T) {
+	cfg := simpleblocklist.CreateConfig()
+	cfg.BlacklistPath = createBlacklistFile(t, "192.0.2.1\n")
+	cfg.BlockedMethodsForListedIPs = []string{"post", "PUT", "DELETE"}
+
+	ctx := context.Background()
+	next := http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		rw.WriteHeader(http.StatusOK)
+	})
+
+	handler, err := simpleblocklist.New(ctx, next, cfg, "simpleblocklist")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		desc           string
+		method         string
+		ip             string
+		expectedStatus int
+	}{
+		{desc: "blacklisted GET", method: http.MethodGet, ip: "192.0.2.1", expectedStatus: 200},
+		{desc: "blacklisted HEAD", method: http.MethodHead, ip: "192.0.2.1", expectedStatus: 200},
+		{desc: "blacklisted POST", method: http.MethodPost, ip: "192.0.2.1", expectedStatus: 403},
+		{desc: "blacklisted DELETE", method: http.MethodDelete, ip: "192.0.2.1", expectedStatus: 403},
+		{desc: "clean POST", method: http.MethodPost, ip: "203.0.113.1", expectedStatus: 200},
+	}
+
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			recorder := httptest.NewRecorder()
+			req, err := http.NewRequestWithContext(ctx, test.method, "http://localhost", nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			req.Header.Set("X-Forwarded-For", test.ip)
+
+			handler.ServeHTTP(recorder, req)
+
+			if recorder.Code != test.expectedStatus {
+				t.Errorf("got status code %d, want %d", recorder.Code, test.expectedStatus)
+			}
+		})
+	}
+}
+
+func TestSimpleBlocklist_HeaderIPsWithPorts(t *testing.T) {
+	cfg := simpleblocklist.CreateConfig()
+	cfg.BlacklistPath = createBlacklistFile(t, "192.0.2.1\n2001:db8::1\n")
+
+	ctx := context.Background()
+	next := http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		rw.WriteHeader(http.StatusOK)
+	})
+
+	handler, err := simpleblocklist.New(ctx, next, cfg, "simpleblocklist")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		desc           string
+		header         string
+		value          string
+		expectedStatus int
+	}{
+		{desc: "IPv4 with port", header: "X-Forwarded-For", value: "192.0.2.1:443", expectedStatus: 403},
+		{desc: "bracketed IPv6 with port", header: "X-Forwarded-For", value: "203.0.113.1, [2001:db8::1]:8080", expectedStatus: 403},
+		{desc: "bracketed IPv6 without port", header: "X-Real-IP", value: "[2001:db8::1]", expectedStatus: 403},
+		{desc: "bare IPv6", header: "X-Forwarded-For", value: "2001:db8::1", expectedStatus: 403},
+		{desc: "clean IPv4 with port", header: "X-Real-IP", value: "203.0.113.1:443", expectedStatus: 200},
+	}
+
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			recorder := httptest.NewRecorder()
+			req, err := http.NewRequestWithContext(ctx, http.MethodGet, "http://localhost", nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			req.RemoteAddr = "203.0.113.9:1234"
+			req.Header.Set(test.header, test.value)
+
+			handler.ServeHTTP(recorder, req)
+
+			if recorder.Code != test.expectedStatus {
+				t.Errorf("got status code %d, want %d", recorder.Code, test.expectedStatus)
+			}
+		})
+	}
+}
+
+func TestSimpleBlocklist_IPv6Zone(t *testing.T) {
+	tests := []struct {
+		desc               string
+		remoteAddr         string
+		forwardedFor       string
+		allowLocalRequests bool
+		expectedStatus     int
+		expectedReason     string
+	}{
+		{
+			desc:               "zoned link-local header IP allowed as local",
+			remoteAddr:         "192.0.2.1:1234",
+			forwardedFor:       "fe80::1%eth0",
+			allowLocalRequests: true,
+			expectedStatus:     200,
+		},
+		{
+			desc:           "zoned link-local header IP denied as local",
+			remoteAddr:     "203.0.113.1:1234",
+			forwardedFor:   "[fe80::1%eth0]:8080",
+			expectedStatus: 403,
+			expectedReason: "local-denied",
+		},
+		{
+			desc:               "zoned link-local connection allowed as local",
+			remoteAddr:         "[fe80::1%eth0]:1234",
+			allowLocalRequests: true,
+			expectedStatus:     200,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			cfg := simpleblocklist.CreateConfig()
+			cfg.BlacklistPath = createBlacklistFile(t, "192.0.2.1\n")
+			cfg.AllowLocalRequests = test.allowLocalRequests
+			cfg.DebugHeaders = true
+
+			ctx := context.Background()
+			next := http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+				rw.WriteHeader(http.StatusOK)
+			})
+
+			handler, err := simpleblocklist.New(ctx, next, cfg, "simpleblocklist")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			recorder := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodGet, "http://localhost", nil)
+			req.RemoteAddr = test.remoteAddr
+			if test.forwardedFor != "" {
+				req.Header.Set("X-Forwarded-For", test.forwardedFor)
+			}
+
+			handler.ServeHTTP(recorder, req)
+
+			if recorder.Code != test.expectedStatus {
+				t.Errorf("got status code %d, want %d", recorder.Code, test.expectedStatus)
+			}
+			if reason := recorder.Header().Get("X-Blocked-Reason"); reason != test.expectedReason {
+				t.Errorf("got blocked reason %q, want %q", reason, test.expectedReason)
+			}
+		})
+	}
+}
+
+func TestSimpleBlocklist_IPv4MappedIPv6(t *testing.T) {
+	cfg := simpleblocklist.CreateConfig()
+	cfg.BlacklistPath = createBlacklistFile(t, "192.0.2.1\n::ffff:198.51.100.0/120\n")
+	cfg.AllowLocalRequests = false
+
+	ctx := context.Background()
+	next := http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		rw.WriteHeader(http.StatusOK)
+	})
+
+	handler, err := simpleblocklist.New(ctx, next, cfg, "simpleblocklist")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		desc           string
+		remoteAddr     string
+		xForwardedFor  string
+		expectedStatus int
+	}{
+		{
+			desc:           "Mapped address in header against IPv4 entry",
+			xForwardedFor:  "::ffff:192.0.2.1",
+			expectedStatus: 403,
+		},
+		{
+			desc:           "Mapped address in RemoteAddr against IPv4 entry",
+			remoteAddr:     "[::ffff:192.0.2.1]:1234",
+			expectedStatus: 403,
+		},
+		{
+			desc:           "IPv4 address against mapped network entry",
+			xForwardedFor:  "198.51.100.7",
+			expectedStatus: 403,
+		},
+		{
+			desc:           "Mapped local address is treated as local",
+			xForwardedFor:  "::ffff:10.0.0.1",
+			expectedStatus: 403,
+		},
+		{
+			desc:           "Mapped address not in blacklist",
+			xForwardedFor:  "::ffff:203.0.113.1",
+			expectedStatus: 200,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			recorder := httptest.NewRecorder()
+			req, err := http.NewRequestWithContext(ctx, http.MethodGet, "http://localhost", nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			req.RemoteAddr = test.remoteAddr
+			if test.xForwardedFor != "" {
+				req.Header.Set("X-Forwarded-For", test.xForwardedFor)
+			}
+
+			handler.ServeHTTP(recorder, req)
+
+			if recorder.Code != test.expectedStatus {
+				t.Errorf("got status code %d, want %d", recorder.Code, test.expectedStatus)
+			}
+		})
+	}
+}
+
+func TestSimpleBlocklist_Exclusions(t *testing.T) {
+	cfg := simpleblocklist.CreateConfig()
+	cfg.BlacklistPath = createBlacklistFile(t, "192.0.2.1\n")
+	cfg.ExcludedPaths = []string{"/healthz", "/metrics/*"}
+	cfg.ExcludedMethods = []string{"options"}
+
+	ctx := context.Background()
+	next := http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		rw.WriteHeader(http.StatusOK)
+	})
+
+	handler, err := simpleblocklist.New(ctx, next, cfg, "simpleblocklist")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		desc           string
+		method         string
+		path           string
+		expectedStatus int
+	}{
+		{
+			desc:           "Excluded exact path",
+			method:         http.MethodGet,
+			path:           "/healthz",
+			expectedStatus: 200,
+		},
+		{
+			desc:           "Excluded path prefix",
+			method:         http.MethodGet,
+			path:           "/metrics/app",
+			expectedStatus: 200,
+		},
+		{
+			desc:           "Exact path does not match as prefix",
+			method:         http.MethodGet,
+			path:           "/healthz/deep",
+			expectedStatus: 403,
+		},
+		{
+			desc:           "Excluded method",
+			method:         http.MethodOptions,
+			path:           "/",
+			expectedStatus: 200,
+		},
+		{
+			desc:           "Not excluded",
+			method:         http.MethodGet,
+			path:           "/",
+			expectedStatus: 403,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			recorder := httptest.NewRecorder()
+			req, err := http.NewRequestWithContext(ctx, test.method, "http://localhost"+test.path, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			req.Header.Set("X-Forwarded-For", "192.0.2.1")
+
+			handler.ServeHTTP(recorder, req)
+
+			if recorder.Code != test.expectedStatus {
+				t.Errorf("got status code %d, want %d", recorder.Code, test.expectedStatus)
+			}
+		})
+	}
+}
+
+func TestSimpleBlocklist_DryRun(t *testing.T) {
+	var buf bytes.Buffer
+	defer simpleblocklist.SetLogOutput(&buf)()
+
+	cfg := simpleblocklist.CreateConfig()
+	cfg.BlacklistPath = createBlacklistFile(t, "198.51.100.0/24\n")
+	cfg.DryRun = true
+
+	ctx := context.Background()
+	next := http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		rw.WriteHeader(http.StatusOK)
+	})
+
+	handler, err := simpleblocklist.New(ctx, next, cfg, "simpleblocklist")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	recorder := httptest.NewRecorder()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, "http://localhost", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("X-Forwarded-For", "198.51.100.7")
+
+	handler.ServeHTTP(recorder, req)
+
+	if recorder.Code != 200 {
+		t.Errorf("got status code %d, want 200", recorder.Code)
+	}
+
+	want := "simpleblocklist: would block [198.51.100.7] matched [198.51.100.0/24]"
+	if !strings.Contains(buf.String(), want) {
+		t.Errorf("expected log line %q, got %q", want, buf.String())
+	}
+	if strings.Contains(buf.String(), "request denied") {
+		t.Errorf("unexpected denied log line in dry-run mode: %q", buf.String())
+	}
+}
+
+func TestSimpleBlocklist_Disabled(t *testing.T) {
+	var buf bytes.Buffer
+	defer simpleblocklist.SetLogOutput(&buf)()
+
+	cfg := simpleblocklist.CreateConfig()
+	cfg.BlacklistPath = createBlacklistFile(t, "198.51.100.0/24\n")
+	cfg.Enabled = false
+
+	ctx := context.Background()
+	next := http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		rw.WriteHeader(http.StatusOK)
+	})
+
+	handler, err := simpleblocklist.New(ctx, next, cfg, "simpleblocklist")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	recorder := httptest.NewRecorder()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, "http://localhost", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("X-Forwarded-For", "198.51.100.7")
+
+	handler.ServeHTTP(recorder, req)
+
+	if recorder.Code != 200 {
+		t.Errorf("got status code %d, want 200", recorder.Code)
+	}
+
+	// The list is still loaded, so it is validated before enforcement is turned back on
+	if want := "Loaded 1 IPs/Networks"; !strings.Contains(buf.String(), want) {
+		t.Errorf("expected log line %q, got %q", want, buf.String())
+	}
+	if blocked, _ := handler.(*simpleblocklist.SimpleBlocklist).IsBlocked(net.ParseIP("198.51.100.7")); !blocked {
+		t.Error("expected the list to be loaded while disabled")
+	}
+}
+
+func TestSimpleBlocklist_DeniedRedirect(t *testing.T) {
+	cfg := simpleblocklist.CreateConfig()
+	cfg.BlacklistPath = createBlacklistFile(t, "192.0.2.1\n")
+	cfg.DeniedRedirectURL = "https://example.com/blocked"
+
+	ctx := context.Background()
+	next := http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		rw.WriteHeader(http.StatusOK)
+	})
+
+	handler, err := simpleblocklist.New(ctx, next, cfg, "simpleblocklist")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		desc             string
+		ip               string
+		expectedStatus   int
+		expectedLocation string
+	}{
+		{
+			desc:             "Blacklisted IP is redirected",
+			ip:               "192.0.2.1",
+			expectedStatus:   302,
+			expectedLocation: "https://example.com/blocked",
+		},
+		{
+			desc:           "Local IP is not redirected",
+			ip:             "127.0.0.1",
+			expectedStatus: 200,
+		},
+		{
+			desc:           "Non-blacklisted IP is not redirected",
+			ip:             "192.0.2.2",
+			expectedStatus: 200,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			recorder := httptest.NewRecorder()
+			req, err := http.NewRequestWithContext(ctx, http.MethodGet, "http://localhost", nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			req.Header.Set("X-Forwarded-For", test.ip)
+
+			handler.ServeHTTP(recorder, req)
+
+			if recorder.Code != test.expectedStatus {
+				t.Errorf("got status code %d, want %d", recorder.Code, test.expectedStatus)
+			}
+			if location := recorder.Header().Get("Location"); location != test.expectedLocation {
+				t.Errorf("got Location %q, want %q", location, test.expectedLocation)
+			}
+		})
+	}
+}
+
+func TestSimpleBlocklist_InvalidDeniedRedirect(t *testing.T) {
+	tests := []struct {
+		desc       string
+		url        string
+		statusCode int
+	}{
+		{
+			desc:       "Unparseable URL",
+			url:        "http://exa mple.com/%zz",
+			statusCode: 302,
+		},
+		{
+			desc:       "Non-redirect status code",
+			url:        "https://example.com/blocked",
+			statusCode: 403,
+		},
+	}
+
+	blacklistPath := createBlacklistFile(t, "192.0.2.1\n")
+
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			cfg := simpleblocklist.CreateConfig()
+			cfg.BlacklistPath = blacklistPath
+			cfg.DeniedRedirectURL = test.url
+			cfg.DeniedRedirectStatusCode = test.statusCode
+
+			ctx := context.Background()
+			next := http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {})
+
+			if _, err := simpleblocklist.New(ctx, next, cfg, "simpleblocklist"); err == nil {
+				t.Error("expected error for an invalid denied redirect")
+			}
+		})
+	}
+}
+
+func TestSimpleBlocklist_DebugHeaders(t *testing.T) {
+	blacklistPath := createBlacklistFile(t, "192.0.2.1\n198.51.100.0/24\n")
+
+	tests := []struct {
+		desc           string
+		debugHeaders   bool
+		allowLocal     bool
+		ip             string
+		expectedStatus int
+		expectedReason string
+	}{
+		{
+			desc:           "Blacklisted network",
+			debugHeaders:   true,
+			ip:             "198.51.100.7",
+			expectedStatus: 403,
+			expectedReason: "blacklist:198.51.100.0/24",
+		},
+		{
+			desc:           "Blacklisted IP",
+			debugHeaders:   true,
+			ip:             "192.0.2.1",
+			expectedStatus: 403,
+			expectedReason: "blacklist:192.0.2.1/32",
+		},
+		{
+			desc:           "Local IP denied",
+			debugHeaders:   true,
+			ip:             "10.0.0.1",
+			expectedStatus: 403,
+			expectedReason: "local-denied",
+		},
+		{
+			desc:           "Allowed IP",
+			debugHeaders:   true,
+			ip:             "203.0.113.1",
+			expectedStatus: 200,
+		},
+		{
+			desc:           "Allowed local IP",
+			debugHeaders:   true,
+			allowLocal:     true,
+			ip:             "10.0.0.1",
+			expectedStatus: 200,
+		},
+		{
+			desc:           "Debug headers disabled",
+			debugHeaders:   false,
+			ip:             "192.0.2.1",
+			expectedStatus: 403,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			cfg := simpleblocklist.CreateConfig()
+			cfg.BlacklistPath = blacklistPath
+			cfg.AllowLocalRequests = test.allowLocal
+			cfg.DebugHeaders = test.debugHeaders
+
+			ctx := context.Background()
+			next := http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+				rw.WriteHeader(http.StatusOK)
+			})
+
+			handler, err := simpleblocklist.New(ctx, next, cfg, "simpleblocklist")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			recorder := httptest.NewRecorder()
+			req, err := http.NewRequestWithContext(ctx, http.MethodGet, "http://localhost", nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			req.Header.Set("X-Forwarded-For", test.ip)
+
+			handler.ServeHTTP(recorder, req)
+
+			if recorder.Code != test.expectedStatus {
+				t.Errorf("got status code %d, want %d", recorder.Code, test.expectedStatus)
+			}
+			reason, ok := recorder.Header()["X-Blocked-Reason"]
+			if test.expectedReason == "" {
+				if ok {
+					t.Errorf("unexpected X-Blocked-Reason header %q", reason)
+				}
+			} else if recorder.Header().Get("X-Blocked-Reason") != test.expectedReason {
+				t.Errorf("got X-Blocked-Reason %q, want %q", reason, test.expectedReason)
+			}
+		})
+	}
+}
+
+func TestSimpleBlocklist_ReloadInterval(t *testing.T) {
+	blacklistPath := createBlacklistFile(t, "192.0.2.1\n")
+
+	cfg := simpleblocklist.CreateConfig()
+	cfg.BlacklistPath = blacklistPath
+	cfg.ReloadIntervalSeconds = 1
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	next := http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {})
+
+	handler, err := simpleblocklist.New(ctx, next, cfg, "simpleblocklist")
+	if err != nil {
+		t.Fatal(err)
+	}
+	blocklist := handler.(*simpleblocklist.SimpleBlocklist)
+
+	if err := os.WriteFile(blacklistPath, []byte("198.51.100.1\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		blocked, _ := blocklist.IsBlocked(net.ParseIP("198.51.100.1"))
+		stale, _ := blocklist.IsBlocked(net.ParseIP("192.0.2.1"))
+		if blocked && !stale {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("the updated blacklist file was not picked up by the periodic reload")
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+}
+
+func TestSimpleBlocklist_ReloadStopsWithContext(t *testing.T) {
+	cfg := simpleblocklist.CreateConfig()
+	cfg.BlacklistPath = createBlacklistFile(t, "192.0.2.1\n")
+	cfg.ReloadIntervalSeconds = 60
+
+	ctx, cancel := context.WithCancel(context.Background())
+	next := http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {})
+
+	handler, err := simpleblocklist.New(ctx, next, cfg, "simpleblocklist")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case <-simpleblocklist.ReloadDone(handler):
+		t.Fatal("reload goroutine stopped before the context was canceled")
+	default:
+	}
+
+	cancel()
+
+	select {
+	case <-simpleblocklist.ReloadDone(handler):
+	case <-time.After(time.Second):
+		t.Fatal("reload goroutine did not stop after the context was canceled")
+	}
+}
+
+func TestSimpleBlocklist_LocalDeniedStatusCode(t *testing.T) {
+	cfg := simpleblocklist.CreateConfig()
+	cfg.BlacklistPath = createBlacklistFile(t, "192.0.2.1\n")
+	cfg.AllowLocalRequests = false
+	cfg.LocalDeniedStatusCode = http.StatusUnauthorized
+
+	ctx := context.Background()
+	next := http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		rw.WriteHeader(http.StatusOK)
+	})
+
+	handler, err := simpleblocklist.New(ctx, next, cfg, "simpleblocklist")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		desc           string
+		remoteAddr     string
+		expectedStatus int
+	}{
+		{desc: "local IP", remoteAddr: "10.0.0.1:1234", expectedStatus: http.StatusUnauthorized},
+		{desc: "blacklisted IP", remoteAddr: "192.0.2.1:1234", expectedStatus: http.StatusForbidden},
+		{desc: "allowed IP", remoteAddr: "203.0.113.10:1234", expectedStatus: http.StatusOK},
+	}
+
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			recorder := httptest.NewRecorder()
+			req, err := http.NewRequestWithContext(ctx, http.MethodGet, "http://localhost", nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			req.RemoteAddr = test.remoteAddr
+
+			handler.ServeHTTP(recorder, req)
+
+			if recorder.Code != test.expectedStatus {
+				t.Errorf("got status code %d, want %d", recorder.Code, test.expectedStatus)
+			}
+		})
+	}
+}
+
+func TestSimpleBlocklist_RetryAfter(t *testing.T) {
+	tests := []struct {
+		desc               string
+		statusCode         int
+		expectedRetryAfter string
+	}{
+		{desc: "too many requests", statusCode: 429, expectedRetryAfter: "120"},
+		{desc: "service unavailable", statusCode: 503, expectedRetryAfter: "120"},
+		{desc: "forbidden", statusCode: 403, expectedRetryAfter: ""},
+	}
+
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			cfg := simpleblocklist.CreateConfig()
+			cfg.BlacklistPath = createBlacklistFile(t, "192.0.2.1\n")
+			cfg.HTTPStatusCodeDeniedRequest = test.statusCode
+			cfg.RetryAfterSeconds = 120
+
+			ctx := context.Background()
+			next := http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+				rw.WriteHeader(http.StatusOK)
+			})
+
+			handler, err := simpleblocklist.New(ctx, next, cfg, "simpleblocklist")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			recorder := httptest.NewRecorder()
+			req, err := http.NewRequestWithContext(ctx, http.MethodGet, "http://localhost", nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			req.Header.Set("X-Forwarded-For", "192.0.2.1")
+
+			handler.ServeHTTP(recorder, req)
+
+			if recorder.Code != test.statusCode {
+				t.Errorf("got status code %d, want %d", recorder.Code, test.statusCode)
+			}
+			if retryAfter := recorder.Header().Get("Retry-After"); retryAfter != test.expectedRetryAfter {
+				t.Errorf("got Retry-After %q, want %q", retryAfter, test.expectedRetryAfter)
+			}
+		})
+	}
+}
+
+func TestSimpleBlocklist_DefaultActionOnNoIP(t *testing.T) {
+	tests := []struct {
+		desc           string
+		action         string
+		remoteAddr     string
+		xForwardedFor  string
+		expectedStatus int
+	}{
+		{desc: "allow without IP", action: "allow", remoteAddr: "", expectedStatus: 200},
+		{desc: "deny without IP", action: "deny", remoteAddr: "", expectedStatus: 403},
+		{desc: "deny with unparseable IPs", action: "deny", remoteAddr: "not-an-ip", xForwardedFor: "unknown", expectedStatus: 403},
+		{desc: "deny with valid IP", action: "deny", remoteAddr: "203.0.113.10:1234", expectedStatus: 200},
+		{desc: "default without IP", action: "", remoteAddr: "", expectedStatus: 200},
+	}
+
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			cfg := simpleblocklist.CreateConfig()
+			cfg.BlacklistPath = createBlacklistFile(t, "192.0.2.1\n")
+			cfg.DefaultActionOnNoIP = test.action
+
+			ctx := context.Background()
+			next := http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+				rw.WriteHeader(http.StatusOK)
+			})
+
+			handler, err := simpleblocklist.New(ctx, next, cfg, "simpleblocklist")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			recorder := httptest.NewRecorder()
+			req, err := http.NewRequestWithContext(ctx, http.MethodGet, "http://localhost", nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			req.RemoteAddr = test.remoteAddr
+			if test.xForwardedFor != "" {
+				req.Header.Set("X-Forwarded-For", test.xForwardedFor)
+			}
+
+			handler.ServeHTTP(recorder, req)
+
+			if recorder.Code != test.expectedStatus {
+				t.Errorf("got status code %d, want %d", recorder.Code, test.expectedStatus)
+			}
+		})
+	}
+}
+
+func TestSimpleBlocklist_InvalidDefaultActionOnNoIP(t *testing.T) {
+	cfg := simpleblocklist.CreateConfig()
+	cfg.BlacklistPath = createBlacklistFile(t, "192.0.2.1\n")
+	cfg.DefaultActionOnNoIP = "block"
+
+	next := http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {})
+
+	if _, err := simpleblocklist.New(context.Background(), next, cfg, "simpleblocklist"); err == nil {
+		t.Error("expected error for an invalid default action")
+	}
+}
+
+func TestSimpleBlocklist_LocalIPRanges(t *testing.T) {
+	tests := []struct {
+		desc               string
+		localIPRanges      []string
+		allowLocalRequests bool
+		treatCGNATAsPublic bool
+		remoteAddr         string
+		expectedStatus     int
+		expectedReason     string
+	}{
+		{
+			desc:               "CGNAT address is blacklisted by default",
+			allowLocalRequests: true,
+			expectedStatus:     403,
+			expectedReason:     "blacklist:100.64.0.0/10",
+		},
+		{
+			desc:               "CGNAT address allowed as local",
+			localIPRanges:      []string{"100.64.0.0/10"},
+			allowLocalRequests: true,
+			expectedStatus:     200,
+		},
+		{
+			desc:               "CGNAT address denied as local",
+			localIPRanges:      []string{"100.64.0.0/10"},
+			allowLocalRequests: false,
+			expectedStatus:     403,
+			expectedReason:     "local-denied",
+		},
+		{
+			desc:               "CGNAT address treated as public despite a local range",
+			localIPRanges:      []string{"100.0.0.0/8"},
+			allowLocalRequests: true,
+			treatCGNATAsPublic: true,
+			expectedStatus:     403,
+			expectedReason:     "blacklist:100.64.0.0/10",
+		},
+		{
+			desc:               "RFC1918 address stays local when CGNAT is public",
+			localIPRanges:      []string{"100.0.0.0/8"},
+			allowLocalRequests: true,
+			treatCGNATAsPublic: true,
+			remoteAddr:         "10.0.0.1:1234",
+			expectedStatus:     200,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			cfg := simpleblocklist.CreateConfig()
+			cfg.BlacklistPath = createBlacklistFile(t, "100.64.0.0/10\n10.0.0.0/8\n")
+			cfg.LocalIPRanges = test.localIPRanges
+			cfg.AllowLocalRequests = test.allowLocalRequests
+			cfg.TreatCGNATAsPublic = test.treatCGNATAsPublic
+			cfg.DebugHeaders = true
+
+			ctx := context.Background()
+			next := http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+				rw.WriteHeader(http.StatusOK)
+			})
+
+			handler, err := simpleblocklist.New(ctx, next, cfg, "simpleblocklist")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			recorder := httptest.NewRecorder()
+			req, err := http.NewRequestWithContext(ctx, http.MethodGet, "http://localhost", nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			req.RemoteAddr = "100.64.1.1:1234"
+			if test.remoteAddr != "" {
+				req.RemoteAddr = test.remoteAddr
+			}
+
+			handler.ServeHTTP(recorder, req)
+
+			if recorder.Code != test.expectedStatus {
+				t.Errorf("got status code %d, want %d", recorder.Code, test.expectedStatus)
+			}
+			if reason := recorder.Header().Get("X-Blocked-Reason"); reason != test.expectedReason {
+				t.Errorf("got blocked reason %q, want %q", reason, test.expectedReason)
+			}
+		})
+	}
+}
+
+func TestSimpleBlocklist_AllowLocalRequestsPaths(t *testing.T) {
+	cfg := simpleblocklist.CreateConfig()
+	cfg.BlacklistPath = createBlacklistFile(t, "10.0.0.0/8\n")
+	cfg.AllowLocalRequests = true
+	cfg.AllowLocalRequestsPaths = []string{"/admin"}
+
+	ctx := context.Background()
+	next := http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		rw.WriteHeader(http.StatusOK)
+	})
+
+	handler, err := simpleblocklist.New(ctx, next, cfg, "simpleblocklist")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		desc           string
+		path           string
+		remoteAddr     string
+		expectedStatus int
+	}{
+		{desc: "local IP on admin route", path: "/admin/users", remoteAddr: "10.0.0.1:1234", expectedStatus: 200},
+		{desc: "local IP on api route", path: "/api/users", remoteAddr: "10.0.0.1:1234", expectedStatus: 403},
+		{desc: "unlisted local IP on api route", path: "/api/users", remoteAddr: "192.168.1.1:1234", expectedStatus: 200},
+	}
+
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			recorder := httptest.NewRecorder()
+			req, err := http.NewRequestWithContext(ctx, http.MethodGet, "http://localhost"+test.path, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			req.RemoteAddr = test.remoteAddr
+
+			handler.ServeHTTP(recorder, req)
+
+			if recorder.Code != test.expectedStatus {
+				t.Errorf("got status code %d, want %d", recorder.Code, test.expectedStatus)
+			}
+		})
+	}
+}
+
+func TestSimpleBlocklist_StillCheckBlacklistForLocal(t *testing.T) {
+	blacklistPath := createBlacklistFile(t, "192.0.2.1\n")
+
+	tests := []struct {
+		desc           string
+		stillCheck     bool
+		xForwardedFor  string
+		expectedStatus int
+	}{
+		{
+			desc:           "spoofed private IP hides a blacklisted IP by default",
+			xForwardedFor:  "10.0.0.1, 192.0.2.1",
+			expectedStatus: 200,
+		},
+		{
+			desc:           "spoofed private IP with a blacklisted IP",
+			stillCheck:     true,
+			xForwardedFor:  "10.0.0.1, 192.0.2.1",
+			expectedStatus: 403,
+		},
+		{
+			desc:           "private IP with a clean public IP",
+			stillCheck:     true,
+			xForwardedFor:  "10.0.0.1, 203.0.113.1",
+			expectedStatus: 200,
+		},
+		{
+			desc:           "private IP only",
+			stillCheck:     true,
+			xForwardedFor:  "10.0.0.1",
+			expectedStatus: 200,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			cfg := simpleblocklist.CreateConfig()
+			cfg.BlacklistPath = blacklistPath
+			cfg.StillCheckBlacklistForLocal = test.stillCheck
+
+			ctx := context.Background()
+			next := http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+				rw.WriteHeader(http.StatusOK)
+			})
+
+			handler, err := simpleblocklist.New(ctx, next, cfg, "simpleblocklist")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			recorder := httptest.NewRecorder()
+			req, err := http.NewRequestWithContext(ctx, http.MethodGet, "http://localhost", nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			req.RemoteAddr = "10.0.0.2:1234"
+			req.Header.Set("X-Forwarded-For", test.xForwardedFor)
+
+			handler.ServeHTTP(recorder, req)
+
+			if recorder.Code != test.expectedStatus {
+				t.Errorf("got status code %d, want %d", recorder.Code, test.expectedStatus)
+			}
+		})
+	}
+}
+
+func TestSimpleBlocklist_EvaluationStrategy(t *testing.T) {
+	blacklistPath := createBlacklistFile(t, "192.0.2.1\n")
+
+	tests := []struct {
+		desc           string
+		strategy       string
+		xForwardedFor  string
+		remoteAddr     string
+		expectedStatus int
+	}{
+		{
+			desc:           "default allows on the first local IP",
+			xForwardedFor:  "10.0.0.1, 192.0.2.1",
+			remoteAddr:     "10.0.0.2:1234",
+			expectedStatus: 200,
+		},
+		{
+			desc:           "default denies on the first blacklisted IP",
+			xForwardedFor:  "192.0.2.1, 203.0.113.1",
+			remoteAddr:     "10.0.0.2:1234",
+			expectedStatus: 403,
+		},
+		{
+			desc:           "deny-if-any-blocked with a local IP first",
+			strategy:       "deny-if-any-blocked",
+			xForwardedFor:  "10.0.0.1, 192.0.2.1",
+			remoteAddr:     "10.0.0.2:1234",
+			expectedStatus: 403,
+		},
+		{
+			desc:           "deny-if-any-blocked with local and clean IPs",
+			strategy:       "deny-if-any-blocked",
+			xForwardedFor:  "10.0.0.1, 203.0.113.1",
+			remoteAddr:     "10.0.0.2:1234",
+			expectedStatus: 200,
+		},
+		{
+			desc:           "allow-if-any-local with a blacklisted IP first",
+			strategy:       "allow-if-any-local",
+			xForwardedFor:  "192.0.2.1, 203.0.113.1",
+			remoteAddr:     "10.0.0.2:1234",
+			expectedStatus: 200,
+		},
+		{
+			desc:           "allow-if-any-local without a local IP",
+			strategy:       "allow-if-any-local",
+			xForwardedFor:  "192.0.2.1",
+			remoteAddr:     "203.0.113.1:1234",
+			expectedStatus: 403,
+		},
+		{
+			desc:           "deny-if-client-blocked with a blacklisted client behind local hops",
+			strategy:       "deny-if-client-blocked",
+			xForwardedFor:  "10.0.0.1, 192.0.2.1",
+			remoteAddr:     "10.0.0.2:1234",
+			expectedStatus: 403,
+		},
+		{
+			desc:           "deny-if-client-blocked ignores IPs beyond the client",
+			strategy:       "deny-if-client-blocked",
+			xForwardedFor:  "192.0.2.1, 203.0.113.1",
+			remoteAddr:     "10.0.0.2:1234",
+			expectedStatus: 200,
+		},
+		{
+			desc:           "deny-if-client-blocked with local IPs only",
+			strategy:       "deny-if-client-blocked",
+			xForwardedFor:  "10.0.0.1",
+			remoteAddr:     "10.0.0.2:1234",
+			expectedStatus: 200,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			cfg := simpleblocklist.CreateConfig()
+			cfg.BlacklistPath = blacklistPath
+			cfg.EvaluationStrategy = test.strategy
+
+			ctx := context.Background()
+			next := http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+				rw.WriteHeader(http.StatusOK)
+			})
+
+			handler, err := simpleblocklist.New(ctx, next, cfg, "simpleblocklist")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			recorder := httptest.NewRecorder()
+			req, err := http.NewRequestWithContext(ctx, http.MethodGet, "http://localhost", nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			req.RemoteAddr = test.remoteAddr
+			req.Header.Set("X-Forwarded-For", test.xForwardedFor)
+
+			handler.ServeHTTP(recorder, req)
+
+			if recorder.Code != test.expectedStatus {
+				t.Errorf("got status code %d, want %d", recorder.Code, test.expectedStatus)
+			}
+		})
+	}
+}
+
+func TestSimpleBlocklist_BlocklistPrecedenceOverLocal(t *testing.T) {
+	blacklistPath := createBlacklistFile(t, "10.0.0.1\n")
+
+	tests := []struct {
+		desc           string
+		precedence     bool
+		remoteAddr     string
+		expectedStatus int
+	}{
+		{
+			desc:           "blacklisted private IP is allowed by default",
+			remoteAddr:     "10.0.0.1:1234",
+			expectedStatus: 200,
+		},
+		{
+			desc:           "blacklisted private IP is denied",
+			precedence:     true,
+			remoteAddr:     "10.0.0.1:1234",
+			expectedStatus: 403,
+		},
+		{
+			desc:           "other private IP is still allowed",
+			precedence:     true,
+			remoteAddr:     "10.0.0.2:1234",
+			expectedStatus: 200,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			cfg := simpleblocklist.CreateConfig()
+			cfg.BlacklistPath = blacklistPath
+			cfg.BlocklistPrecedenceOverLocal = test.precedence
+
+			ctx := context.Background()
+			next := http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+				rw.WriteHeader(http.StatusOK)
+			})
+
+			handler, err := simpleblocklist.New(ctx, next, cfg, "simpleblocklist")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			recorder := httptest.NewRecorder()
+			req, err := http.NewRequestWithContext(ctx, http.MethodGet, "http://localhost", nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			req.RemoteAddr = test.remoteAddr
+
+			handler.ServeHTTP(recorder, req)
+
+			if recorder.Code != test.expectedStatus {
+				t.Errorf("got status code %d, want %d", recorder.Code, test.expectedStatus)
+			}
+		})
+	}
+}
+
+func TestSimpleBlocklist_InvalidLocalIPRanges(t *testing.T) {
+	cfg := simpleblocklist.CreateConfig()
+	cfg.BlacklistPath = createBlacklistFile(t, "192.0.2.1\n")
+	cfg.LocalIPRanges = []string{"100.64.0.0/10", "not-a-cidr"}
+
+	next := http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {})
+
+	if _, err := simpleblocklist.New(context.Background(), next, cfg, "simpleblocklist"); err == nil {
+		t.Error("expected error for an invalid local IP range")
+	}
+}
+
+func TestSimpleBlocklist_IsBlocked(t *testing.T) {
+	cfg := simpleblocklist.CreateConfig()
+	cfg.BlacklistPath = createBlacklistFile(t, `192.0.2.1
+198.51.100.0/24
+!198.51.100.5
+2001:db8::1
+2001:db8:1::/48
+`)
+
+	next := http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {})
+
+	handler, err := simpleblocklist.New(context.Background(), next, cfg, "simpleblocklist")
+	if err != nil {
+		t.Fatal(err)
+	}
+	blocklist := handler.(*simpleblocklist.SimpleBlocklist)
+
+	tests := []struct {
+		desc            string
+		ip              string
+		expectedBlocked bool
+		expectedNetwork string
+	}{
+		{desc: "IPv4 address", ip: "192.0.2.1", expectedBlocked: true, expectedNetwork: "192.0.2.1/32"},
+		{desc: "IPv4 address in range", ip: "198.51.100.7", expectedBlocked: true, expectedNetwork: "198.51.100.0/24"},
+		{desc: "IPv4-mapped address in range", ip: "::ffff:198.51.100.7", expectedBlocked: true, expectedNetwork: "198.51.100.0/24"},
+		{desc: "IPv4 exception in range", ip: "198.51.100.5", expectedBlocked: false},
+		{desc: "IPv6 address", ip: "2001:db8::1", expectedBlocked: true, expectedNetwork: "2001:db8::1/128"},
+		{desc: "IPv6 address in range", ip: "2001:db8:1::42", expectedBlocked: true, expectedNetwork: "2001:db8:1::/48"},
+		{desc: "IPv4 address not listed", ip: "203.0.113.1", expectedBlocked: false},
+		{desc: "IPv6 address not listed", ip: "2001:db8:2::1", expectedBlocked: false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			blocked, network := blocklist.IsBlocked(net.ParseIP(test.ip))
+
+			if blocked != test.expectedBlocked {
+				t.Errorf("got blocked %t, want %t", blocked, test.expectedBlocked)
+			}
+
+			var matched string
+			if network != nil {
+				matched = network.String()
+			}
+			if matched != test.expectedNetwork {
+				t.Errorf("got network %q, want %q", matched, test.expectedNetwork)
+			}
+		})
+	}
+}
+
+func TestSimpleBlocklist_DefaultDeny(t *testing.T) {
+	tests := []struct {
+		desc               string
+		remoteAddr         string
+		allowLocalRequests bool
+		expectedStatus     int
+	}{
+		{desc: "whitelisted IP", remoteAddr: "198.51.100.7:1234", allowLocalRequests: true, expectedStatus: 200},
+		{desc: "whitelisted IP also blacklisted", remoteAddr: "198.51.100.1:1234", allowLocalRequests: true, expectedStatus: 200},
+		{desc: "non-listed public IP", remoteAddr: "203.0.113.10:1234", allowLocalRequests: true, expectedStatus: 403},
+		{desc: "local IP allowed", remoteAddr: "10.0.0.1:1234", allowLocalRequests: true, expectedStatus: 200},
+		{desc: "local IP denied", remoteAddr: "10.0.0.1:1234", allowLocalRequests: false, expectedStatus: 403},
+	}
+
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			cfg := simpleblocklist.CreateConfig()
+			cfg.BlacklistPath = createBlacklistFile(t, "198.51.100.1\n")
+			cfg.WhitelistPath = createBlacklistFile(t, "198.51.100.0/24\n")
+			cfg.DefaultDeny = true
+			cfg.AllowLocalRequests = test.allowLocalRequests
+
+			ctx := context.Background()
+			next := http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+				rw.WriteHeader(http.StatusOK)
+			})
+
+			handler, err := simpleblocklist.New(ctx, next, cfg, "simpleblocklist")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			recorder := httptest.NewRecorder()
+			req, err := http.NewRequestWithContext(ctx, http.MethodGet, "http://localhost", nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			req.RemoteAddr = test.remoteAddr
+
+			handler.ServeHTTP(recorder, req)
+
+			if recorder.Code != test.expectedStatus {
+				t.Errorf("got status code %d, want %d", recorder.Code, test.expectedStatus)
+			}
+		})
+	}
+}
+
+func TestSimpleBlocklist_ConflictResolution(t *testing.T) {
+	tests := []struct {
+		desc           string
+		resolution     string
+		remoteAddr     string
+		expectedStatus int
+	}{
+		{desc: "whitelist wins by default", remoteAddr: "198.51.100.7:1234", expectedStatus: 200},
+		{desc: "whitelist wins", resolution: "whitelist-wins", remoteAddr: "198.51.100.7:1234", expectedStatus: 200},
+		{desc: "blacklist wins", resolution: "blacklist-wins", remoteAddr: "198.51.100.7:1234", expectedStatus: 403},
+		{desc: "blacklisted only", resolution: "whitelist-wins", remoteAddr: "192.0.2.1:1234", expectedStatus: 403},
+		{desc: "whitelisted only", resolution: "blacklist-wins", remoteAddr: "203.0.113.1:1234", expectedStatus: 200},
+	}
+
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			var buf bytes.Buffer
+			defer simpleblocklist.SetLogOutput(&buf)()
+
+			cfg := simpleblocklist.CreateConfig()
+			cfg.BlacklistPath = createBlacklistFile(t, "198.51.100.0/24\n192.0.2.1\n")
+			cfg.WhitelistPath = createBlacklistFile(t, "198.51.100.7\n203.0.113.0/24\n")
+			cfg.ConflictResolution = test.resolution
+
+			ctx := context.Background()
+			next := http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+				rw.WriteHeader(http.StatusOK)
+			})
+
+			handler, err := simpleblocklist.New(ctx, next, cfg, "simpleblocklist")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			want := "Found 1 overlapping whitelist and blacklist entries"
+			if !strings.Contains(buf.String(), want) || !strings.Contains(buf.String(), "198.51.100.7/32 and 198.51.100.0/24") {
+				t.Errorf("expected a warning %q naming the conflict, got %q", want, buf.String())
+			}
+
+			recorder := httptest.NewRecorder()
+			req, err := http.NewRequestWithContext(ctx, http.MethodGet, "http://localhost", nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			req.RemoteAddr = test.remoteAddr
+
+			handler.ServeHTTP(recorder, req)
+
+			if recorder.Code != test.expectedStatus {
+				t.Errorf("got status code %d, want %d", recorder.Code, test.expectedStatus)
+			}
+		})
+	}
+}
+
+func TestSimpleBlocklist_WhitelistPrecedence(t *testing.T) {
+	cfg := simpleblocklist.CreateConfig()
+	cfg.BlacklistPath = createBlacklistFile(t, "198.51.0.0/16\n")
+	cfg.WhitelistPath = createBlacklistFile(t, "198.51.100.7\n203.0.113.7\n")
+	cfg.TieredLists = []simpleblocklist.TieredList{{Path: createBlacklistFile(t, "203.0.113.0/24\n")}}
+
+	ctx := context.Background()
+	next := http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		rw.WriteHeader(http.StatusOK)
+	})
+
+	handler, err := simpleblocklist.New(ctx, next, cfg, "simpleblocklist")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		desc           string
+		xForwardedFor  string
+		expectedStatus int
+	}{
+		{desc: "whitelisted partner in a blacklisted network", xForwardedFor: "198.51.100.7", expectedStatus: 200},
+		{desc: "other IP of the blacklisted network", xForwardedFor: "198.51.100.8", expectedStatus: 403},
+		{desc: "whitelisted IP skips the tiered lists", xForwardedFor: "203.0.113.7", expectedStatus: 200},
+		{desc: "whitelisted IP doesn't exempt the other IPs", xForwardedFor: "198.51.100.7, 198.51.100.8", expectedStatus: 403},
+	}
+
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			recorder := httptest.NewRecorder()
+			req, err := http.NewRequestWithContext(ctx, http.MethodGet, "http://localhost", nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			req.RemoteAddr = "192.0.2.10:1234"
+			req.Header.Set("X-Forwarded-For", test.xForwardedFor)
+
+			handler.ServeHTTP(recorder, req)
+
+			if recorder.Code != test.expectedStatus {
+				t.Errorf("got status code %d, want %d", recorder.Code, test.expectedStatus)
+			}
+		})
+	}
+}
+
+func TestSimpleBlocklist_WhitelistOptions(t *testing.T) {
+	cfg := simpleblocklist.CreateConfig()
+	cfg.BlacklistPath = createBlacklistFile(t, "create blocklist hash:net\nadd blocklist 198.51.100.0/24\n")
+	cfg.BlacklistFormat = "ipset"
+	cfg.DisabledTags = []string{"partners"}
+	cfg.MaxBlacklistEntries = 1
+	cfg.StrictParsing = true
+	// A plain whitelist larger than the blacklist limit, in a tagged group disabled for the blacklist
+	cfg.WhitelistPath = createBlacklistFile(t, "# tag:partners\n198.51.100.7\n198.51.100.8\n")
+
+	next := http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {})
+
+	handler, err := simpleblocklist.New(context.Background(), next, cfg, "simpleblocklist")
+	if err != nil {
+		t.Fatal(err)
+	}
+	blocklist := handler.(*simpleblocklist.SimpleBlocklist)
+
+	for ip, expected := range map[string]bool{"198.51.100.7": false, "198.51.100.8": false, "198.51.100.9": true} {
+		if blocked, _ := blocklist.IsBlocked(net.ParseIP(ip)); blocked != expected {
+			t.Errorf("%s: got blocked %t, want %t", ip, blocked, expected)
+		}
+	}
+
+	if err := simpleblocklist.Reload(handler); err != nil {
+		t.Fatalf("reload: %v", err)
+	}
+	if blocked, _ := blocklist.IsBlocked(net.ParseIP("198.51.100.7")); blocked {
+		t.Error("expected 198.51.100.7 to stay whitelisted after a reload")
+	}
+}
+
+func TestSimpleBlocklist_DefaultDenyConfig(t *testing.T) {
+	next := http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {})
+
+	cfg := simpleblocklist.CreateConfig()
+	cfg.DefaultDeny = true
+	if _, err := simpleblocklist.New(context.Background(), next, cfg, "simpleblocklist"); err == nil {
+		t.Error("expected error for default deny without a whitelist")
+	}
+
+	cfg.WhitelistPath = createBlacklistFile(t, "198.51.100.0/24\n")
+	if _, err := simpleblocklist.New(context.Background(), next, cfg, "simpleblocklist"); err != nil {
+		t.Errorf("expected default deny to work without a blacklist, got %v", err)
+	}
+}
+
+func createBlacklistFile(t *testing.T, content string) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "blacklist.txt")
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	return path
+}
+
+func TestSimpleBlocklist_ExportedReload(t *testing.T) {
+	blacklistPath := createBlacklistFile(t, "192.0.2.1\n")
+
+	cfg := simpleblocklist.CreateConfig()
+	cfg.BlacklistPath = blacklistPath
+
+	next := http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {})
+
+	handler, err := simpleblocklist.New(context.Background(), next, cfg, "simpleblocklist")
+	if err != nil {
+		t.Fatal(err)
+	}
+	blocklist := handler.(*simpleblocklist.SimpleBlocklist)
+
+	if err := os.WriteFile(blacklistPath, []byte("192.0.2.2\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := blocklist.Reload(); err != nil {
+		t.Fatal(err)
+	}
+
+	if blocked, _ := blocklist.IsBlocked(net.ParseIP("192.0.2.2")); !blocked {
+		t.Error("expected the added IP to be blocked after Reload")
+	}
+	if blocked, _ := blocklist.IsBlocked(net.ParseIP("192.0.2.1")); blocked {
+		t.Error("expected the removed IP to be allowed after Reload")
+	}
+}
+
+func TestSimpleBlocklist_ConcurrentReload(t *testing.T) {
+	// In both lists 192.0.2.1 is allowed, by an exception in the first one. A request seeing the
+	// blacklist of the first list without its exceptions would block it.
+	withException := createBlacklistFile(t, "192.0.2.0/24\n!192.0.2.1\n")
+	empty := createBlacklistFile(t, "# nothing blocked\n")
+
+	ctx := context.Background()
+	next := http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {})
+
+	newHandler := func(path string) http.Handler {
+		cfg := simpleblocklist.CreateConfig()
+		cfg.BlacklistPath = path
+		cfg.DecisionCacheSize = 16
+		handler, err := simpleblocklist.New(ctx, next, cfg, "simpleblocklist")
+		if err != nil {
+			t.Fatal(err)
+		}
+		return handler
+	}
+	handler := newHandler(withException)
+	sources := [][]simpleblocklist.BlocklistSource{
+		simpleblocklist.Sources(handler),
+		simpleblocklist.Sources(newHandler(empty)),
+	}
+	blocklist := handler.(*simpleblocklist.SimpleBlocklist)
+
+	done := make(chan struct{})
+	reloaded := make(chan error, 1)
+	go func() {
+		defer close(reloaded)
+		for i := 0; ; i++ {
+			select {
+			case <-done:
+				return
+			default:
+			}
+			simpleblocklist.SetSources(handler, sources[i%2]...)
+			if err := blocklist.Reload(); err != nil {
+				reloaded <- err
+				return
+			}
+		}
+	}()
+
+	var wg sync.WaitGroup
+	failures := make(chan string, 8)
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 2000; i++ {
+				if blocked, network := blocklist.IsBlocked(net.ParseIP("192.0.2.1")); blocked {
+					failures <- "192.0.2.1 blocked by " + network.String()
+					return
+				}
+				if blocked, network := blocklist.IsBlocked(net.ParseIP("192.0.2.2")); blocked && network.String() != "192.0.2.0/24" {
+					failures <- "192.0.2.2 blocked by " + network.String()
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	close(done)
+	close(failures)
+
+	if err := <-reloaded; err != nil {
+		t.Fatal(err)
+	}
+	for failure := range failures {
+		t.Errorf("inconsistent decision during reloads: %s", failure)
+	}
+}
+
+func TestSimpleBlocklist_NilNext(t *testing.T) {
+	tests := []struct {
+		desc           string
+		allowNilNext   bool
+		wantErr        bool
+		remoteAddr     string
+		expectedStatus int
+	}{
+		{
+			desc:    "rejected by default",
+			wantErr: true,
+		},
+		{
+			desc:           "allowed request",
+			allowNilNext:   true,
+			remoteAddr:     "203.0.113.1:1234",
+			expectedStatus: http.StatusBadGateway,
+		},
+		{
+			desc:           "blacklisted request",
+			allowNilNext:   true,
+			remoteAddr:     "192.0.2.1:1234",
+			expectedStatus: http.StatusForbidden,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			cfg := simpleblocklist.CreateConfig()
+			cfg.BlacklistPath = createBlacklistFile(t, "192.0.2.1\n")
+			cfg.AllowNilNext = test.allowNilNext
+
+			handler, err := simpleblocklist.New(context.Background(), nil, cfg, "simpleblocklist")
+			if test.wantErr {
+				if err == nil || !strings.Contains(err.Error(), "no next handler provided") {
+					t.Fatalf("expected a missing next handler error, got %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			recorder := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodGet, "http://localhost", nil)
+			req.RemoteAddr = test.remoteAddr
+
+			handler.ServeHTTP(recorder, req)
+
+			if recorder.Code != test.expectedStatus {
+				t.Errorf("got status code %d, want %d", recorder.Code, test.expectedStatus)
+			}
+		})
+	}
+}
+
+func TestSimpleBlocklist_ProxyProtocolHeader(t *testing.T) {
+	cfg := simpleblocklist.CreateConfig()
+	cfg.BlacklistPath = createBlacklistFile(t, "192.0.2.1\n198.51.100.1\n")
+	cfg.ProxyProtocolHeader = "X-Forwarded-Proxy-Client"
+	cfg.TrustedProxies = []string{"10.0.0.0/8", "203.0.113.0/24"}
+
+	ctx := context.Background()
+	next := http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		rw.WriteHeader(http.StatusOK)
+	})
+
+	handler, err := simpleblocklist.New(ctx, next, cfg, "simpleblocklist")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		desc           string
+		proxyClient    string
+		forwardedFor   string
+		remoteAddr     string
+		expectedStatus int
+	}{
+		{
+			desc:           "blacklisted proxy client",
+			proxyClient:    "192.0.2.1",
+			remoteAddr:     "10.0.0.2:1234",
+			expectedStatus: 403,
+		},
+		{
+			desc:           "blacklisted proxy client from a PROXY v1 line",
+			proxyClient:    "PROXY TCP4 192.0.2.1 10.0.0.2 56324 443",
+			remoteAddr:     "10.0.0.2:1234",
+			expectedStatus: 403,
+		},
+		{
+			desc:           "proxy client preferred over other sources",
+			proxyClient:    "[2001:db8::1]:56324",
+			forwardedFor:   "198.51.100.1",
+			remoteAddr:     "10.0.0.3:1234",
+			expectedStatus: 200,
+		},
+		{
+			desc:           "proxy client from an untrusted peer is ignored",
+			proxyClient:    "8.8.8.8",
+			remoteAddr:     "192.0.2.1:1234",
+			expectedStatus: 403,
+		},
+		{
+			desc:           "PROXY v1 line from an untrusted peer is ignored",
+			proxyClient:    "PROXY TCP4 8.8.8.8 10.0.0.2 56324 443",
+			forwardedFor:   "8.8.8.8",
+			remoteAddr:     "198.51.100.1:1234",
+			expectedStatus: 403,
+		},
+		{
+			desc:           "invalid proxy client falls back",
+			proxyClient:    "unknown",
+			forwardedFor:   "198.51.100.1",
+			remoteAddr:     "203.0.113.1:1234",
+			expectedStatus: 403,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			recorder := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodGet, "http://localhost", nil)
+			req.RemoteAddr = test.remoteAddr
+			req.Header.Set("X-Forwarded-Proxy-Client", test.proxyClient)
+			if test.forwardedFor != "" {
+				req.Header.Set("X-Forwarded-For", test.forwardedFor)
+			}
+
+			handler.ServeHTTP(recorder, req)
+
+			if recorder.Code != test.expectedStatus {
+				t.Errorf("got status code %d, want %d", recorder.Code, test.expectedStatus)
+			}
+		})
+	}
+}
+
+func TestSimpleBlocklist_TrustedProxies(t *testing.T) {
+	cfg := simpleblocklist.CreateConfig()
+	cfg.BlacklistPath = createBlacklistFile(t, "192.0.2.1\n")
+	cfg.TrustedProxies = []string{"10.0.0.0/8", "203.0.113.10"}
+
+	ctx := context.Background()
+	next := http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		rw.WriteHeader(http.StatusOK)
+	})
+
+	handler, err := simpleblocklist.New(ctx, next, cfg, "simpleblocklist")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		desc           string
+		forwardedFor   string
+		remoteAddr     string
+		expectedStatus int
+	}{
+		{
+			desc:           "blacklisted client behind trusted proxies",
+			forwardedFor:   "192.0.2.1, 203.0.113.10",
+			remoteAddr:     "10.0.0.2:1234",
+			expectedStatus: 403,
+		},
+		{
+			desc:           "clean client behind trusted proxies",
+			forwardedFor:   "198.51.100.1, 203.0.113.10",
+			remoteAddr:     "10.0.0.2:1234",
+			expectedStatus: 200,
+		},
+		{
+			desc:           "spoofed entry left of the client is ignored",
+			forwardedFor:   "192.0.2.1, 198.51.100.1",
+			remoteAddr:     "10.0.0.2:1234",
+			expectedStatus: 200,
+		},
+		{
+			desc:           "spoofed clean entry can't hide a blacklisted client",
+			forwardedFor:   "198.51.100.1, 192.0.2.1",
+			remoteAddr:     "10.0.0.2:1234",
+			expectedStatus: 403,
+		},
+		{
+			desc:           "header ignored from an untrusted peer",
+			forwardedFor:   "198.51.100.1",
+			remoteAddr:     "192.0.2.1:1234",
+			expectedStatus: 403,
+		},
+		{
+			desc:           "header ignored from an untrusted peer hiding nothing",
+			forwardedFor:   "192.0.2.1",
+			remoteAddr:     "198.51.100.1:1234",
+			expectedStatus: 200,
+		},
+		{
+			desc:           "only trusted proxies",
+			forwardedFor:   "10.0.0.3, 203.0.113.10",
+			remoteAddr:     "10.0.0.2:1234",
+			expectedStatus: 200,
+		},
+		{
+			desc:           "invalid entry stops the walk",
+			forwardedFor:   "192.0.2.1, unknown, 10.0.0.3",
+			remoteAddr:     "10.0.0.2:1234",
+			expectedStatus: 200,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			recorder := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodGet, "http://localhost", nil)
+			req.RemoteAddr = test.remoteAddr
+			req.Header.Set("X-Forwarded-For", test.forwardedFor)
+
+			handler.ServeHTTP(recorder, req)
+
+			if recorder.Code != test.expectedStatus {
+				t.Errorf("got status code %d, want %d", recorder.Code, test.expectedStatus)
+			}
+		})
+	}
+}
@@ -0,0 +1,251 @@
+package simpleblocklist
+
+import (
+	"encoding/base64"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+)
+
+// BlocklistSource a backend the blacklist is loaded from. Load is called when the middleware is
+// created and on every reload, and returns all the networks to block.
+type BlocklistSource interface {
+	Load() ([]*net.IPNet, error)
+}
+
+// parsedSource is implemented by the built-in sources, which also load exceptions and entry
+// metadata that Load can't return.
+type parsedSource interface {
+	loadParsed() (*parseResult, error)
+}
+
+// FileSource loads blacklist files, see loadBlacklists.
+type FileSource struct {
+	paths  []string
+	opts   blacklistOptions
+	logger *logger
+}
+
+// Load returns the networks blacklisted by the files, without their exceptions.
+func (s *FileSource) Load() ([]*net.IPNet, error) {
+	result, err := s.loadParsed()
+	if err != nil {
+		return nil, err
+	}
+	return result.networks, nil
+}
+
+func (s *FileSource) loadParsed() (*parseResult, error) {
+	return loadBlacklists(s.paths, s.opts, s.logger)
+}
+
+// BinaryFileSource loads a binary blacklist file, see CompileBinaryBlacklist.
+type BinaryFileSource struct {
+	path   string
+	opts   blacklistOptions
+	logger *logger
+}
+
+// Load returns the networks of the binary blacklist.
+func (s *BinaryFileSource) Load() ([]*net.IPNet, error) {
+	result, err := s.loadParsed()
+	if err != nil {
+		return nil, err
+	}
+	return result.networks, nil
+}
+
+func (s *BinaryFileSource) loadParsed() (*parseResult, error) {
+	return loadBinaryBlacklist(s.path, s.opts, s.logger)
+}
+
+// InlineSource parses a blacklist given as content rather than read from a file, see
+// parseBlacklistFile. Includes are not supported as there is no file to resolve them against.
+type InlineSource struct {
+	// name describes the content in logs and errors.
+	name    string
+	content string
+	opts    blacklistOptions
+	logger  *logger
+}
+
+// Load returns the networks blacklisted by the content, without its exceptions.
+func (s *InlineSource) Load() ([]*net.IPNet, error) {
+	result, err := s.loadParsed()
+	if err != nil {
+		return nil, err
+	}
+	return result.networks, nil
+}
+
+func (s *InlineSource) loadParsed() (*parseResult, error) {
+	result, err := parseBlacklistFile("", strings.NewReader(s.content), s.opts)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", s.name, err)
+	}
+	if len(result.includes) != 0 {
+		return nil, fmt.Errorf("%s: includes are not supported", s.name)
+	}
+
+	s.logger.infof(logFields{"entries": len(result.networks), "skipped": result.skipped},
+		"Loaded %d IPs/Networks from %s", len(result.networks), s.name)
+	return result, nil
+}
+
+// URLSource downloads a blacklist over HTTP(S), see fetchBlacklist. Once a download succeeded, a
+// failed one is logged and the last downloaded list is used instead, so an unreachable server
+// doesn't fail the reloads of the other sources.
+type URLSource struct {
+	url    string
+	opts   blacklistOptions
+	logger *logger
+
+	mu   sync.Mutex
+	last *parseResult
+}
+
+// Load returns the networks blacklisted by the downloaded list, without its exceptions.
+func (s *URLSource) Load() ([]*net.IPNet, error) {
+	result, err := s.loadParsed()
+	if err != nil {
+		return nil, err
+	}
+	return result.networks, nil
+}
+
+func (s *URLSource) loadParsed() (*parseResult, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	result, err := fetchBlacklist(s.url, s.opts)
+	if err != nil {
+		if s.last == nil {
+			return nil, fmt.Errorf("%s: %v", s.url, err)
+		}
+		s.logger.warnf(logFields{"url": s.url}, "Failed to download blacklist %s, keeping the last download: %v", s.url, err)
+		return s.last, nil
+	}
+
+	s.logger.infof(logFields{"url": s.url, "entries": len(result.networks), "skipped": result.skipped},
+		"Loaded %d IPs/Networks from %s", len(result.networks), s.url)
+	s.last = result
+	return result, nil
+}
+
+// RedisSource loads the members of a Redis set, see loadSetMembers.
+type RedisSource struct {
+	store  SetStore
+	key    string
+	opts   blacklistOptions
+	logger *logger
+}
+
+// Load returns the networks of the set members.
+func (s *RedisSource) Load() ([]*net.IPNet, error) {
+	result, err := s.loadParsed()
+	if err != nil {
+		return nil, err
+	}
+	return result.networks, nil
+}
+
+func (s *RedisSource) loadParsed() (*parseResult, error) {
+	return loadSetMembers(s.store, s.key, s.opts, s.logger)
+}
+
+// newBlocklistSources returns the sources selected by config: the blacklist files at paths, if
+// any, then the binary blacklist, the inline, base64 and listed entries, the remote blacklists and
+// the Redis set.
+func newBlocklistSources(config *Config, paths []string, opts blacklistOptions, logger *logger) []BlocklistSource {
+	var sources []BlocklistSource
+	if len(paths) != 0 {
+		sources = append(sources, &FileSource{paths: paths, opts: opts, logger: logger})
+	}
+	if len(config.BinaryBlacklistPath) != 0 {
+		sources = append(sources, &BinaryFileSource{path: config.BinaryBlacklistPath, opts: opts, logger: logger})
+	}
+	if len(config.BlacklistInline) != 0 {
+		sources = append(sources, &InlineSource{name: "the inline blacklist", content: config.BlacklistInline, opts: opts, logger: logger})
+	}
+	if len(config.BlacklistBase64) != 0 {
+		// Validate already checked the encoding
+		content, _ := decodeBase64Blacklist(config.BlacklistBase64)
+		sources = append(sources, &InlineSource{name: "the base64 blacklist", content: string(content), opts: opts, logger: logger})
+	}
+	if len(config.BlacklistedIPs) != 0 {
+		// Entries are listed one by one whatever the format of the blacklist files
+		plain := opts
+		plain.format = blacklistFormatPlain
+		sources = append(sources, &InlineSource{
+			name:    "blacklistedIPs",
+			content: strings.Join(config.BlacklistedIPs, "\n"),
+			opts:    plain,
+			logger:  logger,
+		})
+	}
+	for _, u := range blacklistURLs(config) {
+		sources = append(sources, &URLSource{url: u, opts: opts, logger: logger})
+	}
+	if len(config.RedisAddr) != 0 {
+		sources = append(sources, &RedisSource{
+			store:  newSetStore(config.RedisAddr),
+			key:    config.RedisKey,
+			opts:   opts,
+			logger: logger,
+		})
+	}
+	return sources
+}
+
+// loadSources loads and merges all sources, without duplicate networks. It fails if any source
+// fails, so a reload never swaps in a partial list, or if more than maxEntries networks are loaded
+// when it isn't 0.
+func loadSources(sources []BlocklistSource, maxEntries int) (*parseResult, error) {
+	result := &parseResult{}
+	for _, source := range sources {
+		var sourceResult *parseResult
+		if parsed, ok := source.(parsedSource); ok {
+			var err error
+			if sourceResult, err = parsed.loadParsed(); err != nil {
+				return nil, err
+			}
+		} else {
+			networks, err := source.Load()
+			if err != nil {
+				return nil, err
+			}
+			sourceResult = &parseResult{networks: networks}
+		}
+
+		result.merge(sourceResult)
+		if maxEntries > 0 && len(result.networks)+len(result.exceptions) > maxEntries {
+			return nil, fmt.Errorf("blacklists exceed the maximum of %d entries", maxEntries)
+		}
+	}
+	result.dedupe()
+	return result, nil
+}
+
+// blacklistURLs returns the remote blacklist URLs, blacklistURL first, each listed once.
+func blacklistURLs(config *Config) []string {
+	var urls []string
+	seen := make(map[string]bool)
+	for _, u := range append([]string{config.BlacklistURL}, config.BlacklistURLs...) {
+		if u = strings.TrimSpace(u); len(u) != 0 && !seen[u] {
+			seen[u] = true
+			urls = append(urls, u)
+		}
+	}
+	return urls
+}
+
+// decodeBase64Blacklist decodes a base64-encoded blacklist, padded or not. Whitespace is ignored, so
+// the encoded value may be wrapped over several lines.
+func decodeBase64Blacklist(encoded string) ([]byte, error) {
+	encoded = strings.Join(strings.Fields(encoded), "")
+	if strings.HasSuffix(encoded, "=") {
+		return base64.StdEncoding.DecodeString(encoded)
+	}
+	return base64.RawStdEncoding.DecodeString(encoded)
+}
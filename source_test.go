@@ -0,0 +1,460 @@
+package simpleblocklist_test
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/LucaNori/traefik-simpleblocklist"
+)
+
+// stubSource a simpleblocklist.BlocklistSource returning fixed networks.
+type stubSource struct {
+	cidrs []string
+	err   error
+}
+
+func (s *stubSource) Load() ([]*net.IPNet, error) {
+	if s.err != nil {
+		return nil, s.err
+	}
+
+	var networks []*net.IPNet
+	for _, cidr := range s.cidrs {
+		_, network, err := net.ParseCIDR(cidr)
+		if err != nil {
+			return nil, err
+		}
+		networks = append(networks, network)
+	}
+	return networks, nil
+}
+
+func TestSimpleBlocklist_SourcesFromConfig(t *testing.T) {
+	defer simpleblocklist.SetSetStore(&memorySetStore{sets: map[string][]string{}})()
+
+	tests := []struct {
+		desc       string
+		update     func(cfg *simpleblocklist.Config)
+		wantFile   bool
+		wantInline bool
+		wantRedis  bool
+	}{
+		{
+			desc: "file",
+			update: func(cfg *simpleblocklist.Config) {
+				cfg.BlacklistPath = createBlacklistFile(t, "192.0.2.1\n")
+			},
+			wantFile: true,
+		},
+		{
+			desc: "redis",
+			update: func(cfg *simpleblocklist.Config) {
+				cfg.RedisAddr = "redis:6379"
+				cfg.RedisKey = "blocklist"
+			},
+			wantRedis: true,
+		},
+		{
+			desc: "inline",
+			update: func(cfg *simpleblocklist.Config) {
+				cfg.BlacklistInline = "192.0.2.1\n"
+			},
+			wantInline: true,
+		},
+		{
+			desc: "file and redis",
+			update: func(cfg *simpleblocklist.Config) {
+				cfg.BlacklistPath = createBlacklistFile(t, "192.0.2.1\n")
+				cfg.RedisAddr = "redis:6379"
+				cfg.RedisKey = "blocklist"
+			},
+			wantFile:  true,
+			wantRedis: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			cfg := simpleblocklist.CreateConfig()
+			test.update(cfg)
+
+			next := http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {})
+
+			handler, err := simpleblocklist.New(context.Background(), next, cfg, "simpleblocklist")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var gotFile, gotInline, gotRedis bool
+			for _, source := range simpleblocklist.Sources(handler) {
+				switch source.(type) {
+				case *simpleblocklist.FileSource:
+					gotFile = true
+				case *simpleblocklist.InlineSource:
+					gotInline = true
+				case *simpleblocklist.RedisSource:
+					gotRedis = true
+				default:
+					t.Errorf("unexpected source %T", source)
+				}
+			}
+			if gotFile != test.wantFile || gotInline != test.wantInline || gotRedis != test.wantRedis {
+				t.Errorf("got file source %t, inline source %t, redis source %t, want %t, %t, %t",
+					gotFile, gotInline, gotRedis, test.wantFile, test.wantInline, test.wantRedis)
+			}
+		})
+	}
+}
+
+func TestSimpleBlocklist_StubSource(t *testing.T) {
+	cfg := simpleblocklist.CreateConfig()
+	cfg.BlacklistPath = createBlacklistFile(t, "192.0.2.1\n")
+
+	next := http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {})
+
+	handler, err := simpleblocklist.New(context.Background(), next, cfg, "simpleblocklist")
+	if err != nil {
+		t.Fatal(err)
+	}
+	blocklist := handler.(*simpleblocklist.SimpleBlocklist)
+
+	simpleblocklist.SetSources(handler,
+		&stubSource{cidrs: []string{"198.51.100.0/24"}},
+		&stubSource{cidrs: []string{"2001:db8::/32"}})
+	if err := simpleblocklist.Reload(handler); err != nil {
+		t.Fatal(err)
+	}
+
+	for ip, expected := range map[string]bool{"192.0.2.1": false, "198.51.100.7": true, "2001:db8::1": true} {
+		if blocked, _ := blocklist.IsBlocked(net.ParseIP(ip)); blocked != expected {
+			t.Errorf("IsBlocked(%s) = %t, want %t", ip, blocked, expected)
+		}
+	}
+
+	// A failing source fails the reload and keeps the current list
+	simpleblocklist.SetSources(handler,
+		&stubSource{cidrs: []string{"192.0.2.0/24"}},
+		&stubSource{err: errors.New("backend unavailable")})
+	if err := simpleblocklist.Reload(handler); err == nil {
+		t.Fatal("expected the reload to fail")
+	}
+
+	if blocked, _ := blocklist.IsBlocked(net.ParseIP("198.51.100.7")); !blocked {
+		t.Error("expected the previous list to be kept after a failed reload")
+	}
+}
+
+func TestSimpleBlocklist_BlacklistInline(t *testing.T) {
+	cfg := simpleblocklist.CreateConfig()
+	cfg.BlacklistInline = "192.0.2.1\n# a comment\n198.51.100.0/24\n!198.51.100.7\n"
+
+	next := http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {})
+
+	handler, err := simpleblocklist.New(context.Background(), next, cfg, "simpleblocklist")
+	if err != nil {
+		t.Fatal(err)
+	}
+	blocklist := handler.(*simpleblocklist.SimpleBlocklist)
+
+	for ip, expected := range map[string]bool{"192.0.2.1": true, "198.51.100.1": true, "198.51.100.7": false, "203.0.113.1": false} {
+		if blocked, _ := blocklist.IsBlocked(net.ParseIP(ip)); blocked != expected {
+			t.Errorf("IsBlocked(%s) = %t, want %t", ip, blocked, expected)
+		}
+	}
+
+	// The inline entries are merged with the blacklist files
+	cfg.BlacklistPath = createBlacklistFile(t, "203.0.113.1\n")
+	handler, err = simpleblocklist.New(context.Background(), next, cfg, "simpleblocklist")
+	if err != nil {
+		t.Fatal(err)
+	}
+	blocklist = handler.(*simpleblocklist.SimpleBlocklist)
+
+	for _, ip := range []string{"192.0.2.1", "203.0.113.1"} {
+		if blocked, _ := blocklist.IsBlocked(net.ParseIP(ip)); !blocked {
+			t.Errorf("IsBlocked(%s) = false, want true", ip)
+		}
+	}
+
+	cfg.BlacklistInline = "include other.txt\n"
+	if _, err := simpleblocklist.New(context.Background(), next, cfg, "simpleblocklist"); err == nil {
+		t.Error("expected an include in the inline blacklist to fail")
+	}
+}
+
+func TestSimpleBlocklist_BlacklistURL(t *testing.T) {
+	var mu sync.Mutex
+	status, body := http.StatusOK, "192.0.2.1\n"
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		rw.WriteHeader(status)
+		_, _ = rw.Write([]byte(body))
+	}))
+	defer server.Close()
+
+	serve := func(newStatus int, newBody string) {
+		mu.Lock()
+		defer mu.Unlock()
+		status, body = newStatus, newBody
+	}
+
+	cfg := simpleblocklist.CreateConfig()
+	cfg.BlacklistURL = server.URL + "/blocklist.txt"
+
+	next := http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {})
+
+	handler, err := simpleblocklist.New(context.Background(), next, cfg, "simpleblocklist")
+	if err != nil {
+		t.Fatal(err)
+	}
+	blocklist := handler.(*simpleblocklist.SimpleBlocklist)
+
+	if blocked, _ := blocklist.IsBlocked(net.ParseIP("192.0.2.1")); !blocked {
+		t.Error("expected the downloaded entry to be blocked")
+	}
+
+	// A failed download keeps the last downloaded list
+	serve(http.StatusInternalServerError, "")
+	if err := simpleblocklist.Reload(handler); err != nil {
+		t.Fatalf("expected the reload to keep the last download, got %v", err)
+	}
+	if blocked, _ := blocklist.IsBlocked(net.ParseIP("192.0.2.1")); !blocked {
+		t.Error("expected the last downloaded list to be kept")
+	}
+
+	serve(http.StatusOK, "198.51.100.1\n")
+	if err := simpleblocklist.Reload(handler); err != nil {
+		t.Fatal(err)
+	}
+	for ip, expected := range map[string]bool{"192.0.2.1": false, "198.51.100.1": true} {
+		if blocked, _ := blocklist.IsBlocked(net.ParseIP(ip)); blocked != expected {
+			t.Errorf("IsBlocked(%s) = %t after the refresh, want %t", ip, blocked, expected)
+		}
+	}
+
+	// Until a download succeeded, a failed one fails the configuration
+	serve(http.StatusNotFound, "")
+	if _, err := simpleblocklist.New(context.Background(), next, cfg, "simpleblocklist"); err == nil {
+		t.Error("expected a failed first download to fail")
+	}
+}
+
+func TestSimpleBlocklist_GzipBlacklistURL(t *testing.T) {
+	var compressed bytes.Buffer
+	gz := gzip.NewWriter(&compressed)
+	if _, err := gz.Write([]byte("192.0.2.1\n198.51.100.0/24\n")); err != nil {
+		t.Fatal(err)
+	}
+	if err := gz.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		_, _ = rw.Write(compressed.Bytes())
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		desc string
+		path string
+	}{
+		{desc: "gz extension", path: "/blocklist.txt.gz"},
+		{desc: "gzip header without the extension", path: "/blocklist"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			cfg := simpleblocklist.CreateConfig()
+			cfg.BlacklistURL = server.URL + test.path
+			cfg.StrictParsing = true
+
+			next := http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {})
+
+			handler, err := simpleblocklist.New(context.Background(), next, cfg, "simpleblocklist")
+			if err != nil {
+				t.Fatal(err)
+			}
+			blocklist := handler.(*simpleblocklist.SimpleBlocklist)
+
+			for ip, expected := range map[string]bool{"192.0.2.1": true, "198.51.100.7": true, "203.0.113.1": false} {
+				if blocked, _ := blocklist.IsBlocked(net.ParseIP(ip)); blocked != expected {
+					t.Errorf("IsBlocked(%s) = %t, want %t", ip, blocked, expected)
+				}
+			}
+		})
+	}
+}
+
+func TestSimpleBlocklist_GzipBombBlacklistURL(t *testing.T) {
+	defer simpleblocklist.SetMaxRemoteDecompressedSize(1 << 20)()
+
+	// Compresses to a few kilobytes but decompresses past the limit
+	var compressed bytes.Buffer
+	gz := gzip.NewWriter(&compressed)
+	if _, err := gz.Write([]byte("192.0.2.1\n" + strings.Repeat("\n", 2<<20))); err != nil {
+		t.Fatal(err)
+	}
+	if err := gz.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		_, _ = rw.Write(compressed.Bytes())
+	}))
+	defer server.Close()
+
+	cfg := simpleblocklist.CreateConfig()
+	cfg.BlacklistURL = server.URL + "/blocklist"
+
+	next := http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {})
+
+	_, err := simpleblocklist.New(context.Background(), next, cfg, "simpleblocklist")
+	if err == nil || !strings.Contains(err.Error(), "blacklist exceeds 1048576 bytes") {
+		t.Errorf("expected the decompressed size limit to fail the download, got %v", err)
+	}
+}
+
+func TestSimpleBlocklist_BlacklistURLs(t *testing.T) {
+	var buf bytes.Buffer
+	defer simpleblocklist.SetLogOutput(&buf)()
+
+	newFeed := func(body string) *httptest.Server {
+		return httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+			_, _ = rw.Write([]byte(body))
+		}))
+	}
+	first := newFeed("192.0.2.1\n198.51.100.0/24\n")
+	defer first.Close()
+	second := newFeed("198.51.100.0/24\n203.0.113.7\n")
+	defer second.Close()
+
+	cfg := simpleblocklist.CreateConfig()
+	// The repeated URL is only downloaded once
+	cfg.BlacklistURLs = []string{first.URL, second.URL, first.URL}
+	cfg.StatusPath = "/_blocklist/status"
+	cfg.AdminAllowedIPs = []string{"127.0.0.1"}
+
+	next := http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {})
+
+	handler, err := simpleblocklist.New(context.Background(), next, cfg, "simpleblocklist")
+	if err != nil {
+		t.Fatal(err)
+	}
+	blocklist := handler.(*simpleblocklist.SimpleBlocklist)
+
+	for _, ip := range []string{"192.0.2.1", "198.51.100.7", "203.0.113.7"} {
+		if blocked, _ := blocklist.IsBlocked(net.ParseIP(ip)); !blocked {
+			t.Errorf("IsBlocked(%s) = false, want true", ip)
+		}
+	}
+
+	if got := len(simpleblocklist.Sources(handler)); got != 2 {
+		t.Errorf("got %d sources, want 2", got)
+	}
+	for _, want := range []string{"Loaded 2 IPs/Networks from " + first.URL, "Loaded 2 IPs/Networks from " + second.URL} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("expected log line %q, got %q", want, buf.String())
+		}
+	}
+
+	recorder := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "http://localhost/_blocklist/status", nil)
+	req.RemoteAddr = "127.0.0.1:1234"
+	handler.ServeHTTP(recorder, req)
+
+	var status struct {
+		Networks int `json:"networks"`
+	}
+	if err := json.Unmarshal(recorder.Body.Bytes(), &status); err != nil {
+		t.Fatal(err)
+	}
+	if status.Networks != 3 {
+		t.Errorf("got networks %d, want 3 without the duplicate", status.Networks)
+	}
+}
+
+func TestSimpleBlocklist_BlacklistedIPs(t *testing.T) {
+	cfg := simpleblocklist.CreateConfig()
+	cfg.BlacklistPath = createBlacklistFile(t, "203.0.113.1\n")
+	cfg.BlacklistedIPs = []string{"192.0.2.1", "198.51.100.0/24", "!198.51.100.7", "2001:db8::/32"}
+	// The entries are parsed one by one whatever the format of the files
+	cfg.BlacklistFormat = "hosts"
+
+	next := http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {})
+
+	handler, err := simpleblocklist.New(context.Background(), next, cfg, "simpleblocklist")
+	if err != nil {
+		t.Fatal(err)
+	}
+	blocklist := handler.(*simpleblocklist.SimpleBlocklist)
+
+	for ip, expected := range map[string]bool{
+		"192.0.2.1":    true,
+		"198.51.100.1": true,
+		"198.51.100.7": false,
+		"2001:db8::1":  true,
+		"203.0.113.1":  true,
+		"203.0.113.2":  false,
+	} {
+		if blocked, _ := blocklist.IsBlocked(net.ParseIP(ip)); blocked != expected {
+			t.Errorf("IsBlocked(%s) = %t, want %t", ip, blocked, expected)
+		}
+	}
+
+	cfg.BlacklistedIPs = []string{"192.0.2.1", "not-an-ip"}
+	if _, err := simpleblocklist.New(context.Background(), next, cfg, "simpleblocklist"); err == nil || !strings.Contains(err.Error(), `invalid blacklisted IP "not-an-ip"`) {
+		t.Errorf("got error %v, want an invalid blacklisted IP error", err)
+	}
+}
+
+func TestSimpleBlocklist_BlacklistBase64(t *testing.T) {
+	encoded := base64.StdEncoding.EncodeToString([]byte("# from a CRD\n192.0.2.1\n198.51.100.0/24\n!198.51.100.7\n"))
+
+	tests := []struct {
+		desc    string
+		encoded string
+	}{
+		{desc: "padded", encoded: encoded},
+		{desc: "unpadded", encoded: strings.TrimRight(encoded, "=")},
+		{desc: "wrapped", encoded: encoded[:20] + "\n  " + encoded[20:]},
+	}
+
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			cfg := simpleblocklist.CreateConfig()
+			cfg.BlacklistBase64 = test.encoded
+
+			next := http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {})
+
+			handler, err := simpleblocklist.New(context.Background(), next, cfg, "simpleblocklist")
+			if err != nil {
+				t.Fatal(err)
+			}
+			blocklist := handler.(*simpleblocklist.SimpleBlocklist)
+
+			for ip, expected := range map[string]bool{"192.0.2.1": true, "198.51.100.1": true, "198.51.100.7": false} {
+				if blocked, _ := blocklist.IsBlocked(net.ParseIP(ip)); blocked != expected {
+					t.Errorf("IsBlocked(%s) = %t, want %t", ip, blocked, expected)
+				}
+			}
+		})
+	}
+
+	cfg := simpleblocklist.CreateConfig()
+	cfg.BlacklistBase64 = "not base64!"
+	next := http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {})
+	if _, err := simpleblocklist.New(context.Background(), next, cfg, "simpleblocklist"); err == nil || !strings.Contains(err.Error(), "invalid base64 blacklist") {
+		t.Errorf("got error %v, want an invalid base64 error", err)
+	}
+}
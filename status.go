@@ -0,0 +1,49 @@
+package simpleblocklist
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+)
+
+// status the JSON payload served on the status path.
+type status struct {
+	Networks      int                  `json:"networks"`
+	LastReload    time.Time            `json:"last_reload"`
+	DryRun        bool                 `json:"dry_run"`
+	TopBlockedIPs []blockedIPCount     `json:"top_blocked_ips,omitempty"`
+	Metrics       *loadMetricsSnapshot `json:"metrics,omitempty"`
+}
+
+// serveStatus answers with the state of the lists. Like the check path, only admin callers are
+// answered, since the top blocked IPs identify clients; those IPs are masked with anonymizeLoggedIPs.
+func (a *SimpleBlocklist) serveStatus(rw http.ResponseWriter, req *http.Request) {
+	if !a.isAdminCaller(req) {
+		a.writeJSONError(rw, http.StatusForbidden, "the status path is only available to the admin allowed IPs")
+		return
+	}
+
+	a.mu.RLock()
+	payload := status{
+		Networks:   a.networks,
+		LastReload: a.lastReload,
+		DryRun:     a.dryRun,
+	}
+	a.mu.RUnlock()
+
+	if a.blockCounter != nil {
+		payload.TopBlockedIPs = a.blockCounter.top(a.statusTopBlockedIPs)
+		for i := range payload.TopBlockedIPs {
+			payload.TopBlockedIPs[i].IP = a.logIP(payload.TopBlockedIPs[i].IP)
+		}
+	}
+
+	if a.metrics != nil {
+		payload.Metrics = a.metrics.snapshot()
+	}
+
+	rw.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(rw).Encode(payload); err != nil {
+		a.logger.warnf(nil, "Failed to write status: %v", err)
+	}
+}
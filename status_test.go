@@ -0,0 +1,334 @@
+package simpleblocklist_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/LucaNori/traefik-simpleblocklist"
+)
+
+func TestSimpleBlocklist_StatusPath(t *testing.T) {
+	cfg := simpleblocklist.CreateConfig()
+	cfg.BlacklistPath = createBlacklistFile(t, "192.0.2.1\n198.51.100.0/24\n2001:db8::/32\n")
+	cfg.StatusPath = "/_blocklist/status"
+	cfg.AdminAllowedIPs = []string{"127.0.0.1"}
+	cfg.DryRun = true
+
+	ctx := context.Background()
+	next := http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		rw.WriteHeader(http.StatusTeapot)
+	})
+
+	before := time.Now()
+	handler, err := simpleblocklist.New(ctx, next, cfg, "simpleblocklist")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	recorder := httptest.NewRecorder()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, "http://localhost/_blocklist/status", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.RemoteAddr = "127.0.0.1:1234"
+
+	handler.ServeHTTP(recorder, req)
+
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("got status code %d, want 200", recorder.Code)
+	}
+	if contentType := recorder.Header().Get("Content-Type"); contentType != "application/json" {
+		t.Errorf("got Content-Type %q, want application/json", contentType)
+	}
+
+	var status struct {
+		Networks   int       `json:"networks"`
+		LastReload time.Time `json:"last_reload"`
+		DryRun     bool      `json:"dry_run"`
+	}
+	if err := json.Unmarshal(recorder.Body.Bytes(), &status); err != nil {
+		t.Fatal(err)
+	}
+
+	if status.Networks != 3 {
+		t.Errorf("got networks %d, want 3", status.Networks)
+	}
+	if status.LastReload.Before(before.Truncate(time.Second)) {
+		t.Errorf("got last_reload %s, want a time after %s", status.LastReload, before)
+	}
+	if !status.DryRun {
+		t.Error("got dry_run false, want true")
+	}
+}
+
+func TestSimpleBlocklist_StatusPathDisabled(t *testing.T) {
+	cfg := simpleblocklist.CreateConfig()
+	cfg.BlacklistPath = createBlacklistFile(t, "192.0.2.1\n")
+
+	ctx := context.Background()
+	next := http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		rw.WriteHeader(http.StatusTeapot)
+	})
+
+	handler, err := simpleblocklist.New(ctx, next, cfg, "simpleblocklist")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	recorder := httptest.NewRecorder()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, "http://localhost/_blocklist/status", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	handler.ServeHTTP(recorder, req)
+
+	if recorder.Code != http.StatusTeapot {
+		t.Errorf("got status code %d, want the request to be forwarded", recorder.Code)
+	}
+}
+
+func TestSimpleBlocklist_StatusPathAdminCallers(t *testing.T) {
+	cfg := simpleblocklist.CreateConfig()
+	cfg.BlacklistPath = createBlacklistFile(t, "192.0.2.1\n")
+	cfg.StatusPath = "/_blocklist/status"
+	cfg.AdminAllowedIPs = []string{"198.51.100.10"}
+	cfg.TrustedProxies = []string{"10.0.0.2"}
+
+	ctx := context.Background()
+	next := http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		rw.WriteHeader(http.StatusTeapot)
+	})
+
+	handler, err := simpleblocklist.New(ctx, next, cfg, "simpleblocklist")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		desc           string
+		remoteAddr     string
+		forwardedFor   string
+		expectedStatus int
+	}{
+		{
+			desc:           "allowed caller",
+			remoteAddr:     "198.51.100.10:1234",
+			expectedStatus: 200,
+		},
+		{
+			desc:           "allowed caller behind a trusted proxy",
+			remoteAddr:     "10.0.0.2:1234",
+			forwardedFor:   "198.51.100.10",
+			expectedStatus: 200,
+		},
+		{
+			desc:           "other caller behind a trusted proxy",
+			remoteAddr:     "10.0.0.2:1234",
+			forwardedFor:   "203.0.113.1",
+			expectedStatus: 403,
+		},
+		{
+			desc:           "local caller that is not allowed, such as a load balancer",
+			remoteAddr:     "127.0.0.1:1234",
+			expectedStatus: 403,
+		},
+		{
+			desc:           "public caller",
+			remoteAddr:     "203.0.113.1:1234",
+			expectedStatus: 403,
+		},
+		{
+			desc:           "public caller with a forged allowed header",
+			remoteAddr:     "203.0.113.1:1234",
+			forwardedFor:   "198.51.100.10",
+			expectedStatus: 403,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			recorder := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodGet, "http://localhost/_blocklist/status", nil)
+			req.RemoteAddr = test.remoteAddr
+			if test.forwardedFor != "" {
+				req.Header.Set("X-Forwarded-For", test.forwardedFor)
+			}
+
+			handler.ServeHTTP(recorder, req)
+
+			if recorder.Code != test.expectedStatus {
+				t.Errorf("got status code %d, want %d", recorder.Code, test.expectedStatus)
+			}
+		})
+	}
+}
+
+func TestSimpleBlocklist_StatusTopBlockedIPs(t *testing.T) {
+	cfg := simpleblocklist.CreateConfig()
+	cfg.BlacklistPath = createBlacklistFile(t, "192.0.2.0/24\n198.18.0.0/15\n")
+	cfg.StatusPath = "/_blocklist/status"
+	cfg.AdminAllowedIPs = []string{"127.0.0.1"}
+	cfg.StatusTopBlockedIPs = 2
+
+	ctx := context.Background()
+	next := http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		rw.WriteHeader(http.StatusOK)
+	})
+
+	handler, err := simpleblocklist.New(ctx, next, cfg, "simpleblocklist")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	serve := func(ip string) {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, "http://localhost", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("X-Forwarded-For", ip)
+		handler.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	topBlockedIPs := func() []map[string]interface{} {
+		recorder := httptest.NewRecorder()
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, "http://localhost/_blocklist/status", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.RemoteAddr = "127.0.0.1:1234"
+		handler.ServeHTTP(recorder, req)
+
+		var status struct {
+			TopBlockedIPs []map[string]interface{} `json:"top_blocked_ips"`
+		}
+		if err := json.Unmarshal(recorder.Body.Bytes(), &status); err != nil {
+			t.Fatal(err)
+		}
+		return status.TopBlockedIPs
+	}
+
+	for ip, requests := range map[string]int{"192.0.2.1": 2, "192.0.2.2": 5, "192.0.2.3": 3, "203.0.113.1": 10} {
+		for i := 0; i < requests; i++ {
+			serve(ip)
+		}
+	}
+
+	got, err := json.Marshal(topBlockedIPs())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := `[{"count":5,"ip":"192.0.2.2"},{"count":3,"ip":"192.0.2.3"}]`; string(got) != want {
+		t.Errorf("got top blocked IPs %s, want %s", got, want)
+	}
+
+	// Flood the counter with IPs blocked once, the most blocked IP must survive the evictions
+	for i := 0; i < 1500; i++ {
+		serve(fmt.Sprintf("198.18.%d.%d", i/256, i%256))
+	}
+
+	top := topBlockedIPs()
+	if len(top) != 2 || top[0]["ip"] != "192.0.2.2" || top[0]["count"] != float64(5) {
+		t.Errorf("got top blocked IPs %v after the flood, want 192.0.2.2 first", top)
+	}
+}
+
+func TestSimpleBlocklist_StatusTopBlockedIPsAnonymized(t *testing.T) {
+	cfg := simpleblocklist.CreateConfig()
+	cfg.BlacklistPath = createBlacklistFile(t, "192.0.2.0/24\n")
+	cfg.StatusPath = "/_blocklist/status"
+	cfg.AdminAllowedIPs = []string{"127.0.0.1"}
+	cfg.AnonymizeLoggedIPs = true
+
+	ctx := context.Background()
+	next := http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {})
+
+	handler, err := simpleblocklist.New(ctx, next, cfg, "simpleblocklist")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "http://localhost", nil)
+	req.Header.Set("X-Forwarded-For", "192.0.2.123")
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	recorder := httptest.NewRecorder()
+	req = httptest.NewRequest(http.MethodGet, "http://localhost/_blocklist/status", nil)
+	req.RemoteAddr = "127.0.0.1:1234"
+	handler.ServeHTTP(recorder, req)
+
+	if body := recorder.Body.String(); !strings.Contains(body, `"ip":"192.0.2.0"`) || strings.Contains(body, "192.0.2.123") {
+		t.Errorf("got status %s, want the blocked IP masked", body)
+	}
+}
+
+func TestSimpleBlocklist_StatusMetrics(t *testing.T) {
+	blacklistPath := createBlacklistFile(t, "192.0.2.1\n")
+
+	cfg := simpleblocklist.CreateConfig()
+	cfg.BlacklistPath = blacklistPath
+	cfg.StatusPath = "/_blocklist/status"
+	cfg.AdminAllowedIPs = []string{"127.0.0.1"}
+	cfg.MetricsEnabled = true
+
+	ctx := context.Background()
+	next := http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {})
+
+	handler, err := simpleblocklist.New(ctx, next, cfg, "simpleblocklist")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	type metrics struct {
+		LastLoadDurationSeconds *float64 `json:"last_load_duration_seconds"`
+		ReloadFailuresTotal     uint64   `json:"reload_failures_total"`
+	}
+	getMetrics := func() metrics {
+		recorder := httptest.NewRecorder()
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, "http://localhost/_blocklist/status", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.RemoteAddr = "127.0.0.1:1234"
+		handler.ServeHTTP(recorder, req)
+
+		var status struct {
+			Metrics *metrics `json:"metrics"`
+		}
+		if err := json.Unmarshal(recorder.Body.Bytes(), &status); err != nil {
+			t.Fatal(err)
+		}
+		if status.Metrics == nil {
+			t.Fatalf("status %s has no metrics", recorder.Body.String())
+		}
+		return *status.Metrics
+	}
+
+	got := getMetrics()
+	if got.LastLoadDurationSeconds == nil || *got.LastLoadDurationSeconds < 0 {
+		t.Errorf("got last load duration %v, want a duration", got.LastLoadDurationSeconds)
+	}
+	if got.ReloadFailuresTotal != 0 {
+		t.Errorf("got %d reload failures after the first load, want 0", got.ReloadFailuresTotal)
+	}
+
+	if err := os.Remove(blacklistPath); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2; i++ {
+		if err := simpleblocklist.Reload(handler); err == nil {
+			t.Fatal("expected the reload of a missing blacklist to fail")
+		}
+	}
+
+	if got := getMetrics(); got.ReloadFailuresTotal != 2 {
+		t.Errorf("got %d reload failures, want 2", got.ReloadFailuresTotal)
+	}
+}
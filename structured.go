@@ -0,0 +1,162 @@
+package simpleblocklist
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+const (
+	blacklistFormatJSON = "json"
+	blacklistFormatTOML = "toml"
+	blacklistFormatCSV  = "csv"
+)
+
+// documentParsers parse the blacklist formats that describe entries as objects with metadata
+// rather than one entry per line.
+var documentParsers = map[string]func(r io.Reader, opts blacklistOptions) (*parseResult, error){
+	// JSON format: [{"cidr": "192.0.2.0/24", "reason": "...", "expires": "2030-01-01T00:00:00Z"}]
+	blacklistFormatJSON: func(r io.Reader, opts blacklistOptions) (*parseResult, error) {
+		var entries []structuredEntry
+		if err := json.NewDecoder(r).Decode(&entries); err != nil {
+			return nil, fmt.Errorf("invalid JSON blacklist: %v", err)
+		}
+		return parseStructuredEntries(entries, opts, time.Now())
+	},
+	// TOML format: one [[entries]] table per entry, with the same keys as the JSON format, see parseTOMLEntries
+	blacklistFormatTOML: func(r io.Reader, opts blacklistOptions) (*parseResult, error) {
+		entries, err := parseTOMLEntries(r)
+		if err != nil {
+			return nil, fmt.Errorf("invalid TOML blacklist: %v", err)
+		}
+		return parseStructuredEntries(entries, opts, time.Now())
+	},
+	// CSV format: "<cidr>,<reason>,<expires>" rows, see parseCSVBlacklist
+	blacklistFormatCSV: parseCSVBlacklist,
+}
+
+// csvHeaderColumns the names of the entry column recognized in a CSV header row, in lower case.
+var csvHeaderColumns = map[string]bool{"ip": true, "cidr": true, "network": true}
+
+// parseCSVBlacklist parses CSV rows of up to three columns, the entry, an optional reason and an
+// optional RFC 3339 expiry, e.g. "192.0.2.0/24,credential stuffing,2030-01-01T00:00:00Z". Lines
+// starting with "#" are comments, and a first row whose entry column is named in csvHeaderColumns is
+// a header such as "ip,reason,expiry". Rows with an invalid entry or expiry are skipped, or returned
+// as an error in strict mode.
+func parseCSVBlacklist(r io.Reader, opts blacklistOptions) (*parseResult, error) {
+	reader := csv.NewReader(r)
+	reader.Comment = '#'
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	var entries []structuredEntry
+	var skipped []string
+	for first := true; ; first = false {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid CSV blacklist: %v", err)
+		}
+		line, _ := reader.FieldPos(0)
+
+		if first && csvHeaderColumns[strings.ToLower(strings.TrimSpace(record[0]))] {
+			continue
+		}
+		entry, err := parseCSVRecord(record)
+		if err != nil {
+			if opts.strict {
+				return nil, fmt.Errorf("line %d: %v", line, err)
+			}
+			skipped = append(skipped, strings.Join(record, ","))
+			continue
+		}
+		entries = append(entries, entry)
+	}
+
+	result, err := parseStructuredEntries(entries, opts, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	for _, line := range skipped {
+		result.skip(line)
+	}
+	return result, nil
+}
+
+// parseCSVRecord returns the entry of a "cidr,reason,expires" record, whose last columns are optional.
+func parseCSVRecord(record []string) (structuredEntry, error) {
+	entry := structuredEntry{CIDR: strings.TrimSpace(record[0])}
+	if len(record) > 1 {
+		entry.Reason = strings.TrimSpace(record[1])
+	}
+	if len(record) > 2 && len(strings.TrimSpace(record[2])) != 0 {
+		expires, err := time.Parse(time.RFC3339, strings.TrimSpace(record[2]))
+		if err != nil {
+			return structuredEntry{}, fmt.Errorf("invalid expiry: %v", err)
+		}
+		entry.Expires = &expires
+	}
+	return entry, nil
+}
+
+// structuredEntry a blacklist entry of the JSON, TOML and CSV formats.
+type structuredEntry struct {
+	// CIDR any entry accepted by parseEntry, despite the name.
+	CIDR string `json:"cidr"`
+	// Reason why the entry is blacklisted, included in the logs of denied requests.
+	Reason string `json:"reason"`
+	// Expires when the entry stops being loaded, nil for a permanent entry.
+	Expires *time.Time `json:"expires"`
+}
+
+// entryAnnotation metadata attached to a blacklisted network.
+type entryAnnotation struct {
+	reason string
+	// expires when the network stops being blocked, zero for a permanent entry.
+	expires time.Time
+}
+
+// expired reports whether the entry has expired at now.
+func (e entryAnnotation) expired(now time.Time) bool {
+	return !e.expires.IsZero() && !now.Before(e.expires)
+}
+
+// parseStructuredEntries converts entries to networks, skipping the ones that expired before now.
+// Entries that expire later are annotated with their expiry, so they stop matching once it passes.
+// Entries that can't be parsed are skipped, or returned as an error in strict mode.
+func parseStructuredEntries(entries []structuredEntry, opts blacklistOptions, now time.Time) (*parseResult, error) {
+	result := &parseResult{}
+	for i, entry := range entries {
+		var annotation entryAnnotation
+		annotation.reason = entry.Reason
+		if entry.Expires != nil {
+			annotation.expires = *entry.Expires
+		}
+		if annotation.expired(now) {
+			result.expired++
+			continue
+		}
+
+		networks := parseEntry(entry.CIDR)
+		if networks == nil {
+			if opts.strict {
+				return nil, fmt.Errorf("entry %d: invalid IP address or network %q", i+1, entry.CIDR)
+			}
+			result.skip(entry.CIDR)
+			continue
+		}
+
+		result.add(networks, annotation)
+
+		if opts.maxEntries > 0 && len(result.networks) > opts.maxEntries {
+			return nil, fmt.Errorf("entry %d: blacklist exceeds the maximum of %d entries", i+1, opts.maxEntries)
+		}
+	}
+
+	return result, nil
+}
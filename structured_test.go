@@ -0,0 +1,219 @@
+package simpleblocklist
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// parsedEntries the networks and annotations of result, keyed by network, to compare parser output.
+func parsedEntries(result *parseResult) map[string]entryAnnotation {
+	entries := make(map[string]entryAnnotation, len(result.networks))
+	for _, network := range result.networks {
+		entries[network.String()] = result.annotations[network.String()]
+	}
+	return entries
+}
+
+func TestParseStructuredBlacklist(t *testing.T) {
+	expires := time.Date(2999, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	// Each format holds the same entries: an expired one, one with a reason and expiry, one with
+	// only an entry and an invalid one.
+	tests := []struct {
+		desc    string
+		format  string
+		content string
+	}{
+		{
+			desc:   "json",
+			format: blacklistFormatJSON,
+			content: `[
+  {"cidr": "192.0.2.0/24", "reason": "expired abuse report", "expires": "2001-01-01T00:00:00Z"},
+  {"cidr": "198.51.100.0/24", "reason": "credential stuffing, login endpoint", "expires": "2999-01-01T00:00:00Z"},
+  {"cidr": "203.0.113.5"},
+  {"cidr": "not-an-ip"}
+]`,
+		},
+		{
+			desc:   "toml",
+			format: blacklistFormatTOML,
+			content: `[[entries]]
+cidr = "192.0.2.0/24"
+reason = "expired abuse report"
+expires = 2001-01-01T00:00:00Z
+
+[[entries]]
+cidr = "198.51.100.0/24"
+reason = "credential stuffing, login endpoint"
+expires = 2999-01-01T00:00:00Z
+
+[[entries]]
+cidr = "203.0.113.5"
+
+[[entries]]
+cidr = "not-an-ip"
+`,
+		},
+		{
+			desc:   "csv",
+			format: blacklistFormatCSV,
+			content: `ip,reason,expiry
+# exported by the SOC
+192.0.2.0/24,expired abuse report,2001-01-01T00:00:00Z
+198.51.100.0/24,"credential stuffing, login endpoint",2999-01-01T00:00:00Z
+203.0.113.5
+not-an-ip
+`,
+		},
+	}
+
+	expected := map[string]entryAnnotation{
+		"198.51.100.0/24": {reason: "credential stuffing, login endpoint", expires: expires},
+		"203.0.113.5/32":  {},
+	}
+
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			result, err := parseBlacklistFile("blacklist", strings.NewReader(test.content), blacklistOptions{format: test.format})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if entries := parsedEntries(result); !reflect.DeepEqual(entries, expected) {
+				t.Errorf("got entries %+v, want %+v", entries, expected)
+			}
+			if result.expired != 1 {
+				t.Errorf("got %d expired entries, want 1", result.expired)
+			}
+			if result.skipped != 1 || !reflect.DeepEqual(result.skippedSample, []string{"not-an-ip"}) {
+				t.Errorf("got %d skipped entries %q, want the invalid entry", result.skipped, result.skippedSample)
+			}
+
+			if _, err := parseBlacklistFile("blacklist", strings.NewReader(test.content), blacklistOptions{format: test.format, strict: true}); err == nil || !strings.Contains(err.Error(), "not-an-ip") {
+				t.Errorf("expected the invalid entry to fail in strict mode, got %v", err)
+			}
+		})
+	}
+}
+
+func TestParseCSVBlacklist_Header(t *testing.T) {
+	tests := []struct {
+		desc     string
+		content  string
+		expected []string
+		skipped  []string
+	}{
+		{
+			desc:     "ip header",
+			content:  "ip,reason,expiry\n192.0.2.1,scanner,\n",
+			expected: []string{"192.0.2.1/32"},
+		},
+		{
+			desc:     "cidr header in upper case",
+			content:  "CIDR,Reason\n192.0.2.0/24,scanner\n",
+			expected: []string{"192.0.2.0/24"},
+		},
+		{
+			desc:     "network header with spaces",
+			content:  " Network , reason\n198.51.100.0/24,botnet\n",
+			expected: []string{"198.51.100.0/24"},
+		},
+		{
+			desc:     "header after a comment",
+			content:  "# exported by the SOC\nip,reason\n192.0.2.1,scanner\n",
+			expected: []string{"192.0.2.1/32"},
+		},
+		{
+			desc:     "no header",
+			content:  "192.0.2.1,scanner\n198.51.100.0/24\n",
+			expected: []string{"192.0.2.1/32", "198.51.100.0/24"},
+		},
+		{
+			desc:     "first column that only looks like text",
+			content:  "scanner,192.0.2.1\n198.51.100.0/24,botnet\n",
+			expected: []string{"198.51.100.0/24"},
+			skipped:  []string{"scanner"},
+		},
+		{
+			desc:     "header name in another column",
+			content:  "address,ip\n198.51.100.0/24,botnet\n",
+			expected: []string{"198.51.100.0/24"},
+			skipped:  []string{"address"},
+		},
+		{
+			desc:     "header after the first row",
+			content:  "192.0.2.1,scanner\nip,reason\n",
+			expected: []string{"192.0.2.1/32"},
+			skipped:  []string{"ip"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			result, err := parseCSVBlacklist(strings.NewReader(test.content), blacklistOptions{})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var networks []string
+			for _, network := range result.networks {
+				networks = append(networks, network.String())
+			}
+			if !reflect.DeepEqual(networks, test.expected) {
+				t.Errorf("got networks %q, want %q", networks, test.expected)
+			}
+			if !reflect.DeepEqual(result.skippedSample, test.skipped) {
+				t.Errorf("got skipped rows %q, want %q", result.skippedSample, test.skipped)
+			}
+
+			// A skipped row fails the load in strict mode, a header never does
+			_, err = parseCSVBlacklist(strings.NewReader(test.content), blacklistOptions{strict: true})
+			if (err != nil) != (len(test.skipped) != 0) {
+				t.Errorf("got error %v in strict mode, want one only if rows are skipped", err)
+			}
+		})
+	}
+}
+
+func TestParseStructuredBlacklist_Invalid(t *testing.T) {
+	tests := []struct {
+		desc    string
+		format  string
+		content string
+		strict  bool
+		err     string
+	}{
+		{desc: "malformed json", format: blacklistFormatJSON, content: `[{"cidr": "192.0.2.1"`, err: "invalid JSON blacklist"},
+		{desc: "json object", format: blacklistFormatJSON, content: `{"cidr": "192.0.2.1"}`, err: "invalid JSON blacklist"},
+		{desc: "invalid json expiry", format: blacklistFormatJSON, content: `[{"cidr": "192.0.2.1", "expires": "tomorrow"}]`, err: "invalid JSON blacklist"},
+		{desc: "malformed toml", format: blacklistFormatTOML, content: "[[entries]\ncidr = 192.0.2.1\n", err: "invalid TOML blacklist: line 1"},
+		{desc: "invalid toml expiry", format: blacklistFormatTOML, content: "[[entries]]\ncidr = \"192.0.2.1\"\nexpires = tomorrow\n", err: "line 3: invalid expires"},
+		{desc: "malformed csv", format: blacklistFormatCSV, content: "192.0.2.1,bad\"quote\n", err: "invalid CSV blacklist"},
+		{desc: "invalid csv expiry in strict mode", format: blacklistFormatCSV, content: "ip,reason,expiry\n192.0.2.1,scanner,tomorrow\n", strict: true, err: "line 2: invalid expiry"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			result, err := parseBlacklistFile("blacklist", strings.NewReader(test.content), blacklistOptions{format: test.format, strict: test.strict})
+			if err == nil || !strings.Contains(err.Error(), test.err) {
+				t.Errorf("got result %+v and error %v, want an error containing %q", result, err, test.err)
+			}
+		})
+	}
+}
+
+func TestParseCSVBlacklist_InvalidExpiry(t *testing.T) {
+	result, err := parseCSVBlacklist(strings.NewReader("192.0.2.1,scanner,tomorrow\n198.51.100.0/24\n"), blacklistOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(result.networks) != 1 || result.networks[0].String() != "198.51.100.0/24" {
+		t.Errorf("got networks %v, want only the row with a valid expiry", result.networks)
+	}
+	if !reflect.DeepEqual(result.skippedSample, []string{"192.0.2.1,scanner,tomorrow"}) {
+		t.Errorf("got skipped rows %q, want the row with an invalid expiry", result.skippedSample)
+	}
+}
@@ -0,0 +1,98 @@
+package simpleblocklist
+
+import (
+	"fmt"
+	"net"
+)
+
+const (
+	tierActionDeny = "deny"
+	tierActionLog  = "log"
+)
+
+// TieredList a blacklist with its own response, e.g. a soft list answered with 429 Too Many Requests
+// or only logged, next to a hard list answered with 403 Forbidden.
+type TieredList struct {
+	Path string `yaml:"path"`
+	// StatusCode the status code of denied requests, the denied request status code if 0.
+	StatusCode int `yaml:"statusCode"`
+	// Action what happens to requests from listed IPs, "deny" (default) or "log".
+	Action string `yaml:"action"`
+}
+
+// listTier a loaded tiered list.
+type listTier struct {
+	path       string
+	statusCode int
+	logOnly    bool
+	networks   *ipTrie
+	exceptions *ipTrie
+}
+
+// validate reports the problems of the tiered list at index i.
+func (l TieredList) validate(i int) []string {
+	var problems []string
+	if len(l.Path) == 0 {
+		problems = append(problems, fmt.Sprintf("tiered list %d has no path", i+1))
+	}
+	if l.StatusCode != 0 {
+		if err := validateDeniedStatusCode(l.StatusCode); err != nil {
+			problems = append(problems, fmt.Sprintf("tiered list %d: %v", i+1, err))
+		}
+	}
+	switch l.Action {
+	case "", tierActionDeny, tierActionLog:
+	default:
+		problems = append(problems, fmt.Sprintf("tiered list %d: invalid action %q, expected %q or %q", i+1, l.Action, tierActionDeny, tierActionLog))
+	}
+	return problems
+}
+
+// loadTiers loads the tiered lists, keeping their order.
+func loadTiers(lists []TieredList, opts blacklistOptions, logger *logger) ([]*listTier, error) {
+	tiers := make([]*listTier, 0, len(lists))
+	for _, list := range lists {
+		result, err := loadBlacklists([]string{list.Path}, opts, logger)
+		if err != nil {
+			return nil, err
+		}
+		tiers = append(tiers, &listTier{
+			path:       list.Path,
+			statusCode: list.StatusCode,
+			logOnly:    list.Action == tierActionLog,
+			networks:   newIPTrie(result.networks),
+			exceptions: newIPTrie(result.exceptions),
+		})
+	}
+	return tiers, nil
+}
+
+// matchTiers returns the decision of the first tiered list that matches ip, or nil if none does.
+// The caller must hold a.mu.
+func (a *SimpleBlocklist) matchTiers(ip net.IP) *blockDecision {
+	for _, tier := range a.tiers {
+		network := tier.networks.match(ip)
+		if network == nil || tier.exceptions.lookup(ip) {
+			continue
+		}
+		return &blockDecision{
+			matched:    network.String(),
+			code:       "list:" + network.String(),
+			reason:     "IP is listed in " + tier.path,
+			network:    network,
+			statusCode: tier.statusCode,
+			logOnly:    tier.logOnly,
+			fields:     logFields{"matched_network": network.String(), "list": tier.path},
+		}
+	}
+	return nil
+}
+
+// flag logs a request from an IP on a log-only tiered list, which is then checked as usual.
+func (a *SimpleBlocklist) flag(ip string, decision *blockDecision) {
+	fields := logFields{"ip": a.logIP(ip), "action": "log", "matched": decision.matched}
+	for key, value := range decision.fields {
+		fields[key] = value
+	}
+	a.logger.infof(fields, "%s: request flagged [%s] matched [%s] - %s", a.name, a.logIP(ip), decision.matched, decision.reason)
+}
@@ -0,0 +1,72 @@
+package simpleblocklist_test
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/LucaNori/traefik-simpleblocklist"
+)
+
+func TestSimpleBlocklist_TieredLists(t *testing.T) {
+	var buf bytes.Buffer
+	defer simpleblocklist.SetLogOutput(&buf)()
+
+	cfg := simpleblocklist.CreateConfig()
+	cfg.BlacklistPath = createBlacklistFile(t, "192.0.2.1\n")
+	cfg.TieredLists = []simpleblocklist.TieredList{
+		{Path: createBlacklistFile(t, "198.51.100.0/24\n")},
+		{Path: createBlacklistFile(t, "198.51.100.7\n203.0.113.0/24\n!203.0.113.99\n"), StatusCode: http.StatusTooManyRequests},
+		{Path: createBlacklistFile(t, "203.0.113.99\n2001:db8::/32\n"), Action: "log"},
+	}
+
+	ctx := context.Background()
+	next := http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		rw.WriteHeader(http.StatusOK)
+	})
+
+	handler, err := simpleblocklist.New(ctx, next, cfg, "simpleblocklist")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		desc           string
+		ip             string
+		expectedStatus int
+		expectedLog    string
+	}{
+		{desc: "main blacklist", ip: "192.0.2.1", expectedStatus: 403},
+		{desc: "hard list", ip: "198.51.100.1", expectedStatus: 403},
+		{desc: "hard list first", ip: "198.51.100.7", expectedStatus: 403},
+		{desc: "soft list", ip: "203.0.113.5", expectedStatus: 429},
+		{desc: "soft list exception, log-only list", ip: "203.0.113.99", expectedStatus: 200, expectedLog: "request flagged [203.0.113.99]"},
+		{desc: "log-only list", ip: "2001:db8::1", expectedStatus: 200, expectedLog: "request flagged [2001:db8::1]"},
+		{desc: "not listed", ip: "192.0.2.200", expectedStatus: 200},
+	}
+
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			buf.Reset()
+
+			recorder := httptest.NewRecorder()
+			req, err := http.NewRequestWithContext(ctx, http.MethodGet, "http://localhost", nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			req.Header.Set("X-Forwarded-For", test.ip)
+
+			handler.ServeHTTP(recorder, req)
+
+			if recorder.Code != test.expectedStatus {
+				t.Errorf("got status code %d, want %d", recorder.Code, test.expectedStatus)
+			}
+			if !strings.Contains(buf.String(), test.expectedLog) {
+				t.Errorf("expected %q in the log, got %q", test.expectedLog, buf.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,52 @@
+package simpleblocklist
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// parseCertFingerprint normalizes a SHA-256 certificate fingerprint to lowercase hex. Colons and
+// spaces, as printed by "openssl x509 -fingerprint -sha256", are ignored.
+func parseCertFingerprint(s string) (string, error) {
+	fingerprint := strings.ToLower(strings.NewReplacer(":", "", " ", "").Replace(s))
+	decoded, err := hex.DecodeString(fingerprint)
+	if err != nil || len(decoded) != sha256.Size {
+		return "", fmt.Errorf("invalid certificate fingerprint %q supplied, expected a SHA-256 hex digest", s)
+	}
+	return fingerprint, nil
+}
+
+// newBlockedCertFingerprints returns the set of blocked client certificate fingerprints of config.
+func newBlockedCertFingerprints(config *Config, logger *logger) map[string]struct{} {
+	if len(config.BlockedCertFingerprints) == 0 {
+		return nil
+	}
+
+	fingerprints := make(map[string]struct{}, len(config.BlockedCertFingerprints))
+	for _, s := range config.BlockedCertFingerprints {
+		// Validate already checked the fingerprints
+		fingerprint, _ := parseCertFingerprint(s)
+		fingerprints[fingerprint] = struct{}{}
+	}
+	logger.infof(nil, "Blocked client certificates: %d", len(fingerprints))
+	return fingerprints
+}
+
+// blockedCertificate returns the SHA-256 fingerprint of the TLS client certificate of req if it is
+// blocked, or an empty string if it isn't or the request has no client certificate.
+func (a *SimpleBlocklist) blockedCertificate(req *http.Request) string {
+	if len(a.blockedCertFingerprints) == 0 || req.TLS == nil || len(req.TLS.PeerCertificates) == 0 {
+		return ""
+	}
+
+	// Only the leaf certificate identifies the client, the others are intermediates
+	sum := sha256.Sum256(req.TLS.PeerCertificates[0].Raw)
+	fingerprint := hex.EncodeToString(sum[:])
+	if _, ok := a.blockedCertFingerprints[fingerprint]; ok {
+		return fingerprint
+	}
+	return ""
+}
@@ -0,0 +1,114 @@
+package simpleblocklist_test
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/LucaNori/traefik-simpleblocklist"
+)
+
+func TestSimpleBlocklist_BlockedCertFingerprints(t *testing.T) {
+	blockedCert := &x509.Certificate{Raw: []byte("blocked client certificate")}
+	allowedCert := &x509.Certificate{Raw: []byte("allowed client certificate")}
+
+	// Colon-separated uppercase, as printed by openssl
+	sum := sha256.Sum256(blockedCert.Raw)
+	var parts []string
+	for _, b := range sum {
+		parts = append(parts, fmt.Sprintf("%02X", b))
+	}
+
+	cfg := simpleblocklist.CreateConfig()
+	cfg.BlacklistPath = createBlacklistFile(t, "192.0.2.1\n")
+	cfg.BlockedCertFingerprints = []string{strings.Join(parts, ":")}
+
+	ctx := context.Background()
+	next := http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		rw.WriteHeader(http.StatusOK)
+	})
+
+	handler, err := simpleblocklist.New(ctx, next, cfg, "simpleblocklist")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		desc           string
+		remoteAddr     string
+		tls            *tls.ConnectionState
+		expectedStatus int
+	}{
+		{
+			desc:           "blocked certificate",
+			remoteAddr:     "203.0.113.1:1234",
+			tls:            &tls.ConnectionState{PeerCertificates: []*x509.Certificate{blockedCert}},
+			expectedStatus: 403,
+		},
+		{
+			desc:           "blocked certificate from a local IP",
+			remoteAddr:     "10.0.0.1:1234",
+			tls:            &tls.ConnectionState{PeerCertificates: []*x509.Certificate{blockedCert}},
+			expectedStatus: 403,
+		},
+		{
+			desc:           "blocked certificate as intermediate",
+			remoteAddr:     "203.0.113.1:1234",
+			tls:            &tls.ConnectionState{PeerCertificates: []*x509.Certificate{allowedCert, blockedCert}},
+			expectedStatus: 200,
+		},
+		{
+			desc:           "allowed certificate from a blacklisted IP",
+			remoteAddr:     "192.0.2.1:1234",
+			tls:            &tls.ConnectionState{PeerCertificates: []*x509.Certificate{allowedCert}},
+			expectedStatus: 403,
+		},
+		{
+			desc:           "TLS without client certificate",
+			remoteAddr:     "203.0.113.1:1234",
+			tls:            &tls.ConnectionState{},
+			expectedStatus: 200,
+		},
+		{
+			desc:           "plain HTTP",
+			remoteAddr:     "203.0.113.1:1234",
+			expectedStatus: 200,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			recorder := httptest.NewRecorder()
+			req, err := http.NewRequestWithContext(ctx, http.MethodGet, "https://localhost", nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			req.RemoteAddr = test.remoteAddr
+			req.TLS = test.tls
+
+			handler.ServeHTTP(recorder, req)
+
+			if recorder.Code != test.expectedStatus {
+				t.Errorf("got status code %d, want %d", recorder.Code, test.expectedStatus)
+			}
+		})
+	}
+}
+
+func TestSimpleBlocklist_InvalidCertFingerprint(t *testing.T) {
+	cfg := simpleblocklist.CreateConfig()
+	cfg.BlacklistPath = createBlacklistFile(t, "192.0.2.1\n")
+	cfg.BlockedCertFingerprints = []string{"AB:CD:EF"}
+
+	next := http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {})
+
+	if _, err := simpleblocklist.New(context.Background(), next, cfg, "simpleblocklist"); err == nil {
+		t.Error("expected error for a truncated certificate fingerprint")
+	}
+}
@@ -0,0 +1,235 @@
+package simpleblocklist
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// tomlEntriesTable the header of the array of tables holding the entries of a TOML blacklist.
+const tomlEntriesTable = "entries"
+
+// parseTOMLEntries reads the entries of a TOML blacklist. Only the subset of TOML needed by the
+// format is supported: "[[entries]]" tables of "key = value" pairs, where values are single-line
+// basic or literal strings, or offset date-times for "expires", which may also be quoted. Comments,
+// blank lines and the keys and tables other than "[[entries]]" are ignored, as long as their values
+// are of the supported kinds, so a file carrying extra metadata still loads.
+func parseTOMLEntries(r io.Reader) ([]structuredEntry, error) {
+	p := &tomlParser{keys: map[string]bool{}}
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if len(text) == 0 || text[0] == '#' {
+			continue
+		}
+
+		var err error
+		if text[0] == '[' {
+			err = p.table(text)
+		} else {
+			err = p.keyValue(text)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", line, err)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return p.entries, nil
+}
+
+// tomlParser the state of parseTOMLEntries carried from one line to the next.
+type tomlParser struct {
+	entries []structuredEntry
+	// entry the entry whose table is being read, nil outside an "[[entries]]" table.
+	entry *structuredEntry
+	// keys the keys of the table being read, which can't be repeated.
+	keys map[string]bool
+}
+
+// table starts the table whose header is text.
+func (p *tomlParser) table(text string) error {
+	table, array, err := parseTOMLTableHeader(text)
+	if err != nil {
+		return err
+	}
+	if table == tomlEntriesTable && !array {
+		return fmt.Errorf("entries must be written as [[entries]] tables")
+	}
+
+	p.entry = nil
+	p.keys = map[string]bool{}
+	if table == tomlEntriesTable {
+		p.entries = append(p.entries, structuredEntry{})
+		p.entry = &p.entries[len(p.entries)-1]
+	}
+	return nil
+}
+
+// keyValue sets the field of the current entry the "key = value" line text holds, if any.
+func (p *tomlParser) keyValue(text string) error {
+	key, value, err := parseTOMLKeyValue(text)
+	if err != nil {
+		return err
+	}
+	if p.keys[key] {
+		return fmt.Errorf("duplicate key %q", key)
+	}
+	p.keys[key] = true
+	if p.entry == nil {
+		return nil
+	}
+
+	switch key {
+	case "cidr", "reason":
+		if !value.quoted {
+			return fmt.Errorf("%s must be a string", key)
+		}
+		if key == "cidr" {
+			p.entry.CIDR = value.text
+		} else {
+			p.entry.Reason = value.text
+		}
+	case "expires":
+		expires, err := parseTOMLDateTime(value.text)
+		if err != nil {
+			return fmt.Errorf("invalid expires: %v", err)
+		}
+		p.entry.Expires = &expires
+	}
+	return nil
+}
+
+// parseTOMLTableHeader parses a "[table]" or "[[table]]" line, reporting whether it is an array of tables.
+func parseTOMLTableHeader(text string) (table string, array bool, err error) {
+	open, closing := "[", "]"
+	if strings.HasPrefix(text, "[[") {
+		open, closing, array = "[[", "]]", true
+	}
+
+	end := strings.Index(text, closing)
+	if end < 0 {
+		return "", false, fmt.Errorf("unterminated table header %q", text)
+	}
+	if rest := strings.TrimSpace(text[end+len(closing):]); len(rest) != 0 && rest[0] != '#' {
+		return "", false, fmt.Errorf("unexpected %q after table header", rest)
+	}
+
+	table = strings.TrimSpace(text[len(open):end])
+	if len(table) == 0 {
+		return "", false, fmt.Errorf("empty table header %q", text)
+	}
+	return table, array, nil
+}
+
+// tomlValue a scalar TOML value.
+type tomlValue struct {
+	// text the unescaped content of a string, or the raw text of any other value.
+	text string
+	// quoted whether the value is a string.
+	quoted bool
+}
+
+// parseTOMLKeyValue parses a "key = value" line, followed by an optional comment.
+func parseTOMLKeyValue(text string) (string, tomlValue, error) {
+	i := strings.IndexByte(text, '=')
+	if i < 0 {
+		return "", tomlValue{}, fmt.Errorf("expected key = value, got %q", text)
+	}
+
+	key, err := parseTOMLKey(strings.TrimSpace(text[:i]))
+	if err != nil {
+		return "", tomlValue{}, err
+	}
+
+	value, rest, err := parseTOMLValue(strings.TrimSpace(text[i+1:]))
+	if err != nil {
+		return "", tomlValue{}, fmt.Errorf("key %q: %v", key, err)
+	}
+	if rest = strings.TrimSpace(rest); len(rest) != 0 && rest[0] != '#' {
+		return "", tomlValue{}, fmt.Errorf("key %q: unexpected %q after the value", key, rest)
+	}
+	return key, value, nil
+}
+
+// parseTOMLKey parses a bare or quoted key.
+func parseTOMLKey(key string) (string, error) {
+	if len(key) > 1 && (key[0] == '"' || key[0] == '\'') {
+		unquoted, rest, err := parseTOMLString(key)
+		if err != nil || len(rest) != 0 {
+			return "", fmt.Errorf("invalid key %s", key)
+		}
+		return unquoted, nil
+	}
+	if len(key) == 0 || strings.ContainsAny(key, " \t\"'") {
+		return "", fmt.Errorf("invalid key %q", key)
+	}
+	return key, nil
+}
+
+// parseTOMLValue parses the scalar value at the start of raw, returning it and the text after it.
+func parseTOMLValue(raw string) (tomlValue, string, error) {
+	switch {
+	case len(raw) == 0:
+		return tomlValue{}, "", fmt.Errorf("missing value")
+	case raw[0] == '"' || raw[0] == '\'':
+		unquoted, rest, err := parseTOMLString(raw)
+		if err != nil {
+			return tomlValue{}, "", err
+		}
+		return tomlValue{text: unquoted, quoted: true}, rest, nil
+	case raw[0] == '[' || raw[0] == '{':
+		return tomlValue{}, "", fmt.Errorf("arrays and inline tables are not supported")
+	default:
+		end := strings.IndexByte(raw, '#')
+		if end < 0 {
+			end = len(raw)
+		}
+		return tomlValue{text: strings.TrimSpace(raw[:end])}, raw[end:], nil
+	}
+}
+
+// parseTOMLString parses the single-line basic ("...") or literal ('...') string at the start of
+// text, returning its content and the text after it.
+func parseTOMLString(text string) (value, rest string, err error) {
+	if strings.HasPrefix(text, `"""`) || strings.HasPrefix(text, "'''") {
+		return "", "", fmt.Errorf("multi-line strings are not supported")
+	}
+
+	if text[0] == '\'' {
+		end := strings.IndexByte(text[1:], '\'')
+		if end < 0 {
+			return "", "", fmt.Errorf("unterminated string %s", text)
+		}
+		return text[1 : end+1], text[end+2:], nil
+	}
+
+	// The escapes of Go strings are a superset of those of TOML basic strings.
+	for i := 1; i < len(text); i++ {
+		switch text[i] {
+		case '\\':
+			i++
+		case '"':
+			value, err := strconv.Unquote(text[:i+1])
+			if err != nil {
+				return "", "", fmt.Errorf("invalid string %s", text[:i+1])
+			}
+			return value, text[i+1:], nil
+		}
+	}
+	return "", "", fmt.Errorf("unterminated string %s", text)
+}
+
+// parseTOMLDateTime parses an offset date-time, whose date and time may be separated by a space as
+// TOML allows.
+func parseTOMLDateTime(text string) (time.Time, error) {
+	if len(text) > 10 && text[10] == ' ' {
+		text = text[:10] + "T" + text[11:]
+	}
+	return time.Parse(time.RFC3339, text)
+}
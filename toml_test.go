@@ -0,0 +1,165 @@
+package simpleblocklist
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseTOMLEntries(t *testing.T) {
+	expires := time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		desc     string
+		input    string
+		expected []structuredEntry
+	}{
+		{
+			desc: "entries with every key",
+			input: `# exported by the SOC
+[[entries]]
+cidr = "192.0.2.0/24"
+reason = "credential stuffing"
+expires = 2030-01-01T00:00:00Z
+
+[[entries]]
+cidr = "2001:db8::/32"
+`,
+			expected: []structuredEntry{
+				{CIDR: "192.0.2.0/24", Reason: "credential stuffing", Expires: &expires},
+				{CIDR: "2001:db8::/32"},
+			},
+		},
+		{
+			desc: "indentation, spacing and comments",
+			input: `  [[ entries ]] # first
+	cidr="192.0.2.1"   # scanner
+	reason = 'literal \n string' # kept as is
+`,
+			expected: []structuredEntry{{CIDR: "192.0.2.1", Reason: `literal \n string`}},
+		},
+		{
+			desc:     "escapes in basic strings",
+			input:    "[[entries]]\ncidr = \"192.0.2.1\"\nreason = \"tab\\there \\\"quoted\\\" \\u00e9 # not a comment\"\n",
+			expected: []structuredEntry{{CIDR: "192.0.2.1", Reason: "tab\there \"quoted\" é # not a comment"}},
+		},
+		{
+			desc:     "quoted keys",
+			input:    "[[entries]]\n\"cidr\" = \"192.0.2.1\"\n'reason' = \"scanner\"\n",
+			expected: []structuredEntry{{CIDR: "192.0.2.1", Reason: "scanner"}},
+		},
+		{
+			desc:     "space separated expiry",
+			input:    "[[entries]]\ncidr = \"192.0.2.1\"\nexpires = 2030-01-01 00:00:00Z\n",
+			expected: []structuredEntry{{CIDR: "192.0.2.1", Expires: &expires}},
+		},
+		{
+			desc:     "quoted expiry",
+			input:    "[[entries]]\ncidr = \"192.0.2.1\"\nexpires = \"2030-01-01T01:00:00+01:00\"\n",
+			expected: []structuredEntry{{CIDR: "192.0.2.1", Expires: &expires}},
+		},
+		{
+			desc: "other keys and tables are ignored",
+			input: `title = "blocklist"
+version = 3
+
+[meta]
+updated = 2024-05-01T00:00:00Z
+
+[[entries]]
+cidr = "192.0.2.1"
+added_by = "soc"
+confidence = 90
+
+[[sources]]
+cidr = "198.51.100.0/24"
+`,
+			expected: []structuredEntry{{CIDR: "192.0.2.1"}},
+		},
+		{
+			desc:  "empty document",
+			input: "# nothing yet\n",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			entries, err := parseTOMLEntries(strings.NewReader(test.input))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if len(entries) != len(test.expected) {
+				t.Fatalf("got %d entries, want %d: %+v", len(entries), len(test.expected), entries)
+			}
+			for i, entry := range entries {
+				expected := test.expected[i]
+				if entry.CIDR != expected.CIDR || entry.Reason != expected.Reason {
+					t.Errorf("entry %d: got %q %q, want %q %q", i, entry.CIDR, entry.Reason, expected.CIDR, expected.Reason)
+				}
+				if (entry.Expires == nil) != (expected.Expires == nil) ||
+					entry.Expires != nil && !entry.Expires.Equal(*expected.Expires) {
+					t.Errorf("entry %d: got expiry %v, want %v", i, entry.Expires, expected.Expires)
+				}
+			}
+		})
+	}
+}
+
+func TestParseTOMLEntries_Invalid(t *testing.T) {
+	tests := []struct {
+		desc  string
+		input string
+		err   string
+	}{
+		{desc: "unterminated table header", input: "[[entries]\ncidr = \"192.0.2.1\"\n", err: "line 1: unterminated table header"},
+		{desc: "entries as a single table", input: "[entries]\ncidr = \"192.0.2.1\"\n", err: "line 1: entries must be written as [[entries]] tables"},
+		{desc: "unquoted cidr", input: "[[entries]]\ncidr = 192.0.2.1\n", err: "line 2: cidr must be a string"},
+		{desc: "missing value", input: "[[entries]]\ncidr =\n", err: "line 2: key \"cidr\": missing value"},
+		{desc: "missing equal sign", input: "[[entries]]\ncidr \"192.0.2.1\"\n", err: "line 2: expected key = value"},
+		{desc: "unterminated string", input: "[[entries]]\ncidr = \"192.0.2.1\n", err: "line 2: key \"cidr\": unterminated string"},
+		{desc: "text after the value", input: "[[entries]]\ncidr = \"192.0.2.1\" \"192.0.2.2\"\n", err: "line 2: key \"cidr\": unexpected"},
+		{desc: "duplicate key", input: "[[entries]]\ncidr = \"192.0.2.1\"\n\ncidr = \"192.0.2.2\"\n", err: "line 4: duplicate key \"cidr\""},
+		{desc: "multi-line string", input: "[[entries]]\nreason = \"\"\"\nscanner\n\"\"\"\n", err: "line 2: key \"reason\": multi-line strings are not supported"},
+		{desc: "array value", input: "[[entries]]\ncidr = [\"192.0.2.1\"]\n", err: "line 2: key \"cidr\": arrays and inline tables are not supported"},
+		{desc: "local date-time", input: "[[entries]]\nexpires = 2030-01-01T00:00:00\n", err: "line 2: invalid expires"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			entries, err := parseTOMLEntries(strings.NewReader(test.input))
+			if err == nil || !strings.Contains(err.Error(), test.err) {
+				t.Errorf("got entries %+v and error %v, want an error containing %q", entries, err, test.err)
+			}
+		})
+	}
+}
+
+func TestParseTOMLEntries_SameAsJSON(t *testing.T) {
+	toml := `[[entries]]
+cidr = "192.0.2.0/24"
+reason = "credential stuffing"
+expires = 2999-01-01T00:00:00Z
+
+[[entries]]
+cidr = "2001:db8::/32"
+`
+	json := `[
+  {"cidr": "192.0.2.0/24", "reason": "credential stuffing", "expires": "2999-01-01T00:00:00Z"},
+  {"cidr": "2001:db8::/32"}
+]`
+
+	fromTOML, err := parseBlacklistFile("blacklist.toml", strings.NewReader(toml), blacklistOptions{format: blacklistFormatTOML})
+	if err != nil {
+		t.Fatal(err)
+	}
+	fromJSON, err := parseBlacklistFile("blacklist.json", strings.NewReader(json), blacklistOptions{format: blacklistFormatJSON})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(fromTOML, fromJSON) {
+		t.Errorf("got %+v from TOML, want the same as from JSON %+v", fromTOML, fromJSON)
+	}
+}
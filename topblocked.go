@@ -0,0 +1,79 @@
+package simpleblocklist
+
+import (
+	"sort"
+	"sync"
+)
+
+const (
+	defaultStatusTopBlockedIPs = 10
+	// maxTrackedBlockedIPs bounds the number of IPs whose blocks are counted.
+	maxTrackedBlockedIPs = 1000
+)
+
+// blockedIPCount the number of denied requests of one IP, as reported on the status path.
+type blockedIPCount struct {
+	IP    string `json:"ip"`
+	Count uint64 `json:"count"`
+}
+
+// blockCounter counts denied requests per IP. Once it tracks maxTrackedBlockedIPs IPs, the least
+// blocked one is evicted to make room for a new one, so the memory used stays bounded.
+type blockCounter struct {
+	mu     sync.Mutex
+	counts map[string]uint64
+}
+
+// newBlockCounter returns the counter of the top blocked IPs reported on the status path of config,
+// or nil if they aren't reported.
+func newBlockCounter(config *Config) *blockCounter {
+	if len(config.StatusPath) == 0 || config.StatusTopBlockedIPs <= 0 {
+		return nil
+	}
+	return &blockCounter{counts: make(map[string]uint64)}
+}
+
+// add counts a denied request from ip.
+func (c *blockCounter) add(ip string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if _, ok := c.counts[ip]; !ok && len(c.counts) >= maxTrackedBlockedIPs {
+		c.evictLeastBlocked()
+	}
+	c.counts[ip]++
+}
+
+// evictLeastBlocked removes the IP with the fewest denied requests. The caller must hold c.mu.
+func (c *blockCounter) evictLeastBlocked() {
+	var least string
+	var leastCount uint64
+	for ip, count := range c.counts {
+		if least == "" || count < leastCount {
+			least, leastCount = ip, count
+		}
+	}
+	delete(c.counts, least)
+}
+
+// top returns the n most blocked IPs, most blocked first. IPs blocked equally often are sorted
+// by IP so the output is stable.
+func (c *blockCounter) top(n int) []blockedIPCount {
+	c.mu.Lock()
+	counts := make([]blockedIPCount, 0, len(c.counts))
+	for ip, count := range c.counts {
+		counts = append(counts, blockedIPCount{IP: ip, Count: count})
+	}
+	c.mu.Unlock()
+
+	sort.Slice(counts, func(i, j int) bool {
+		if counts[i].Count != counts[j].Count {
+			return counts[i].Count > counts[j].Count
+		}
+		return counts[i].IP < counts[j].IP
+	})
+	if len(counts) > n {
+		counts = counts[:n]
+	}
+	return counts
+}
@@ -0,0 +1,86 @@
+package simpleblocklist
+
+import "net"
+
+// trieNode is a node of a binary prefix trie, one level per address bit.
+type trieNode struct {
+	children [2]*trieNode
+	network  *net.IPNet
+}
+
+// ipTrie indexes networks by prefix so a lookup costs at most one step per address bit,
+// regardless of how many networks are stored.
+type ipTrie struct {
+	v4 *trieNode
+	v6 *trieNode
+}
+
+func newIPTrie(networks []*net.IPNet) *ipTrie {
+	t := &ipTrie{v4: &trieNode{}, v6: &trieNode{}}
+	for _, network := range networks {
+		t.insert(network)
+	}
+	return t
+}
+
+func (t *ipTrie) insert(network *net.IPNet) {
+	ones, bits := network.Mask.Size()
+
+	var node *trieNode
+	var ip net.IP
+	switch bits {
+	case 8 * net.IPv4len:
+		node, ip = t.v4, network.IP.To4()
+	case 8 * net.IPv6len:
+		node, ip = t.v6, network.IP.To16()
+	}
+	if node == nil || ip == nil {
+		return
+	}
+
+	for i := 0; i < ones; i++ {
+		bit := ipBit(ip, i)
+		if node.children[bit] == nil {
+			node.children[bit] = &trieNode{}
+		}
+		node = node.children[bit]
+	}
+	node.network = network
+}
+
+// lookup reports whether ip is contained in any indexed network.
+func (t *ipTrie) lookup(ip net.IP) bool {
+	return t.match(ip) != nil
+}
+
+// match returns the shortest indexed network containing ip, or nil if there is none.
+func (t *ipTrie) match(ip net.IP) *net.IPNet {
+	return t.matchWhere(ip, nil)
+}
+
+// matchWhere returns the shortest indexed network containing ip for which accept returns true,
+// or nil if there is none. A nil accept accepts every network.
+func (t *ipTrie) matchWhere(ip net.IP, accept func(*net.IPNet) bool) *net.IPNet {
+	node := t.v6
+	if ip4 := ip.To4(); ip4 != nil {
+		node, ip = t.v4, ip4
+	} else if ip = ip.To16(); ip == nil {
+		return nil
+	}
+
+	for i := 0; node != nil; i++ {
+		if node.network != nil && (accept == nil || accept(node.network)) {
+			return node.network
+		}
+		if i == 8*len(ip) {
+			return nil
+		}
+		node = node.children[ipBit(ip, i)]
+	}
+
+	return nil
+}
+
+func ipBit(ip net.IP, i int) byte {
+	return ip[i/8] >> (7 - uint(i%8)) & 1
+}
@@ -0,0 +1,139 @@
+package simpleblocklist
+
+import (
+	"math/rand"
+	"net"
+	"testing"
+)
+
+func TestIPTrie_MatchesLinearScan(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	networks := randomNetworks(rnd, 1000)
+	trie := newIPTrie(networks)
+
+	for i := 0; i < 10000; i++ {
+		ip := randomIP(rnd)
+		if got, want := trie.lookup(ip), linearLookup(networks, ip); got != want {
+			t.Fatalf("lookup(%s) = %t, linear scan = %t", ip, got, want)
+		}
+	}
+
+	// Every network must match its own first address.
+	for _, network := range networks {
+		if !trie.lookup(network.IP) {
+			t.Fatalf("lookup(%s) = false, want true for %s", network.IP, network)
+		}
+	}
+}
+
+func TestIPTrie_Lookup(t *testing.T) {
+	var networks []*net.IPNet
+	for _, cidr := range []string{"192.0.2.0/24", "198.51.100.7/32", "2001:db8::/32", "0.0.0.0/0"} {
+		_, network, err := net.ParseCIDR(cidr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		networks = append(networks, network)
+	}
+	trie := newIPTrie(networks[:3])
+
+	tests := []struct {
+		ip       string
+		expected bool
+	}{
+		{ip: "192.0.2.1", expected: true},
+		{ip: "192.0.3.1", expected: false},
+		{ip: "198.51.100.7", expected: true},
+		{ip: "198.51.100.8", expected: false},
+		{ip: "2001:db8::1", expected: true},
+		{ip: "2001:db9::1", expected: false},
+		{ip: "::ffff:192.0.2.1", expected: true},
+	}
+
+	for _, test := range tests {
+		if got := trie.lookup(net.ParseIP(test.ip)); got != test.expected {
+			t.Errorf("lookup(%s) = %t, want %t", test.ip, got, test.expected)
+		}
+	}
+
+	if !newIPTrie(networks[3:]).lookup(net.ParseIP("203.0.113.1")) {
+		t.Error("expected 0.0.0.0/0 to match every IPv4 address")
+	}
+}
+
+func BenchmarkLookup(b *testing.B) {
+	rnd := rand.New(rand.NewSource(1))
+	networks := randomNetworks(rnd, 100000)
+	trie := newIPTrie(networks)
+
+	ips := make([]net.IP, 1024)
+	for i := range ips {
+		ips[i] = randomIP(rnd)
+	}
+
+	b.Run("linear", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			linearLookup(networks, ips[i%len(ips)])
+		}
+	})
+
+	b.Run("trie", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			trie.lookup(ips[i%len(ips)])
+		}
+	})
+}
+
+func linearLookup(networks []*net.IPNet, ip net.IP) bool {
+	for _, network := range networks {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+func randomNetworks(rnd *rand.Rand, n int) []*net.IPNet {
+	networks := make([]*net.IPNet, 0, n)
+	for i := 0; i < n; i++ {
+		ip := randomIP(rnd)
+		bits := 8 * len(ip)
+		// Keep prefixes long enough that most random addresses don't match.
+		mask := net.CIDRMask(bits/2+rnd.Intn(bits/2+1), bits)
+		networks = append(networks, &net.IPNet{IP: ip.Mask(mask), Mask: mask})
+	}
+	return networks
+}
+
+func randomIP(rnd *rand.Rand) net.IP {
+	size := net.IPv4len
+	if rnd.Intn(4) == 0 {
+		size = net.IPv6len
+	}
+	ip := make(net.IP, size)
+	rnd.Read(ip)
+	return ip
+}
+
+func TestIPTrie_MatchWhere(t *testing.T) {
+	var networks []*net.IPNet
+	for _, cidr := range []string{"192.0.0.0/16", "192.0.2.0/24"} {
+		_, network, err := net.ParseCIDR(cidr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		networks = append(networks, network)
+	}
+	trie := newIPTrie(networks)
+
+	skipShortest := func(network *net.IPNet) bool {
+		return network.String() != "192.0.0.0/16"
+	}
+
+	if got := trie.matchWhere(net.ParseIP("192.0.2.1"), skipShortest); got == nil || got.String() != "192.0.2.0/24" {
+		t.Errorf("matchWhere(192.0.2.1) = %v, want 192.0.2.0/24", got)
+	}
+	if got := trie.matchWhere(net.ParseIP("192.0.3.1"), skipShortest); got != nil {
+		t.Errorf("matchWhere(192.0.3.1) = %v, want nil", got)
+	}
+}
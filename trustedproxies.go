@@ -0,0 +1,59 @@
+package simpleblocklist
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// parseNetworks parses the IPs and CIDR ranges of entries, which Validate already checked.
+func parseNetworks(entries []string) []*net.IPNet {
+	var networks []*net.IPNet
+	for _, entry := range entries {
+		networks = append(networks, parseNetwork(strings.TrimSpace(entry)))
+	}
+	return networks
+}
+
+// collectTrustedIP returns the client IP behind the trusted proxies. X-Forwarded-For is only
+// honored when RemoteAddr is a trusted proxy, and is then walked from right to left, skipping the
+// trusted proxies, so the nearest untrusted hop is the client: the entries left of it were set by
+// the client and can't be trusted. If every entry is a trusted proxy, or an entry is not a valid
+// IP, the last trusted hop is the client.
+func (a *SimpleBlocklist) collectTrustedIP(req *http.Request) ([]string, error) {
+	client := remoteAddrIP(req)
+	if ip := parseIP(client); ip == nil || !a.isTrustedProxy(ip) {
+		return []string{client}, nil
+	}
+
+	value := req.Header.Get(xForwardedFor)
+	if a.maxForwardedForEntries > 0 && strings.Count(value, ",") >= a.maxForwardedForEntries {
+		return nil, fmt.Errorf("%s header has more than %d entries", xForwardedFor, a.maxForwardedForEntries)
+	}
+
+	hops := strings.Split(value, ",")
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop := stripPort(strings.TrimSpace(hops[i]))
+		ip := parseIP(hop)
+		if ip == nil {
+			break
+		}
+		client = hop
+		if !a.isTrustedProxy(ip) {
+			break
+		}
+	}
+
+	return []string{client}, nil
+}
+
+// isTrustedProxy reports whether ip belongs to one of the trusted proxies.
+func (a *SimpleBlocklist) isTrustedProxy(ip net.IP) bool {
+	for _, network := range a.trustedProxies {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}